@@ -0,0 +1,103 @@
+package solvent
+
+import (
+	"time"
+
+	"github.com/eldelto/solvent/crdt"
+	"github.com/google/uuid"
+)
+
+// Clock provides the timestamps used to resolve conflicts between
+// concurrent updates and observes the timestamps of merged remote
+// updates so local updates are always ordered after them
+type Clock interface {
+	Now() crdt.Timestamp
+	Observe(remote crdt.Timestamp) error
+}
+
+// MaxClockDrift is the maximum amount of time a remote timestamp may be
+// ahead of the local wall clock to still be observed by the default
+// Clock
+const MaxClockDrift = 1 * time.Minute
+
+// defaultClock is the hybrid logical clock of the current process which
+// is used if no other Clock has been set
+var defaultClock = crdt.NewHybridClock(uuid.New(), MaxClockDrift)
+
+// IDGenerator provides the IDs for newly created Notebooks, ToDoLists
+// and ToDoItems
+type IDGenerator interface {
+	NewID() (uuid.UUID, error)
+}
+
+// RandomIDGenerator is an IDGenerator returning random UUIDs
+type RandomIDGenerator struct{}
+
+// NewID returns a new random UUID or an UnknownError if the
+// generation fails
+func (g RandomIDGenerator) NewID() (uuid.UUID, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		err = &UnknownError{
+			message: "item creation failed with nested error",
+			err:     err,
+		}
+	}
+
+	return id, err
+}
+
+// environment holds the Clock and IDGenerator used by the operations
+// of a Notebook and its ToDoLists and falls back to the process wide
+// hybrid logical clock and the RandomIDGenerator if none are set. It
+// also holds the changeSet the operations record their changes in
+type environment struct {
+	clock       Clock
+	idGenerator IDGenerator
+	changes     *changeSet
+}
+
+func (e *environment) now() crdt.Timestamp {
+	if e.clock == nil {
+		return defaultClock.Now()
+	}
+
+	return e.clock.Now()
+}
+
+func (e *environment) observe(remote crdt.Timestamp) error {
+	if e.clock == nil {
+		return defaultClock.Observe(remote)
+	}
+
+	return e.clock.Observe(remote)
+}
+
+func (e *environment) newID() (uuid.UUID, error) {
+	if e.idGenerator == nil {
+		return RandomIDGenerator{}.NewID()
+	}
+
+	return e.idGenerator.NewID()
+}
+
+// taggedSet is implemented by sets that tag their entries, like the
+// crdt.ORSet
+type taggedSet interface {
+	SetTagGenerator(tags crdt.TagGenerator)
+}
+
+// tagWith lets the given set tag its new entries with the IDGenerator of
+// the environment, so tags are created the same way as the IDs
+func (e *environment) tagWith(set interface{}) {
+	tagged, ok := set.(taggedSet)
+	if !ok {
+		return
+	}
+
+	if e.idGenerator == nil {
+		tagged.SetTagGenerator(RandomIDGenerator{})
+	} else {
+		tagged.SetTagGenerator(e.idGenerator)
+	}
+}
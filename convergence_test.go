@@ -0,0 +1,300 @@
+package solvent
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/eldelto/solvent/crdt"
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/google/uuid"
+)
+
+var convergenceTitles = []string{"a", "b", "c"}
+
+// randomClock returns timestamps from a tiny range so concurrent updates
+// frequently end up with equal timestamps
+type randomClock struct {
+	random *rand.Rand
+}
+
+func (c *randomClock) Now() crdt.Timestamp {
+	return crdt.Timestamp{
+		WallTime: 1 + c.random.Int63n(4),
+		Replica:  uuid.UUID{15: byte(c.random.Intn(2))},
+	}
+}
+
+func (c *randomClock) Observe(remote crdt.Timestamp) error {
+	return nil
+}
+
+// sequentialIDGenerator returns unique IDs shared by all replicas
+type sequentialIDGenerator struct {
+	count uint64
+}
+
+func (g *sequentialIDGenerator) NewID() (uuid.UUID, error) {
+	g.count++
+	id := uuid.UUID{}
+	binary.BigEndian.PutUint64(id[8:], g.count)
+
+	return id, nil
+}
+
+func convergenceEnvironment(seed int64) environment {
+	return environment{
+		clock:       &randomClock{random: rand.New(rand.NewSource(seed))},
+		idGenerator: &sequentialIDGenerator{},
+	}
+}
+
+func randomTitle(random *rand.Rand) string {
+	return convergenceTitles[random.Intn(len(convergenceTitles))]
+}
+
+func toDoItemConvergence(seed int64) Replicated[*ToDoItem] {
+	clock := &randomClock{random: rand.New(rand.NewSource(seed))}
+	item := ToDoItem{ID: uuid.UUID{15: 1}}
+
+	return Replicated[*ToDoItem]{
+		New: func(replica int) *ToDoItem {
+			copied := item
+			return &copied
+		},
+		Operations: []Operation[*ToDoItem]{
+			func(random *rand.Rand, replica int, item *ToDoItem) (*ToDoItem, error) {
+				item.Title = Title{Value: randomTitle(random), UpdatedAt: clock.Now()}
+				return item, nil
+			},
+			func(random *rand.Rand, replica int, item *ToDoItem) (*ToDoItem, error) {
+				item.Checked = Checked{Value: random.Intn(2) == 0, UpdatedAt: clock.Now()}
+				return item, nil
+			},
+			func(random *rand.Rand, replica int, item *ToDoItem) (*ToDoItem, error) {
+				item.OrderValue = OrderValue{Value: randomTitle(random), UpdatedAt: clock.Now()}
+				return item, nil
+			},
+		},
+		Merge: func(this, other *ToDoItem) (*ToDoItem, error) {
+			merged, err := this.Merge(other)
+			if err != nil {
+				return nil, err
+			}
+			return merged.(*ToDoItem), nil
+		},
+		Copy: func(item *ToDoItem) *ToDoItem {
+			copied := *item
+			return &copied
+		},
+		View: func(item *ToDoItem) interface{} {
+			return *item
+		},
+	}
+}
+
+// toDoListOperations returns random operations on a ToDoList that is
+// chosen from the state by the given function
+func toDoListOperations[T any](list func(random *rand.Rand, state T) *ToDoList) []Operation[T] {
+	withList := func(operation func(random *rand.Rand, list *ToDoList) error) Operation[T] {
+		return func(random *rand.Rand, replica int, state T) (T, error) {
+			if list := list(random, state); list != nil {
+				return state, operation(random, list)
+			}
+			return state, nil
+		}
+	}
+	withItem := func(operation func(random *rand.Rand, list *ToDoList, id uuid.UUID) error) Operation[T] {
+		return withList(func(random *rand.Rand, list *ToDoList) error {
+			items := list.GetItems()
+			if len(items) == 0 {
+				return nil
+			}
+			return operation(random, list, items[random.Intn(len(items))].ID)
+		})
+	}
+
+	return []Operation[T]{
+		withList(func(random *rand.Rand, list *ToDoList) error {
+			_, err := list.Rename(randomTitle(random))
+			return err
+		}),
+		withList(func(random *rand.Rand, list *ToDoList) error {
+			_, err := list.AddItem(randomTitle(random))
+			return err
+		}),
+		withItem(func(random *rand.Rand, list *ToDoList, id uuid.UUID) error {
+			list.RemoveItem(id)
+			return nil
+		}),
+		withItem(func(random *rand.Rand, list *ToDoList, id uuid.UUID) error {
+			_, err := list.CheckItem(id)
+			return err
+		}),
+		withItem(func(random *rand.Rand, list *ToDoList, id uuid.UUID) error {
+			_, err := list.UncheckItem(id)
+			return err
+		}),
+		withItem(func(random *rand.Rand, list *ToDoList, id uuid.UUID) error {
+			_, err := list.RenameItem(id, randomTitle(random))
+			return err
+		}),
+		withItem(func(random *rand.Rand, list *ToDoList, id uuid.UUID) error {
+			return list.MoveItem(id, random.Intn(len(list.GetItems())))
+		}),
+	}
+}
+
+type toDoListView struct {
+	ID         uuid.UUID
+	Title      Title
+	OrderValue OrderValue
+	Items      []ToDoItem
+	Tombstones []uuid.UUID
+}
+
+func newToDoListView(list *ToDoList) toDoListView {
+	tombstones := []uuid.UUID{}
+	if items, ok := list.ToDoItems.(crdt.Compactable[uuid.UUID]); ok {
+		tombstones = sortedIDs(items.Tombstones())
+	}
+
+	return toDoListView{
+		ID:         list.ID,
+		Title:      list.Title,
+		OrderValue: list.OrderValue,
+		Items:      list.GetItems(),
+		Tombstones: tombstones,
+	}
+}
+
+func sortedIDs(ids []uuid.UUID) []uuid.UUID {
+	sort.Slice(ids, func(i, j int) bool { return bytes.Compare(ids[i][:], ids[j][:]) < 0 })
+	return ids
+}
+
+func toDoListConvergence(seed int64) Replicated[*ToDoList] {
+	list, _ := convergenceEnvironment(seed).newToDoList(listTitle0)
+	merge := func(this, other *ToDoList) (*ToDoList, error) {
+		merged, err := this.Merge(other)
+		if err != nil {
+			return nil, err
+		}
+		return merged.(*ToDoList), nil
+	}
+	copyList := func(list *ToDoList) *ToDoList {
+		copied, _ := merge(list, list)
+		return copied
+	}
+
+	return Replicated[*ToDoList]{
+		New: func(replica int) *ToDoList {
+			return copyList(list)
+		},
+		Operations: toDoListOperations(func(random *rand.Rand, list *ToDoList) *ToDoList {
+			return list
+		}),
+		Merge: merge,
+		Copy:  copyList,
+		View: func(list *ToDoList) interface{} {
+			return newToDoListView(list)
+		},
+	}
+}
+
+func notebookConvergence(seed int64, options ...NotebookOption) Replicated[*Notebook] {
+	env := convergenceEnvironment(seed)
+	options = append(options, WithClock(env.clock), WithIDGenerator(env.idGenerator))
+	notebook, _ := NewNotebook(options...)
+
+	merge := func(this, other *Notebook) (*Notebook, error) {
+		merged, err := this.Merge(other)
+		if err != nil {
+			return nil, err
+		}
+		return merged.(*Notebook), nil
+	}
+	copyNotebook := func(notebook *Notebook) *Notebook {
+		copied, _ := merge(notebook, notebook)
+		return copied
+	}
+	randomList := func(random *rand.Rand, notebook *Notebook) *ToDoList {
+		lists := notebook.GetLists()
+		if len(lists) == 0 {
+			return nil
+		}
+		return lists[random.Intn(len(lists))]
+	}
+
+	operations := []Operation[*Notebook]{
+		func(random *rand.Rand, replica int, notebook *Notebook) (*Notebook, error) {
+			_, err := notebook.AddList(randomTitle(random))
+			return notebook, err
+		},
+		func(random *rand.Rand, replica int, notebook *Notebook) (*Notebook, error) {
+			if list := randomList(random, notebook); list != nil {
+				notebook.RemoveList(list.ID)
+			}
+			return notebook, nil
+		},
+		func(random *rand.Rand, replica int, notebook *Notebook) (*Notebook, error) {
+			if list := randomList(random, notebook); list != nil {
+				return notebook, notebook.MoveList(list.ID, random.Intn(len(notebook.GetLists())))
+			}
+			return notebook, nil
+		},
+	}
+	operations = append(operations, toDoListOperations(randomList)...)
+
+	return Replicated[*Notebook]{
+		New: func(replica int) *Notebook {
+			return copyNotebook(notebook)
+		},
+		Operations: operations,
+		Merge:      merge,
+		Copy:       copyNotebook,
+		View: func(notebook *Notebook) interface{} {
+			lists := []toDoListView{}
+			for _, list := range notebook.GetLists() {
+				lists = append(lists, newToDoListView(list))
+			}
+			return []interface{}{lists, sortedIDs(notebook.Tombstones())}
+		},
+	}
+}
+
+func addConvergenceSeeds(f *testing.F) {
+	for seed := int64(0); seed < 20; seed++ {
+		f.Add(seed)
+	}
+}
+
+func FuzzToDoItemConvergence(f *testing.F) {
+	addConvergenceSeeds(f)
+	f.Fuzz(func(t *testing.T, seed int64) {
+		AssertConvergence(t, seed, toDoItemConvergence(seed))
+	})
+}
+
+func FuzzToDoListConvergence(f *testing.F) {
+	addConvergenceSeeds(f)
+	f.Fuzz(func(t *testing.T, seed int64) {
+		AssertConvergence(t, seed, toDoListConvergence(seed))
+	})
+}
+
+func FuzzNotebookConvergence(f *testing.F) {
+	addConvergenceSeeds(f)
+	f.Fuzz(func(t *testing.T, seed int64) {
+		AssertConvergence(t, seed, notebookConvergence(seed))
+	})
+}
+
+func FuzzNotebookWithORSetsConvergence(f *testing.F) {
+	addConvergenceSeeds(f)
+	f.Fuzz(func(t *testing.T, seed int64) {
+		AssertConvergence(t, seed, notebookConvergence(seed, WithORSets()))
+	})
+}
@@ -0,0 +1,185 @@
+package crdt
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	. "github.com/eldelto/solvent/internal/testutils"
+)
+
+var convergenceKeys = []string{"a", "b", "c", "d", "e"}
+
+// maxMergeable is a grow-only register that merges to the greater value
+type maxMergeable struct {
+	value      int
+	identifier string
+}
+
+func (m *maxMergeable) Identifier() interface{} {
+	return m.identifier
+}
+
+func (m *maxMergeable) Key() string {
+	return m.identifier
+}
+
+func (m *maxMergeable) Merge(other Mergeable) (Mergeable, error) {
+	if m.Identifier() != other.Identifier() {
+		return nil, NewCannotBeMergedError(m, other)
+	}
+
+	merged := *m
+	if otherValue := other.(*maxMergeable).value; otherValue > merged.value {
+		merged.value = otherValue
+	}
+
+	return &merged, nil
+}
+
+func randomMaxMergeable(random *rand.Rand) *maxMergeable {
+	return &maxMergeable{
+		value:      random.Intn(10),
+		identifier: convergenceKeys[random.Intn(len(convergenceKeys))],
+	}
+}
+
+func randomLiveItem[K comparable, V Keyed[K]](random *rand.Rand, liveView ItemMap[K, V]) (V, bool) {
+	if len(liveView) == 0 {
+		var zero V
+		return zero, false
+	}
+
+	// Map iteration order is random, so items are chosen by the rank of
+	// their key to keep the history reproducible
+	items := make([]V, 0, len(liveView))
+	for _, item := range liveView {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return fmt.Sprint(items[i].Key()) < fmt.Sprint(items[j].Key())
+	})
+
+	return items[random.Intn(len(items))], true
+}
+
+func copyMaxMergeables(itemMap ItemMap[string, *maxMergeable]) ItemMap[string, *maxMergeable] {
+	copied := make(ItemMap[string, *maxMergeable], len(itemMap))
+	for key, item := range itemMap {
+		copiedItem := *item
+		copied[key] = &copiedItem
+	}
+
+	return copied
+}
+
+func maxMergeableValues(itemMap ItemMap[string, *maxMergeable]) map[string]int {
+	values := make(map[string]int, len(itemMap))
+	for key, item := range itemMap {
+		values[key] = item.value
+	}
+
+	return values
+}
+
+type maxPSet = PSet[string, *maxMergeable]
+
+func psetConvergence() Replicated[*maxPSet] {
+	return Replicated[*maxPSet]{
+		New: func(replica int) *maxPSet {
+			pset := NewPSet[string, *maxMergeable](psetID0)
+			return &pset
+		},
+		Operations: []Operation[*maxPSet]{
+			func(random *rand.Rand, replica int, pset *maxPSet) (*maxPSet, error) {
+				return pset, pset.Add(randomMaxMergeable(random))
+			},
+			func(random *rand.Rand, replica int, pset *maxPSet) (*maxPSet, error) {
+				if item, ok := randomLiveItem(random, pset.LiveView()); ok {
+					pset.Remove(item)
+				}
+				return pset, nil
+			},
+		},
+		Merge: func(this, other *maxPSet) (*maxPSet, error) {
+			return this.MergePSet(other)
+		},
+		Copy: func(pset *maxPSet) *maxPSet {
+			return &maxPSet{
+				LiveSet:      copyMaxMergeables(pset.LiveSet),
+				TombstoneSet: copyMaxMergeables(pset.TombstoneSet),
+				identifier:   pset.identifier,
+			}
+		},
+		View: func(pset *maxPSet) interface{} {
+			return []map[string]int{
+				maxMergeableValues(pset.LiveView()),
+				maxMergeableValues(pset.TombstoneSet),
+			}
+		},
+	}
+}
+
+type maxORSet = ORSet[string, *maxMergeable]
+
+func orsetConvergence() Replicated[*maxORSet] {
+	return Replicated[*maxORSet]{
+		New: func(replica int) *maxORSet {
+			orset := NewORSet[string, *maxMergeable](psetID0)
+			return &orset
+		},
+		Operations: []Operation[*maxORSet]{
+			func(random *rand.Rand, replica int, orset *maxORSet) (*maxORSet, error) {
+				return orset, orset.Add(randomMaxMergeable(random))
+			},
+			func(random *rand.Rand, replica int, orset *maxORSet) (*maxORSet, error) {
+				if item, ok := randomLiveItem(random, orset.LiveView()); ok {
+					orset.Remove(item)
+				}
+				return orset, nil
+			},
+		},
+		Merge: func(this, other *maxORSet) (*maxORSet, error) {
+			return this.MergeORSet(other)
+		},
+		Copy: func(orset *maxORSet) *maxORSet {
+			copied := NewORSet[string, *maxMergeable](orset.identifier)
+			for key, taggedItems := range orset.Entries {
+				copiedTaggedItems := make(TaggedItems[*maxMergeable], len(taggedItems))
+				for tag, item := range taggedItems {
+					copiedItem := *item
+					copiedTaggedItems[tag] = &copiedItem
+				}
+				copied.Entries[key] = copiedTaggedItems
+			}
+			for tag := range orset.RemovedTags {
+				copied.RemovedTags[tag] = struct{}{}
+			}
+			return &copied
+		},
+		View: func(orset *maxORSet) interface{} {
+			return maxMergeableValues(orset.LiveView())
+		},
+	}
+}
+
+func FuzzPSetConvergence(f *testing.F) {
+	for seed := int64(0); seed < 20; seed++ {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		AssertConvergence(t, seed, psetConvergence())
+	})
+}
+
+func FuzzORSetConvergence(f *testing.F) {
+	for seed := int64(0); seed < 20; seed++ {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		AssertConvergence(t, seed, orsetConvergence())
+	})
+}
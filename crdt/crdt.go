@@ -10,38 +10,101 @@ type Mergeable interface {
 	Merge(other Mergeable) (Mergeable, error)
 }
 
-type ItemMap map[interface{}]Mergeable
+// Keyed is a Mergeable that additionally exposes its identifier as a
+// comparable key so it can be stored in the generic collections
+type Keyed[K comparable] interface {
+	Mergeable
+	Key() K
+}
+
+// ItemMap is a mapping from key -> item used by the generic collections
+type ItemMap[K comparable, V Keyed[K]] map[K]V
+
+// Set is the common interface of the set CRDTs holding Keyed items
+type Set[K comparable, V Keyed[K]] interface {
+	Mergeable
+	Add(item V) error
+	Remove(item V)
+	Get(key K) (V, bool)
+	LiveView() ItemMap[K, V]
+	Delta(keys []K, project func(item V) V) Set[K, V]
+}
+
+// MergeSets merges two Sets of the same kind or returns a
+// TypeMisMatchError if they are backed by different implementations
+func MergeSets[K comparable, V Keyed[K]](this, other Set[K, V]) (Set[K, V], error) {
+	merged, err := this.Merge(other)
+	if err != nil {
+		return nil, err
+	}
+
+	mergedSet, ok := merged.(Set[K, V])
+	if !ok {
+		return nil, NewTypeMisMatchError(this, merged)
+	}
+
+	return mergedSet, nil
+}
+
+// Compactable is implemented by sets that are able to drop the
+// tombstones of removed items. Pruning a tombstone is only safe once it
+// is causally stable, meaning every replica has observed the removal,
+// otherwise a replica that still holds the live item would resurrect
+// it on the next merge
+type Compactable[K comparable] interface {
+	Tombstones() []K
+	Prune(stable func(key K) bool) []K
+}
 
-type PSet struct {
-	LiveSet      ItemMap
-	TombstoneSet ItemMap
+// PSet is a 2P-Set consisting of two grow-only sets. An item is part of
+// the PSet as long as it is contained in the LiveSet but not in the
+// TombstoneSet
+type PSet[K comparable, V Keyed[K]] struct {
+	LiveSet      ItemMap[K, V]
+	TombstoneSet ItemMap[K, V]
 	identifier   string
 }
 
-func NewPSet(identifier string) PSet {
-	return PSet{
-		LiveSet:      ItemMap{},
-		TombstoneSet: ItemMap{},
+func NewPSet[K comparable, V Keyed[K]](identifier string) PSet[K, V] {
+	return PSet[K, V]{
+		LiveSet:      ItemMap[K, V]{},
+		TombstoneSet: ItemMap[K, V]{},
 		identifier:   identifier,
 	}
 }
 
-func (p *PSet) Add(item Mergeable) error {
+func (p *PSet[K, V]) Add(item V) error {
 	return addToItemMap(p.LiveSet, item)
 }
 
-func (p *PSet) Remove(item Mergeable) {
-	key := item.Identifier()
+func (p *PSet[K, V]) Remove(item V) {
+	key := item.Key()
 
-	if _, ok := p.LiveView()[key]; !ok {
+	if _, ok := p.Get(key); !ok {
 		return
 	}
 
 	p.TombstoneSet[key] = item
 }
 
-func (p *PSet) LiveView() ItemMap {
-	liveView := ItemMap{}
+// Get returns the item with the given key if it is part of the
+// LiveSet but not of the TombstoneSet
+func (p *PSet[K, V]) Get(key K) (V, bool) {
+	item, ok := p.LiveSet[key]
+	if !ok {
+		return item, false
+	}
+
+	if _, ok := p.TombstoneSet[key]; ok {
+		var zero V
+		return zero, false
+	}
+
+	return item, true
+}
+
+func (p *PSet[K, V]) LiveView() ItemMap[K, V] {
+	liveView := make(ItemMap[K, V], len(p.LiveSet))
 
 	for key, value := range p.LiveSet {
 		if _, ok := p.TombstoneSet[key]; !ok {
@@ -52,33 +115,90 @@ func (p *PSet) LiveView() ItemMap {
 	return liveView
 }
 
-func (p *PSet) Identifier() interface{} {
+// Delta returns a PSet that only contains the state of the items with
+// the given keys. The delta is itself a PSet and merging it into a
+// replica applies all the changes made to those items. The optional
+// project function is applied to every item of the delta
+func (p *PSet[K, V]) Delta(keys []K, project func(item V) V) Set[K, V] {
+	delta := NewPSet[K, V](p.identifier)
+	for _, key := range keys {
+		if item, ok := p.LiveSet[key]; ok {
+			delta.LiveSet[key] = projectItem(item, project)
+		}
+		if item, ok := p.TombstoneSet[key]; ok {
+			delta.TombstoneSet[key] = projectItem(item, project)
+		}
+	}
+
+	return &delta
+}
+
+// Tombstones returns the keys of all the items that have been removed
+// from the PSet
+func (p *PSet[K, V]) Tombstones() []K {
+	keys := make([]K, 0, len(p.TombstoneSet))
+	for key := range p.TombstoneSet {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// Prune drops all the removed items for which stable returns true from
+// both the LiveSet and the TombstoneSet and returns their keys
+func (p *PSet[K, V]) Prune(stable func(key K) bool) []K {
+	pruned := []K{}
+	for key := range p.TombstoneSet {
+		if !stable(key) {
+			continue
+		}
+
+		delete(p.LiveSet, key)
+		delete(p.TombstoneSet, key)
+		pruned = append(pruned, key)
+	}
+
+	return pruned
+}
+
+func (p *PSet[K, V]) Identifier() interface{} {
 	return p.identifier
 }
 
-func (p *PSet) Merge(other Mergeable) (Mergeable, error) {
+func (p *PSet[K, V]) Merge(other Mergeable) (Mergeable, error) {
 	if p.Identifier() != other.Identifier() {
 		err := NewCannotBeMergedError(p, other)
 		return nil, err
 	}
 
-	otherPSet, ok := other.(*PSet)
+	otherPSet, ok := other.(*PSet[K, V])
 	if !ok {
 		err := NewTypeMisMatchError(p, other)
 		return nil, err
 	}
 
-	mergedLiveSet, err := mergeItemMaps(p.LiveSet, otherPSet.LiveSet)
+	return p.MergePSet(otherPSet)
+}
+
+// MergePSet is the type-safe variant of Merge for two PSets holding
+// the same item type
+func (p *PSet[K, V]) MergePSet(other *PSet[K, V]) (*PSet[K, V], error) {
+	if p.Identifier() != other.Identifier() {
+		err := NewCannotBeMergedError(p, other)
+		return nil, err
+	}
+
+	mergedLiveSet, err := mergeItemMaps(p.LiveSet, other.LiveSet)
 	if err != nil {
 		return nil, err
 	}
 
-	mergedTombstoneSet, err := mergeItemMaps(p.TombstoneSet, otherPSet.TombstoneSet)
+	mergedTombstoneSet, err := mergeItemMaps(p.TombstoneSet, other.TombstoneSet)
 	if err != nil {
 		return nil, err
 	}
 
-	mergedPSet := PSet{
+	mergedPSet := PSet[K, V]{
 		LiveSet:      mergedLiveSet,
 		TombstoneSet: mergedTombstoneSet,
 		identifier:   p.identifier,
@@ -86,8 +206,16 @@ func (p *PSet) Merge(other Mergeable) (Mergeable, error) {
 	return &mergedPSet, nil
 }
 
-func mergeItemMaps(this, other ItemMap) (ItemMap, error) {
-	mergedItemMap := ItemMap{}
+func projectItem[V any](item V, project func(item V) V) V {
+	if project == nil {
+		return item
+	}
+
+	return project(item)
+}
+
+func mergeItemMaps[K comparable, V Keyed[K]](this, other ItemMap[K, V]) (ItemMap[K, V], error) {
+	mergedItemMap := make(ItemMap[K, V], len(this))
 	for key, value := range this {
 		mergedItemMap[key] = value
 	}
@@ -102,8 +230,8 @@ func mergeItemMaps(this, other ItemMap) (ItemMap, error) {
 	return mergedItemMap, nil
 }
 
-func addToItemMap(itemMap ItemMap, item Mergeable) error {
-	key := item.Identifier()
+func addToItemMap[K comparable, V Keyed[K]](itemMap ItemMap[K, V], item V) error {
+	key := item.Key()
 
 	oldItem, ok := itemMap[key]
 	if !ok {
@@ -111,7 +239,7 @@ func addToItemMap(itemMap ItemMap, item Mergeable) error {
 		return nil
 	}
 
-	mergedItem, err := oldItem.Merge(item)
+	mergedItem, err := mergeItems(oldItem, item)
 	if err != nil {
 		return err
 	}
@@ -120,6 +248,22 @@ func addToItemMap(itemMap ItemMap, item Mergeable) error {
 	return nil
 }
 
+func mergeItems[V Mergeable](this, other V) (V, error) {
+	merged, err := this.Merge(other)
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+
+	mergedItem, ok := merged.(V)
+	if !ok {
+		var zero V
+		return zero, NewTypeMisMatchError(this, merged)
+	}
+
+	return mergedItem, nil
+}
+
 // CannotBeMergedError indicates that two entities cannot be merged
 // (e.g. IDs do not match)
 type CannotBeMergedError struct {
@@ -27,7 +27,7 @@ var invalidMergeable = testMergeable{
 }
 
 func TestAdd(t *testing.T) {
-	pset := NewPSet(psetID0)
+	pset := newTestPSet(psetID0)
 
 	err := pset.Add(&mergeable0)
 	AssertEquals(t, nil, err, "pset.Add error")
@@ -37,15 +37,15 @@ func TestAdd(t *testing.T) {
 
 	mergedMergeable := mergeable0
 	mergedMergeable.value = 2
-	expected := ItemMap{
-		mergeable0.Identifier(): &mergedMergeable,
-		mergeable1.Identifier(): &mergeable1,
+	expected := testItemMap{
+		mergeableID0: &mergedMergeable,
+		mergeableID1: &mergeable1,
 	}
 	AssertEquals(t, expected, pset.LiveSet, "pset.LiveSet")
 }
 
 func TestInvalidAdd(t *testing.T) {
-	pset := NewPSet(psetID0)
+	pset := newTestPSet(psetID0)
 
 	err := pset.Add(&mergeable0)
 	AssertEquals(t, nil, err, "pset.Add error")
@@ -54,65 +54,65 @@ func TestInvalidAdd(t *testing.T) {
 	expectedErr := NewCannotBeMergedError(&mergeable0, &invalidMergeable)
 	AssertEquals(t, expectedErr, err, "pset.Add error")
 
-	expected := ItemMap{
-		mergeable0.Identifier(): &mergeable0,
+	expected := testItemMap{
+		mergeableID0: &mergeable0,
 	}
 	AssertEquals(t, expected, pset.LiveSet, "pset.LiveSet")
 }
 
 func TestRemove(t *testing.T) {
-	pset := NewPSet(psetID0)
+	pset := newTestPSet(psetID0)
 
 	pset.Add(&mergeable0)
 	pset.Remove(&mergeable0)
 	pset.Remove(&mergeable1)
 
-	expected := ItemMap{
-		mergeable0.Identifier(): &mergeable0,
+	expected := testItemMap{
+		mergeableID0: &mergeable0,
 	}
 	AssertEquals(t, expected, pset.TombstoneSet, "pset.TombstoneSet")
 }
 
 func TestLiveView(t *testing.T) {
-	pset := NewPSet(psetID0)
+	pset := newTestPSet(psetID0)
 
 	pset.Add(&mergeable0)
 	pset.Add(&mergeable1)
 	pset.Remove(&mergeable0)
 
-	expected := ItemMap{
-		mergeable1.Identifier(): &mergeable1,
+	expected := testItemMap{
+		mergeableID1: &mergeable1,
 	}
 	AssertEquals(t, expected, pset.LiveView(), "pset.LiveView")
 }
 
 func TestIdentifier(t *testing.T) {
-	pset := NewPSet(psetID0)
+	pset := newTestPSet(psetID0)
 
 	AssertEquals(t, psetID0, pset.Identifier(), "pset.Identifier")
 }
 
 func TestMerge(t *testing.T) {
-	pset0 := NewPSet(psetID0)
+	pset0 := newTestPSet(psetID0)
 	pset0.Add(&mergeable0)
 	pset0.Add(&mergeable1)
 
-	pset1 := NewPSet(psetID0)
+	pset1 := newTestPSet(psetID0)
 	pset1.Add(&mergeable0)
 	pset1.Remove(&mergeable0)
 
 	mergedPSet, err := pset0.Merge(&pset1)
 	AssertEquals(t, nil, err, "pset0.Merge error")
 
-	expected := ItemMap{
-		mergeable1.Identifier(): &mergeable1,
+	expected := testItemMap{
+		mergeableID1: &mergeable1,
 	}
-	AssertEquals(t, expected, mergedPSet.(*PSet).LiveView(), "pset.LiveView")
+	AssertEquals(t, expected, mergedPSet.(*testPSet).LiveView(), "pset.LiveView")
 }
 
 func TestInvalidIdentifierMerge(t *testing.T) {
-	pset0 := NewPSet(psetID0)
-	pset1 := NewPSet(psetID1)
+	pset0 := newTestPSet(psetID0)
+	pset1 := newTestPSet(psetID1)
 
 	_, err := pset0.Merge(&pset1)
 
@@ -121,10 +121,10 @@ func TestInvalidIdentifierMerge(t *testing.T) {
 }
 
 func TestMergeError(t *testing.T) {
-	pset0 := NewPSet(psetID0)
+	pset0 := newTestPSet(psetID0)
 	pset0.Add(&mergeable0)
 
-	pset1 := NewPSet(psetID0)
+	pset1 := newTestPSet(psetID0)
 	pset1.Add(&invalidMergeable)
 
 	_, err := pset0.Merge(&pset1)
@@ -133,6 +133,62 @@ func TestMergeError(t *testing.T) {
 	AssertEquals(t, expected, err, "pset0.Merge error")
 }
 
+func TestGet(t *testing.T) {
+	pset := newTestPSet(psetID0)
+	pset.Add(&mergeable0)
+	pset.Add(&mergeable1)
+	pset.Remove(&mergeable1)
+
+	item, ok := pset.Get(mergeableID0)
+	AssertEquals(t, true, ok, "pset.Get ok")
+	AssertEquals(t, &mergeable0, item, "pset.Get item")
+
+	item, ok = pset.Get(mergeableID1)
+	AssertEquals(t, false, ok, "pset.Get removed ok")
+	AssertEquals(t, (*testMergeable)(nil), item, "pset.Get removed item")
+
+	_, ok = pset.Get("unknown")
+	AssertEquals(t, false, ok, "pset.Get unknown ok")
+}
+
+func TestMergePSet(t *testing.T) {
+	pset0 := newTestPSet(psetID0)
+	pset0.Add(&mergeable0)
+
+	pset1 := newTestPSet(psetID0)
+	pset1.Add(&mergeable0)
+	pset1.Add(&mergeable1)
+
+	mergedPSet, err := pset0.MergePSet(&pset1)
+	AssertEquals(t, nil, err, "pset0.MergePSet error")
+
+	mergedMergeable := mergeable0
+	mergedMergeable.value = 2
+	expected := testItemMap{
+		mergeableID0: &mergedMergeable,
+		mergeableID1: &mergeable1,
+	}
+	AssertEquals(t, expected, mergedPSet.LiveView(), "mergedPSet.LiveView")
+	AssertEquals(t, psetID0, mergedPSet.Identifier(), "mergedPSet.Identifier")
+}
+
+func TestTypeMisMatchMerge(t *testing.T) {
+	pset0 := newTestPSet(psetID0)
+	pset1 := NewPSet[string, *otherTestMergeable](psetID0)
+
+	_, err := pset0.Merge(&pset1)
+
+	expected := NewTypeMisMatchError(&pset0, &pset1)
+	AssertEquals(t, expected, err, "pset0.Merge error")
+}
+
+type testPSet = PSet[string, *testMergeable]
+type testItemMap = ItemMap[string, *testMergeable]
+
+func newTestPSet(identifier string) testPSet {
+	return NewPSet[string, *testMergeable](identifier)
+}
+
 type testMergeable struct {
 	value      int
 	identifier string
@@ -142,6 +198,10 @@ func (t *testMergeable) Identifier() interface{} {
 	return t.identifier
 }
 
+func (t *testMergeable) Key() string {
+	return t.identifier
+}
+
 func (t *testMergeable) Merge(other Mergeable) (Mergeable, error) {
 	if t.Identifier() != other.Identifier() {
 		err := NewCannotBeMergedError(t, other)
@@ -161,3 +221,51 @@ func (t *testMergeable) Merge(other Mergeable) (Mergeable, error) {
 
 	return &merged, nil
 }
+
+type otherTestMergeable struct {
+	testMergeable
+}
+
+func (t *otherTestMergeable) Merge(other Mergeable) (Mergeable, error) {
+	return t, nil
+}
+
+func TestPrune(t *testing.T) {
+	pset := newTestPSet(psetID0)
+	pset.Add(&mergeable0)
+	pset.Add(&mergeable1)
+	pset.Remove(&mergeable0)
+	pset.Remove(&mergeable1)
+
+	AssertEquals(t, 2, len(pset.Tombstones()), "len(pset.Tombstones)")
+
+	pruned := pset.Prune(func(key string) bool { return key == mergeableID0 })
+	AssertEquals(t, []string{mergeableID0}, pruned, "pset.Prune")
+
+	expected := testItemMap{
+		mergeableID1: &mergeable1,
+	}
+	AssertEquals(t, expected, pset.LiveSet, "pset.LiveSet")
+	AssertEquals(t, expected, pset.TombstoneSet, "pset.TombstoneSet")
+}
+
+func TestDelta(t *testing.T) {
+	pset := newTestPSet(psetID0)
+	pset.Add(&mergeable0)
+	pset.Add(&mergeable1)
+	pset.Remove(&mergeable0)
+
+	delta := pset.Delta([]string{mergeableID0}, nil).(*testPSet)
+
+	expected := testItemMap{
+		mergeableID0: &mergeable0,
+	}
+	AssertEquals(t, expected, delta.LiveSet, "delta.LiveSet")
+	AssertEquals(t, expected, delta.TombstoneSet, "delta.TombstoneSet")
+
+	replica := newTestPSet(psetID0)
+	replica.Add(&mergeable0)
+	merged, err := replica.MergePSet(delta)
+	AssertEquals(t, nil, err, "replica.MergePSet error")
+	AssertEquals(t, testItemMap{}, merged.LiveView(), "merged.LiveView")
+}
@@ -0,0 +1,148 @@
+package crdt
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Timestamp is a hybrid logical clock timestamp consisting of the
+// physical wall time in UTC nanoseconds, a logical counter that orders
+// events within the same wall time and the ID of the replica that
+// created it which breaks ties between concurrent events
+type Timestamp struct {
+	WallTime int64
+	Counter  uint32
+	Replica  uuid.UUID
+}
+
+// Compare returns -1 if the Timestamp happened before the other one,
+// 1 if it happened after and 0 if both are equal
+func (t Timestamp) Compare(other Timestamp) int {
+	switch {
+	case t.WallTime < other.WallTime:
+		return -1
+	case t.WallTime > other.WallTime:
+		return 1
+	case t.Counter < other.Counter:
+		return -1
+	case t.Counter > other.Counter:
+		return 1
+	default:
+		return bytes.Compare(t.Replica[:], other.Replica[:])
+	}
+}
+
+// After reports whether the Timestamp happened after the other one
+func (t Timestamp) After(other Timestamp) bool {
+	return t.Compare(other) > 0
+}
+
+// Before reports whether the Timestamp happened before the other one
+func (t Timestamp) Before(other Timestamp) bool {
+	return t.Compare(other) < 0
+}
+
+// HybridClock is a hybrid logical clock generating Timestamps that are
+// close to the wall clock time but never go backwards and are always
+// greater than every Timestamp the clock has observed so far
+type HybridClock struct {
+	replica   uuid.UUID
+	maxDrift  time.Duration
+	wallClock func() int64
+	last      Timestamp
+	mutex     sync.Mutex
+}
+
+// NewHybridClock creates a HybridClock for the given replica that
+// refuses to observe Timestamps which are more than maxDrift ahead of
+// the local wall clock
+func NewHybridClock(replica uuid.UUID, maxDrift time.Duration) *HybridClock {
+	return &HybridClock{
+		replica:   replica,
+		maxDrift:  maxDrift,
+		wallClock: func() int64 { return time.Now().UTC().UnixNano() },
+		last:      Timestamp{Replica: replica},
+	}
+}
+
+// Replica returns the ID of the replica the HybridClock belongs to
+func (c *HybridClock) Replica() uuid.UUID {
+	return c.replica
+}
+
+// Now returns a new Timestamp that is greater than all the Timestamps
+// previously returned or observed by the HybridClock
+func (c *HybridClock) Now() Timestamp {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	wallTime := c.wallClock()
+	if wallTime > c.last.WallTime {
+		c.last = Timestamp{WallTime: wallTime, Replica: c.replica}
+	} else {
+		c.last.Counter++
+	}
+
+	return c.last
+}
+
+// Observe advances the HybridClock past the given remote Timestamp or
+// returns a ClockDriftError without advancing it if the remote
+// Timestamp is too far ahead of the local wall clock
+func (c *HybridClock) Observe(remote Timestamp) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	wallTime := c.wallClock()
+	if remote.WallTime-wallTime > c.maxDrift.Nanoseconds() {
+		return NewClockDriftError(remote, wallTime)
+	}
+
+	last := c.last
+	switch {
+	case wallTime > last.WallTime && wallTime > remote.WallTime:
+		c.last = Timestamp{WallTime: wallTime}
+	case last.WallTime == remote.WallTime:
+		c.last.Counter = maxCounter(last.Counter, remote.Counter) + 1
+	case last.WallTime > remote.WallTime:
+		c.last.Counter++
+	default:
+		c.last = Timestamp{WallTime: remote.WallTime, Counter: remote.Counter + 1}
+	}
+	c.last.Replica = c.replica
+
+	return nil
+}
+
+func maxCounter(a, b uint32) uint32 {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+// ClockDriftError indicates that a remote Timestamp is too far ahead of
+// the local wall clock to be observed
+type ClockDriftError struct {
+	Remote   Timestamp
+	WallTime int64
+	message  string
+}
+
+func NewClockDriftError(remote Timestamp, wallTime int64) *ClockDriftError {
+	return &ClockDriftError{
+		Remote:   remote,
+		WallTime: wallTime,
+		message: fmt.Sprintf("timestamp of replica '%v' is %v ahead of the local clock",
+			remote.Replica, time.Duration(remote.WallTime-wallTime)),
+	}
+}
+
+func (e *ClockDriftError) Error() string {
+	return e.message
+}
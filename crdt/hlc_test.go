@@ -0,0 +1,73 @@
+package crdt
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/google/uuid"
+)
+
+var replica0 = uuid.UUID{15: 1}
+var replica1 = uuid.UUID{15: 2}
+
+func newTestHybridClock(wallTime *int64) *HybridClock {
+	clock := NewHybridClock(replica0, time.Second)
+	clock.wallClock = func() int64 { return *wallTime }
+
+	return clock
+}
+
+func TestTimestampCompare(t *testing.T) {
+	timestamp := Timestamp{WallTime: 10, Counter: 1, Replica: replica0}
+
+	AssertEquals(t, 0, timestamp.Compare(timestamp), "Compare equal")
+	AssertEquals(t, true, timestamp.After(Timestamp{WallTime: 9, Counter: 5, Replica: replica1}), "After wall time")
+	AssertEquals(t, true, timestamp.Before(Timestamp{WallTime: 10, Counter: 2, Replica: replica0}), "Before counter")
+	AssertEquals(t, true, timestamp.Before(Timestamp{WallTime: 10, Counter: 1, Replica: replica1}), "Before replica")
+}
+
+func TestHybridClockNow(t *testing.T) {
+	wallTime := int64(100)
+	clock := newTestHybridClock(&wallTime)
+
+	timestamp0 := clock.Now()
+	AssertEquals(t, Timestamp{WallTime: 100, Replica: replica0}, timestamp0, "clock.Now")
+
+	timestamp1 := clock.Now()
+	AssertEquals(t, Timestamp{WallTime: 100, Counter: 1, Replica: replica0}, timestamp1, "clock.Now same wall time")
+
+	wallTime = 50
+	timestamp2 := clock.Now()
+	AssertEquals(t, true, timestamp2.After(timestamp1), "clock.Now after wall clock went backwards")
+
+	wallTime = 200
+	timestamp3 := clock.Now()
+	AssertEquals(t, Timestamp{WallTime: 200, Replica: replica0}, timestamp3, "clock.Now new wall time")
+}
+
+func TestHybridClockObserve(t *testing.T) {
+	wallTime := int64(100)
+	clock := newTestHybridClock(&wallTime)
+	clock.Now()
+
+	remote := Timestamp{WallTime: 150, Counter: 3, Replica: replica1}
+	err := clock.Observe(remote)
+	AssertEquals(t, nil, err, "clock.Observe error")
+
+	timestamp := clock.Now()
+	AssertEquals(t, Timestamp{WallTime: 150, Counter: 5, Replica: replica0}, timestamp, "clock.Now")
+	AssertEquals(t, true, timestamp.After(remote), "timestamp.After")
+}
+
+func TestHybridClockObserveDrift(t *testing.T) {
+	wallTime := int64(100)
+	clock := newTestHybridClock(&wallTime)
+
+	remote := Timestamp{WallTime: 100 + time.Minute.Nanoseconds(), Replica: replica1}
+	err := clock.Observe(remote)
+	AssertEquals(t, NewClockDriftError(remote, 100), err, "clock.Observe error")
+
+	timestamp := clock.Now()
+	AssertEquals(t, Timestamp{WallTime: 100, Replica: replica0}, timestamp, "clock.Now")
+}
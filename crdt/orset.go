@@ -0,0 +1,288 @@
+package crdt
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// TagSet is a set of the unique tags an ORSet assigns to every add
+// operation
+type TagSet map[uuid.UUID]struct{}
+
+// TaggedItems is a mapping from tag -> item holding the payload of
+// every add operation of a single key that has not been removed yet
+type TaggedItems[V any] map[uuid.UUID]V
+
+// TagGenerator provides the unique tags of an ORSet
+type TagGenerator interface {
+	NewID() (uuid.UUID, error)
+}
+
+// ORSet is an Observed-Remove Set. Every add operation is assigned a
+// unique tag and a remove operation only tombstones the tags it has
+// observed. An item is part of the ORSet as long as at least one of
+// its tags has not been removed which allows items to be re-added with
+// the same key after they have been removed
+type ORSet[K comparable, V Keyed[K]] struct {
+	Entries     map[K]TaggedItems[V]
+	RemovedTags TagSet
+	identifier  string
+	// tags creates the tags of new entries or random UUIDs are used if
+	// it is nil
+	tags TagGenerator
+	// removedKeys maps the removed tags this replica has seen the
+	// entries of to their keys, so deltas only contain the removed tags
+	// of their keys. It is not part of the state that is merged or
+	// stored
+	removedKeys map[uuid.UUID]K
+}
+
+func NewORSet[K comparable, V Keyed[K]](identifier string) ORSet[K, V] {
+	return ORSet[K, V]{
+		Entries:     map[K]TaggedItems[V]{},
+		RemovedTags: TagSet{},
+		identifier:  identifier,
+		removedKeys: map[uuid.UUID]K{},
+	}
+}
+
+// SetTagGenerator sets the TagGenerator used to tag new entries. Random
+// UUIDs are used if it is nil
+func (o *ORSet[K, V]) SetTagGenerator(tags TagGenerator) {
+	o.tags = tags
+}
+
+func (o *ORSet[K, V]) newTag() (uuid.UUID, error) {
+	if o.tags == nil {
+		return uuid.NewRandom()
+	}
+
+	return o.tags.NewID()
+}
+
+// Add merges the given item into the already existing one or tags it
+// as a fresh entry if no item with the same key is part of the ORSet
+func (o *ORSet[K, V]) Add(item V) error {
+	key := item.Key()
+
+	taggedItems, ok := o.Entries[key]
+	if !ok {
+		tag, err := o.newTag()
+		if err != nil {
+			return err
+		}
+
+		o.Entries[key] = TaggedItems[V]{tag: item}
+		return nil
+	}
+
+	mergedTaggedItems := make(TaggedItems[V], len(taggedItems))
+	for tag, taggedItem := range taggedItems {
+		mergedItem, err := mergeItems(taggedItem, item)
+		if err != nil {
+			return err
+		}
+		mergedTaggedItems[tag] = mergedItem
+	}
+	o.Entries[key] = mergedTaggedItems
+
+	return nil
+}
+
+// Remove tombstones all the tags of the given item that have been
+// observed so far
+func (o *ORSet[K, V]) Remove(item V) {
+	key := item.Key()
+
+	if o.removedKeys == nil {
+		o.removedKeys = map[uuid.UUID]K{}
+	}
+	for tag := range o.Entries[key] {
+		o.RemovedTags[tag] = struct{}{}
+		o.removedKeys[tag] = key
+	}
+	delete(o.Entries, key)
+}
+
+// Get returns the item with the given key by merging the payloads of
+// all its tags that have not been removed yet
+func (o *ORSet[K, V]) Get(key K) (V, bool) {
+	var mergedItem V
+	taggedItems := o.Entries[key]
+	for i, tag := range sortedTags(taggedItems) {
+		if i == 0 {
+			mergedItem = taggedItems[tag]
+			continue
+		}
+
+		// Payloads of the same key can always be merged
+		if item, err := mergeItems(mergedItem, taggedItems[tag]); err == nil {
+			mergedItem = item
+		}
+	}
+
+	return mergedItem, len(taggedItems) > 0
+}
+
+func (o *ORSet[K, V]) LiveView() ItemMap[K, V] {
+	liveView := make(ItemMap[K, V], len(o.Entries))
+	for key := range o.Entries {
+		liveView[key], _ = o.Get(key)
+	}
+
+	return liveView
+}
+
+// Delta returns an ORSet that only contains the state of the items with
+// the given keys, including the removed tags of these keys this replica
+// has seen the entries of. The optional project function is applied to
+// every item of the delta
+func (o *ORSet[K, V]) Delta(keys []K, project func(item V) V) Set[K, V] {
+	delta := NewORSet[K, V](o.identifier)
+	delta.tags = o.tags
+
+	deltaKeys := make(map[K]struct{}, len(keys))
+	for _, key := range keys {
+		deltaKeys[key] = struct{}{}
+	}
+	for tag, key := range o.removedKeys {
+		if _, ok := deltaKeys[key]; ok {
+			delta.RemovedTags[tag] = struct{}{}
+			delta.removedKeys[tag] = key
+		}
+	}
+
+	for _, key := range keys {
+		taggedItems, ok := o.Entries[key]
+		if !ok {
+			continue
+		}
+
+		deltaTaggedItems := make(TaggedItems[V], len(taggedItems))
+		for tag, item := range taggedItems {
+			deltaTaggedItems[tag] = projectItem(item, project)
+		}
+		delta.Entries[key] = deltaTaggedItems
+	}
+
+	return &delta
+}
+
+func (o *ORSet[K, V]) Identifier() interface{} {
+	return o.identifier
+}
+
+func (o *ORSet[K, V]) Merge(other Mergeable) (Mergeable, error) {
+	if o.Identifier() != other.Identifier() {
+		err := NewCannotBeMergedError(o, other)
+		return nil, err
+	}
+
+	otherORSet, ok := other.(*ORSet[K, V])
+	if !ok {
+		err := NewTypeMisMatchError(o, other)
+		return nil, err
+	}
+
+	return o.MergeORSet(otherORSet)
+}
+
+// MergeORSet is the type-safe variant of Merge for two ORSets holding
+// the same item type
+func (o *ORSet[K, V]) MergeORSet(other *ORSet[K, V]) (*ORSet[K, V], error) {
+	if o.Identifier() != other.Identifier() {
+		err := NewCannotBeMergedError(o, other)
+		return nil, err
+	}
+
+	mergedRemovedTags := make(TagSet, len(o.RemovedTags)+len(other.RemovedTags))
+	for tag := range o.RemovedTags {
+		mergedRemovedTags[tag] = struct{}{}
+	}
+	for tag := range other.RemovedTags {
+		mergedRemovedTags[tag] = struct{}{}
+	}
+
+	keys := make(map[K]struct{}, len(o.Entries))
+	for key := range o.Entries {
+		keys[key] = struct{}{}
+	}
+	for key := range other.Entries {
+		keys[key] = struct{}{}
+	}
+
+	mergedRemovedKeys := make(map[uuid.UUID]K, len(o.removedKeys)+len(other.removedKeys))
+	for _, removedKeys := range []map[uuid.UUID]K{o.removedKeys, other.removedKeys} {
+		for tag, key := range removedKeys {
+			mergedRemovedKeys[tag] = key
+		}
+	}
+
+	mergedEntries := make(map[K]TaggedItems[V], len(keys))
+	for key := range keys {
+		for _, taggedItems := range []TaggedItems[V]{o.Entries[key], other.Entries[key]} {
+			for tag := range taggedItems {
+				if _, ok := mergedRemovedTags[tag]; ok {
+					mergedRemovedKeys[tag] = key
+				}
+			}
+		}
+
+		mergedTaggedItems, err := mergeTaggedItems(mergedRemovedTags, o.Entries[key], other.Entries[key])
+		if err != nil {
+			return nil, err
+		}
+
+		if len(mergedTaggedItems) > 0 {
+			mergedEntries[key] = mergedTaggedItems
+		}
+	}
+
+	mergedORSet := ORSet[K, V]{
+		Entries:     mergedEntries,
+		RemovedTags: mergedRemovedTags,
+		identifier:  o.identifier,
+		tags:        o.tags,
+		removedKeys: mergedRemovedKeys,
+	}
+	return &mergedORSet, nil
+}
+
+// mergeTaggedItems merges the payloads of the tags that are part of
+// both TaggedItems and keeps all the other tags that have not been
+// removed. Payloads of different tags are kept apart and only merged by
+// Get, otherwise the payload of a removed tag would leak into the
+// remaining ones depending on the order of the merges
+func mergeTaggedItems[V Mergeable](removedTags TagSet, this, other TaggedItems[V]) (TaggedItems[V], error) {
+	mergedTaggedItems := TaggedItems[V]{}
+	for _, taggedItems := range []TaggedItems[V]{this, other} {
+		for tag, item := range taggedItems {
+			if _, ok := removedTags[tag]; ok {
+				continue
+			}
+
+			if mergedItem, ok := mergedTaggedItems[tag]; ok {
+				var err error
+				item, err = mergeItems(mergedItem, item)
+				if err != nil {
+					return nil, err
+				}
+			}
+			mergedTaggedItems[tag] = item
+		}
+	}
+
+	return mergedTaggedItems, nil
+}
+
+func sortedTags[V any](taggedItems TaggedItems[V]) []uuid.UUID {
+	tags := make([]uuid.UUID, 0, len(taggedItems))
+	for tag := range taggedItems {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return bytes.Compare(tags[i][:], tags[j][:]) < 0 })
+
+	return tags
+}
@@ -0,0 +1,204 @@
+package crdt
+
+import (
+	"testing"
+
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/google/uuid"
+)
+
+type testORSet = ORSet[string, *testMergeable]
+
+func newTestORSet(identifier string) testORSet {
+	return NewORSet[string, *testMergeable](identifier)
+}
+
+func TestORSetAdd(t *testing.T) {
+	orset := newTestORSet(psetID0)
+
+	err := orset.Add(&mergeable0)
+	AssertEquals(t, nil, err, "orset.Add error")
+
+	orset.Add(&mergeable0)
+	orset.Add(&mergeable1)
+
+	mergedMergeable := mergeable0
+	mergedMergeable.value = 2
+	expected := testItemMap{
+		mergeableID0: &mergedMergeable,
+		mergeableID1: &mergeable1,
+	}
+	AssertEquals(t, expected, orset.LiveView(), "orset.LiveView")
+	AssertEquals(t, 1, len(orset.Entries[mergeableID0]), "len(orset.Entries)")
+}
+
+type testTagGenerator struct {
+	count byte
+}
+
+func (g *testTagGenerator) NewID() (uuid.UUID, error) {
+	g.count++
+	return uuid.UUID{15: g.count}, nil
+}
+
+func TestORSetAddWithTagGenerator(t *testing.T) {
+	orset := newTestORSet(psetID0)
+	orset.SetTagGenerator(&testTagGenerator{})
+	orset.Add(&mergeable0)
+	orset.Remove(&mergeable0)
+	orset.Add(&mergeable0)
+
+	expected := map[string]TaggedItems[*testMergeable]{
+		mergeableID0: {uuid.UUID{15: 2}: &mergeable0},
+	}
+	AssertEquals(t, expected, orset.Entries, "orset.Entries")
+	AssertEquals(t, TagSet{uuid.UUID{15: 1}: {}}, orset.RemovedTags, "orset.RemovedTags")
+
+	// Merged sets keep tagging with the same TagGenerator
+	merged, _ := orset.MergeORSet(&orset)
+	merged.Add(&mergeable1)
+	_, ok := merged.Entries[mergeableID1][uuid.UUID{15: 3}]
+	AssertEquals(t, true, ok, "merged tag")
+}
+
+func TestORSetInvalidAdd(t *testing.T) {
+	orset := newTestORSet(psetID0)
+	orset.Add(&mergeable0)
+
+	err := orset.Add(&invalidMergeable)
+	expectedErr := NewCannotBeMergedError(&mergeable0, &invalidMergeable)
+	AssertEquals(t, expectedErr, err, "orset.Add error")
+}
+
+func TestORSetRemove(t *testing.T) {
+	orset := newTestORSet(psetID0)
+	orset.Add(&mergeable0)
+	orset.Add(&mergeable1)
+
+	orset.Remove(&mergeable0)
+
+	expected := testItemMap{
+		mergeableID1: &mergeable1,
+	}
+	AssertEquals(t, expected, orset.LiveView(), "orset.LiveView")
+	AssertEquals(t, 1, len(orset.RemovedTags), "len(orset.RemovedTags)")
+
+	_, ok := orset.Get(mergeableID0)
+	AssertEquals(t, false, ok, "orset.Get ok")
+}
+
+func TestORSetReAdd(t *testing.T) {
+	orset := newTestORSet(psetID0)
+	orset.Add(&mergeable0)
+	orset.Remove(&mergeable0)
+
+	reAdded := mergeable0
+	reAdded.value = 5
+	err := orset.Add(&reAdded)
+	AssertEquals(t, nil, err, "orset.Add error")
+
+	item, ok := orset.Get(mergeableID0)
+	AssertEquals(t, true, ok, "orset.Get ok")
+	AssertEquals(t, &reAdded, item, "orset.Get item")
+}
+
+func TestORSetMerge(t *testing.T) {
+	orset0 := newTestORSet(psetID0)
+	orset0.Add(&mergeable0)
+	orset0.Add(&mergeable1)
+
+	merged, _ := orset0.MergeORSet(&orset0)
+	orset1 := *merged
+
+	// Concurrently remove and re-add mergeable0 on orset0 while orset1
+	// removes it
+	orset0.Remove(&mergeable0)
+	orset0.Add(&mergeable0)
+	orset1.Remove(&mergeable0)
+	orset1.Remove(&mergeable1)
+
+	mergedORSet, err := orset0.Merge(&orset1)
+	AssertEquals(t, nil, err, "orset0.Merge error")
+
+	expected := testItemMap{
+		mergeableID0: &mergeable0,
+	}
+	AssertEquals(t, expected, mergedORSet.(*testORSet).LiveView(), "mergedORSet.LiveView")
+}
+
+func TestORSetMergeConcurrentAdds(t *testing.T) {
+	orset0 := newTestORSet(psetID0)
+	orset0.Add(&mergeable0)
+
+	orset1 := newTestORSet(psetID0)
+	orset1.Add(&mergeable0)
+
+	mergedORSet, err := orset0.MergeORSet(&orset1)
+	AssertEquals(t, nil, err, "orset0.MergeORSet error")
+	AssertEquals(t, 2, len(mergedORSet.Entries[mergeableID0]), "len(mergedORSet.Entries)")
+
+	mergedMergeable := mergeable0
+	mergedMergeable.value = 2
+	item, _ := mergedORSet.Get(mergeableID0)
+	AssertEquals(t, &mergedMergeable, item, "mergedORSet.Get item")
+}
+
+func TestORSetInvalidIdentifierMerge(t *testing.T) {
+	orset0 := newTestORSet(psetID0)
+	orset1 := newTestORSet(psetID1)
+
+	_, err := orset0.Merge(&orset1)
+
+	expected := NewCannotBeMergedError(&orset0, &orset1)
+	AssertEquals(t, expected, err, "orset0.Merge error")
+}
+
+func TestMergeSetsTypeMisMatch(t *testing.T) {
+	pset := newTestPSet(psetID0)
+	orset := newTestORSet(psetID0)
+
+	_, err := MergeSets[string, *testMergeable](&pset, &orset)
+
+	expected := NewTypeMisMatchError(&pset, &orset)
+	AssertEquals(t, expected, err, "MergeSets error")
+}
+
+func TestORSetDelta(t *testing.T) {
+	orset := newTestORSet(psetID0)
+	orset.Add(&mergeable0)
+	orset.Add(&mergeable1)
+
+	replica := orset.Delta([]string{mergeableID0, mergeableID1}, nil).(*testORSet)
+
+	orset.Remove(&mergeable0)
+	delta := orset.Delta([]string{mergeableID0}, nil).(*testORSet)
+	AssertEquals(t, 0, len(delta.Entries), "len(delta.Entries)")
+
+	merged, err := replica.MergeORSet(delta)
+	AssertEquals(t, nil, err, "replica.MergeORSet error")
+
+	expected := testItemMap{
+		mergeableID1: &mergeable1,
+	}
+	AssertEquals(t, expected, merged.LiveView(), "merged.LiveView")
+}
+
+func TestORSetDeltaOnlyContainsRemovedTagsOfKeys(t *testing.T) {
+	orset := newTestORSet(psetID0)
+	orset.Add(&mergeable0)
+	orset.Add(&mergeable1)
+	tag0 := sortedTags(orset.Entries[mergeableID0])[0]
+
+	replica := orset.Delta([]string{mergeableID0, mergeableID1}, nil).(*testORSet)
+	replica.Remove(&mergeable1)
+	merged, _ := orset.MergeORSet(replica)
+	merged.Remove(&mergeable0)
+
+	delta := merged.Delta([]string{mergeableID0}, nil).(*testORSet)
+	AssertEquals(t, TagSet{tag0: {}}, delta.RemovedTags, "delta.RemovedTags")
+
+	delta = merged.Delta([]string{mergeableID1}, nil).(*testORSet)
+	AssertEquals(t, 1, len(delta.RemovedTags), "len(delta.RemovedTags)")
+	_, ok := delta.RemovedTags[tag0]
+	AssertEquals(t, false, ok, "delta contains the removed tag of another key")
+}
@@ -0,0 +1,132 @@
+package crdt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// positionDigits are the digits of the positions in ascending order
+const positionDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// PositionBetween returns a position that sorts lexicographically
+// between the given ones. Positions are fractional indices with an
+// arbitrary number of base-62 digits after the radix point, so there is
+// always room for another position between two distinct ones.
+//
+// An empty before marks the start and an empty after the end of the
+// sequence. Concurrent inserts at the same place result in equal
+// positions, which is why users of positions need to break ties with
+// another unique value like an ID.
+func PositionBetween(before, after string) (string, error) {
+	if !isValidPosition(before) || !isValidPosition(after) {
+		return "", NewInvalidPositionError(before, after)
+	}
+
+	switch {
+	case after == "":
+		return positionAfter(before), nil
+	case before >= after:
+		return "", NewInvalidPositionError(before, after)
+	default:
+		return positionMidpoint(before, after), nil
+	}
+}
+
+// isValidPosition reports whether the position only consists of valid
+// digits and does not end with a zero which would prevent inserting
+// positions right before it
+func isValidPosition(position string) bool {
+	for _, digit := range position {
+		if !strings.ContainsRune(positionDigits, digit) {
+			return false
+		}
+	}
+
+	return !strings.HasSuffix(position, positionDigits[:1])
+}
+
+// positionAfter increments the first digit of the position that is not
+// the greatest one or appends a digit, so appending positions only grows
+// them by one digit every 61 positions
+func positionAfter(position string) string {
+	for i := 0; i < len(position); i++ {
+		digit := strings.IndexByte(positionDigits, position[i])
+		if digit < len(positionDigits)-1 {
+			return position[:i] + string(positionDigits[digit+1])
+		}
+	}
+
+	return position + string(positionDigits[1])
+}
+
+// positionMidpoint returns the position in the middle of before and
+// after. The before position may be empty to mark the start and the
+// after position to mark the end of the sequence
+func positionMidpoint(before, after string) string {
+	if after != "" {
+		n := 0
+		for n < len(after) && positionDigit(before, n) == after[n] {
+			n++
+		}
+		if n > 0 {
+			return after[:n] + positionMidpoint(positionSuffix(before, n), after[n:])
+		}
+	}
+
+	digitBefore := 0
+	if before != "" {
+		digitBefore = strings.IndexByte(positionDigits, before[0])
+	}
+	digitAfter := len(positionDigits)
+	if after != "" {
+		digitAfter = strings.IndexByte(positionDigits, after[0])
+	}
+
+	if digitAfter-digitBefore > 1 {
+		return string(positionDigits[(digitBefore+digitAfter+1)/2])
+	}
+
+	if len(after) > 1 {
+		return after[:1]
+	}
+
+	return string(positionDigits[digitBefore]) + positionMidpoint(positionSuffix(before, 1), "")
+}
+
+// positionDigit returns the digit at the given index of the position
+// padded with zeros
+func positionDigit(position string, i int) byte {
+	if i < len(position) {
+		return position[i]
+	}
+
+	return positionDigits[0]
+}
+
+func positionSuffix(position string, i int) string {
+	if i < len(position) {
+		return position[i:]
+	}
+
+	return ""
+}
+
+// InvalidPositionError indicates that no position can be created
+// between the given positions
+type InvalidPositionError struct {
+	Before  string
+	After   string
+	message string
+}
+
+func NewInvalidPositionError(before, after string) *InvalidPositionError {
+	return &InvalidPositionError{
+		Before:  before,
+		After:   after,
+		message: fmt.Sprintf("no position can be created between '%s' and '%s'", before, after),
+	}
+}
+
+func (e *InvalidPositionError) Error() string {
+	return e.message
+}
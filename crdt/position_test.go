@@ -0,0 +1,91 @@
+package crdt
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	. "github.com/eldelto/solvent/internal/testutils"
+)
+
+func TestPositionBetween(t *testing.T) {
+	tests := []struct {
+		before   string
+		after    string
+		expected string
+	}{
+		{"", "", "1"},
+		{"1", "", "2"},
+		{"z", "", "z1"},
+		{"", "1", "0V"},
+		{"1", "3", "2"},
+		{"1", "2", "1V"},
+		{"1", "11", "10V"},
+		{"V", "W", "VV"},
+		{"VV", "W", "Vl"},
+	}
+
+	for _, test := range tests {
+		position, err := PositionBetween(test.before, test.after)
+		AssertEquals(t, nil, err, "PositionBetween error")
+		AssertEquals(t, test.expected, position, "PositionBetween("+test.before+", "+test.after+")")
+	}
+}
+
+func TestInvalidPositionBetween(t *testing.T) {
+	tests := []struct {
+		before string
+		after  string
+	}{
+		{"2", "1"},
+		{"1", "1"},
+		{"10", ""},
+		{"", "1+"},
+	}
+
+	for _, test := range tests {
+		_, err := PositionBetween(test.before, test.after)
+		AssertEquals(t, NewInvalidPositionError(test.before, test.after), err, "PositionBetween error")
+	}
+}
+
+func TestRandomPositionInserts(t *testing.T) {
+	random := rand.New(rand.NewSource(0))
+	positions := []string{}
+
+	for i := 0; i < 1000; i++ {
+		index := random.Intn(len(positions) + 1)
+		before, after := "", ""
+		if index > 0 {
+			before = positions[index-1]
+		}
+		if index < len(positions) {
+			after = positions[index]
+		}
+
+		position, err := PositionBetween(before, after)
+		if err != nil {
+			t.Fatalf("PositionBetween(%s, %s) error: %v", before, after, err)
+		}
+		if position <= before || (after != "" && position >= after) {
+			t.Fatalf("position '%s' is not between '%s' and '%s'", position, before, after)
+		}
+
+		positions = append(positions[:index], append([]string{position}, positions[index:]...)...)
+	}
+
+	AssertEquals(t, true, sort.StringsAreSorted(positions), "sort.StringsAreSorted")
+}
+
+func TestRepeatedPositionInserts(t *testing.T) {
+	before, after := "1", "2"
+	for i := 0; i < 200; i++ {
+		position, err := PositionBetween(before, after)
+		if err != nil {
+			t.Fatalf("PositionBetween(%s, %s) error: %v", before, after, err)
+		}
+		after = position
+	}
+
+	AssertEquals(t, true, len(after) < 50, "len(position)")
+}
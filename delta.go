@@ -0,0 +1,162 @@
+package solvent
+
+import (
+	"github.com/google/uuid"
+)
+
+// changeSet records the IDs of the ToDoLists and ToDoItems that have
+// been changed locally since the last delta has been reset
+type changeSet struct {
+	lists map[uuid.UUID]map[uuid.UUID]struct{}
+}
+
+func newChangeSet() *changeSet {
+	return &changeSet{
+		lists: map[uuid.UUID]map[uuid.UUID]struct{}{},
+	}
+}
+
+func (c *changeSet) recordList(listID uuid.UUID) map[uuid.UUID]struct{} {
+	items, ok := c.lists[listID]
+	if !ok {
+		items = map[uuid.UUID]struct{}{}
+		c.lists[listID] = items
+	}
+
+	return items
+}
+
+func (c *changeSet) recordItem(listID, itemID uuid.UUID) {
+	c.recordList(listID)[itemID] = struct{}{}
+}
+
+func (e *environment) recordList(listID uuid.UUID) {
+	if e.changes != nil {
+		e.changes.recordList(listID)
+	}
+}
+
+func (e *environment) recordItem(listID, itemID uuid.UUID) {
+	if e.changes != nil {
+		e.changes.recordItem(listID, itemID)
+	}
+}
+
+// trackChanges starts recording the local changes of the Notebook and
+// of all the ToDoLists retrieved from it afterwards
+func (n *Notebook) trackChanges() {
+	if n.changes == nil {
+		n.changes = newChangeSet()
+	}
+}
+
+// Delta returns a Notebook that only contains the ToDoLists and
+// ToDoItems that have been changed locally since the last call to
+// ResetDelta. Merging the delta into another replica of the Notebook
+// applies all these changes
+func (n *Notebook) Delta() *Notebook {
+	n.trackChanges()
+
+	listIDs := make([]uuid.UUID, 0, len(n.changes.lists))
+	for listID := range n.changes.lists {
+		listIDs = append(listIDs, listID)
+	}
+
+	delta := Notebook{
+		ID:        n.ID,
+		CreatedAt: n.CreatedAt,
+	}
+	delta.ToDoLists = n.ToDoLists.Delta(listIDs, func(list *ToDoList) *ToDoList {
+		itemIDs := make([]uuid.UUID, 0, len(n.changes.lists[list.ID]))
+		for itemID := range n.changes.lists[list.ID] {
+			itemIDs = append(itemIDs, itemID)
+		}
+
+		return &ToDoList{
+			ID:         list.ID,
+			Title:      list.Title,
+			ToDoItems:  list.ToDoItems.Delta(itemIDs, nil),
+			OrderValue: list.OrderValue,
+			CreatedAt:  list.CreatedAt,
+		}
+	})
+
+	return &delta
+}
+
+// ResetDelta forgets all the recorded local changes, usually after the
+// delta has been acknowledged by the server
+func (n *Notebook) ResetDelta() {
+	n.trackChanges()
+
+	for listID := range n.changes.lists {
+		delete(n.changes.lists, listID)
+	}
+}
+
+// DeltaSince returns a Notebook that only contains the ToDoLists and
+// ToDoItems that have been added, changed or removed compared to the
+// given older state of the Notebook and reports whether there have been
+// any changes at all
+func (n *Notebook) DeltaSince(old *Notebook) (*Notebook, bool) {
+	oldLists := old.ToDoLists.LiveView()
+	newLists := n.ToDoLists.LiveView()
+
+	changedItems := map[uuid.UUID][]uuid.UUID{}
+	for id, list := range newLists {
+		oldList, ok := oldLists[id]
+		if !ok {
+			changedItems[id] = changedKeys(ToDoItemMap{}, list.ToDoItems.LiveView())
+			continue
+		}
+
+		itemIDs := changedKeys(oldList.ToDoItems.LiveView(), list.ToDoItems.LiveView())
+		if len(itemIDs) > 0 || list.Title != oldList.Title || list.OrderValue != oldList.OrderValue {
+			changedItems[id] = itemIDs
+		}
+	}
+	for id := range oldLists {
+		if _, ok := newLists[id]; !ok {
+			changedItems[id] = []uuid.UUID{}
+		}
+	}
+
+	listIDs := make([]uuid.UUID, 0, len(changedItems))
+	for id := range changedItems {
+		listIDs = append(listIDs, id)
+	}
+
+	delta := Notebook{
+		ID:        n.ID,
+		CreatedAt: n.CreatedAt,
+	}
+	delta.ToDoLists = n.ToDoLists.Delta(listIDs, func(list *ToDoList) *ToDoList {
+		return &ToDoList{
+			ID:         list.ID,
+			Title:      list.Title,
+			ToDoItems:  list.ToDoItems.Delta(changedItems[list.ID], nil),
+			OrderValue: list.OrderValue,
+			CreatedAt:  list.CreatedAt,
+		}
+	})
+
+	return &delta, len(listIDs) > 0
+}
+
+// changedKeys returns the keys of the ToDoItems that have been added,
+// changed or removed in newItems compared to oldItems
+func changedKeys(oldItems, newItems ToDoItemMap) []uuid.UUID {
+	keys := []uuid.UUID{}
+	for id, item := range newItems {
+		if oldItem, ok := oldItems[id]; !ok || *oldItem != *item {
+			keys = append(keys, id)
+		}
+	}
+	for id := range oldItems {
+		if _, ok := newItems[id]; !ok {
+			keys = append(keys, id)
+		}
+	}
+
+	return keys
+}
@@ -0,0 +1,101 @@
+package solvent
+
+import (
+	"testing"
+
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/google/uuid"
+)
+
+func TestDelta(t *testing.T) {
+	notebook0, _ := NewNotebook()
+	list0, _ := notebook0.AddList(listTitle0)
+	itemID0, _ := list0.AddItem(itemTitle0)
+	list0.AddItem(itemTitle1)
+
+	merged, _ := notebook0.Merge(notebook0)
+	notebook1 := merged.(*Notebook)
+	notebook1.changes = nil
+	notebook0.ResetDelta()
+
+	list0, _ = notebook0.GetList(list0.ID)
+	list0.CheckItem(itemID0)
+	list1, _ := notebook0.AddList(listTitle1)
+
+	delta := notebook0.Delta()
+	AssertEquals(t, 2, len(delta.ToDoLists.LiveView()), "len(delta.ToDoLists)")
+	deltaList0, _ := delta.GetList(list0.ID)
+	AssertEquals(t, []uuid.UUID{itemID0}, itemIDs(deltaList0.GetItems()), "deltaList0.GetItems")
+
+	merged, err := notebook1.Merge(delta)
+	AssertEquals(t, nil, err, "notebook1.Merge error")
+	notebook1 = merged.(*Notebook)
+
+	mergedList0, _ := notebook1.GetList(list0.ID)
+	item0, _ := mergedList0.GetItem(itemID0)
+	AssertEquals(t, true, item0.Checked.Value, "item0.Checked")
+	AssertEquals(t, 2, len(mergedList0.GetItems()), "len(mergedList0.GetItems)")
+	_, err = notebook1.GetList(list1.ID)
+	AssertEquals(t, nil, err, "notebook1.GetList error")
+
+	notebook0.ResetDelta()
+	AssertEquals(t, 0, len(notebook0.Delta().ToDoLists.LiveView()), "len(delta.ToDoLists)")
+}
+
+func TestDeltaRemovals(t *testing.T) {
+	notebook0, _ := NewNotebook()
+	list0, _ := notebook0.AddList(listTitle0)
+	list1, _ := notebook0.AddList(listTitle1)
+	itemID0, _ := list0.AddItem(itemTitle0)
+
+	merged, _ := notebook0.Merge(notebook0)
+	notebook1 := merged.(*Notebook)
+	notebook0.ResetDelta()
+
+	list0, _ = notebook0.GetList(list0.ID)
+	list0.RemoveItem(itemID0)
+	notebook0.RemoveList(list1.ID)
+
+	merged, _ = notebook1.Merge(notebook0.Delta())
+	notebook1 = merged.(*Notebook)
+
+	AssertEquals(t, []uuid.UUID{list0.ID}, listIDs(notebook1.GetLists()), "notebook1.GetLists")
+	mergedList0, _ := notebook1.GetList(list0.ID)
+	AssertEquals(t, []ToDoItem{}, mergedList0.GetItems(), "mergedList0.GetItems")
+}
+
+func TestDeltaSince(t *testing.T) {
+	notebook, _ := NewNotebook()
+	list0, _ := notebook.AddList(listTitle0)
+	list1, _ := notebook.AddList(listTitle1)
+	itemID0, _ := list0.AddItem(itemTitle0)
+	list0.AddItem(itemTitle1)
+
+	merged, _ := notebook.Merge(notebook)
+	old := merged.(*Notebook)
+
+	_, changed := notebook.DeltaSince(old)
+	AssertEquals(t, false, changed, "changed")
+
+	list0.RenameItem(itemID0, itemTitle2)
+	notebook.RemoveList(list1.ID)
+
+	delta, changed := notebook.DeltaSince(old)
+	AssertEquals(t, true, changed, "changed")
+	AssertEquals(t, []uuid.UUID{list1.ID}, delta.Tombstones(), "delta.Tombstones")
+
+	deltaList0, _ := delta.GetList(list0.ID)
+	AssertEquals(t, []uuid.UUID{itemID0}, itemIDs(deltaList0.GetItems()), "deltaList0.GetItems")
+
+	merged, _ = old.Merge(delta)
+	AssertEquals(t, listIDs(notebook.GetLists()), listIDs(merged.(*Notebook).GetLists()), "merged.GetLists")
+}
+
+func listIDs(lists []*ToDoList) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(lists))
+	for _, list := range lists {
+		ids = append(ids, list.ID)
+	}
+
+	return ids
+}
@@ -0,0 +1,117 @@
+package testutils
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// Operation applies a random update to the state of the given replica
+// and returns the updated state
+type Operation[T any] func(random *rand.Rand, replica int, state T) (T, error)
+
+// Replicated describes a CRDT of type T for AssertConvergence
+type Replicated[T any] struct {
+	// New returns the initial state of the given replica. All replicas
+	// have to start with a state of the same CRDT, e.g. the same ID
+	New func(replica int) T
+	// Operations are the updates the replicas randomly choose from
+	Operations []Operation[T]
+	// Merge merges two states without modifying them
+	Merge func(this, other T) (T, error)
+	// Copy returns a deep copy of the state so replicas never share
+	// any mutable data
+	Copy func(state T) T
+	// View returns the observable state that has to be equal for
+	// converged replicas
+	View func(state T) interface{}
+	// Replicas is the number of simulated replicas, defaults to 3
+	Replicas int
+	// Steps is the number of operations and merges, defaults to 100
+	Steps int
+}
+
+// AssertConvergence simulates a random history of operations and merges
+// across multiple replicas of a CRDT that is fully determined by the
+// given seed. It asserts that Merge is idempotent, commutative and
+// associative for randomly chosen states of the history and that all
+// replicas converge to the same View once they have merged each other's
+// states, regardless of the order of the merges
+func AssertConvergence[T any](t *testing.T, seed int64, r Replicated[T]) {
+	t.Helper()
+	if r.Replicas <= 0 {
+		r.Replicas = 3
+	}
+	if r.Steps <= 0 {
+		r.Steps = 100
+	}
+
+	random := rand.New(rand.NewSource(seed))
+	merge := func(this, other T) T {
+		t.Helper()
+		merged, err := r.Merge(r.Copy(this), r.Copy(other))
+		if err != nil {
+			t.Fatalf("seed %d: Merge error: %v", seed, err)
+		}
+
+		return merged
+	}
+
+	replicas := make([]T, r.Replicas)
+	for i := range replicas {
+		replicas[i] = r.New(i)
+	}
+
+	history := make([]T, 0, r.Steps)
+	for step := 0; step < r.Steps; step++ {
+		i := random.Intn(len(replicas))
+		if random.Intn(4) == 0 {
+			replicas[i] = merge(replicas[i], replicas[random.Intn(len(replicas))])
+		} else {
+			operation := r.Operations[random.Intn(len(r.Operations))]
+			state, err := operation(random, i, r.Copy(replicas[i]))
+			if err != nil {
+				t.Fatalf("seed %d: operation error in step %d: %v", seed, step, err)
+			}
+			replicas[i] = state
+		}
+
+		history = append(history, r.Copy(replicas[i]))
+	}
+
+	assertView := func(law string, expected, actual T) {
+		t.Helper()
+		expectedView, actualView := r.View(expected), r.View(actual)
+		if !reflect.DeepEqual(expectedView, actualView) {
+			t.Fatalf("seed %d: Merge is not %s: '%v' != '%v'", seed, law, expectedView, actualView)
+		}
+	}
+
+	for i := 0; i < len(history); i++ {
+		a := history[random.Intn(len(history))]
+		b := history[random.Intn(len(history))]
+		c := history[random.Intn(len(history))]
+
+		assertView("idempotent", a, merge(a, a))
+		assertView("commutative", merge(a, b), merge(b, a))
+		assertView("associative", merge(merge(a, b), c), merge(a, merge(b, c)))
+	}
+
+	var converged T
+	for round := 0; round < 2; round++ {
+		order := random.Perm(len(replicas))
+		state := replicas[order[0]]
+		for _, i := range order[1:] {
+			state = merge(state, replicas[i])
+		}
+
+		if round == 0 {
+			converged = state
+		} else {
+			assertView("order independent", converged, state)
+		}
+	}
+	for _, replica := range replicas {
+		assertView("convergent", converged, merge(replica, converged))
+	}
+}
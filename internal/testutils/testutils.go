@@ -37,6 +37,7 @@ type Response struct {
 	response   *http.Response
 	T          *testing.T
 	StatusCode int
+	Header     http.Header
 	mapBody    map[string]interface{}
 }
 
@@ -45,6 +46,7 @@ func NewResponse(t *testing.T, response *http.Response) Response {
 		response:   response,
 		T:          t,
 		StatusCode: response.StatusCode,
+		Header:     response.Header,
 		mapBody:    map[string]interface{}{},
 	}
 }
@@ -72,6 +74,8 @@ type TestServer struct {
 	*httptest.Server
 	T      *testing.T
 	Client *http.Client
+	// Headers are sent with every request
+	Headers map[string]string
 }
 
 func NewTestServer(t *testing.T, handler http.Handler) *TestServer {
@@ -87,6 +91,11 @@ func (ts *TestServer) GET(path string) Response {
 	return ts.request("GET", path, "")
 }
 
+// GETWithHeaders sends a GET request with the given additional headers
+func (ts *TestServer) GETWithHeaders(path string, headers map[string]string) Response {
+	return ts.requestWithHeaders("GET", path, "", headers)
+}
+
 func (ts *TestServer) POST(path string, body string) Response {
 	return ts.request("POST", path, body)
 }
@@ -95,11 +104,19 @@ func (ts *TestServer) PUT(path string, body string) Response {
 	return ts.request("PUT", path, body)
 }
 
+func (ts *TestServer) PATCH(path string, body string) Response {
+	return ts.request("PATCH", path, body)
+}
+
 func (ts *TestServer) DELETE(path string) Response {
 	return ts.request("DELETE", path, "")
 }
 
 func (ts *TestServer) request(verb, path string, body string) Response {
+	return ts.requestWithHeaders(verb, path, body, nil)
+}
+
+func (ts *TestServer) requestWithHeaders(verb, path string, body string, headers map[string]string) Response {
 	url := ts.URL + path
 	bodyData := bytes.NewBufferString(body)
 
@@ -109,6 +126,12 @@ func (ts *TestServer) request(verb, path string, body string) Response {
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	for key, value := range ts.Headers {
+		req.Header.Set(key, value)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
 
 	response, err := ts.Client.Do(req)
 	if err != nil {
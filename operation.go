@@ -0,0 +1,273 @@
+package solvent
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// OperationKind identifies the change an OperationRecord makes to a
+// Notebook
+type OperationKind string
+
+const (
+	AddListOperation     OperationKind = "addList"
+	RemoveListOperation  OperationKind = "removeList"
+	RestoreListOperation OperationKind = "restoreList"
+	RenameListOperation  OperationKind = "renameList"
+	MoveListOperation    OperationKind = "moveList"
+	AddItemOperation     OperationKind = "addItem"
+	RemoveItemOperation  OperationKind = "removeItem"
+	RestoreItemOperation OperationKind = "restoreItem"
+	RenameItemOperation  OperationKind = "renameItem"
+	CheckItemOperation   OperationKind = "checkItem"
+	MoveItemOperation    OperationKind = "moveItem"
+)
+
+// OperationRecord records a single change of a Notebook together with
+// everything that is needed to revert it again. Only the fields that
+// belong to its kind are set
+type OperationRecord struct {
+	Kind   OperationKind
+	ListID uuid.UUID
+	ItemID uuid.UUID
+	// Title is the title of an added or renamed list or item and
+	// OldTitle the one it had before being renamed
+	Title    string
+	OldTitle string
+	// Checked is the checked state an item has been set to and
+	// OldChecked the one it had before
+	Checked    bool
+	OldChecked bool
+	// Index is the index a list or item has been moved to and OldIndex
+	// the one it had before
+	Index    int
+	OldIndex int
+	// List is the removed list that gets restored
+	List *ToDoList
+	// Item is the removed item that gets restored
+	Item *ToDoItem
+}
+
+// Inverse returns the OperationRecord that reverts the given one once it
+// has been applied
+func (o OperationRecord) Inverse() OperationRecord {
+	switch o.Kind {
+	case AddListOperation, RestoreListOperation:
+		return OperationRecord{Kind: RemoveListOperation, ListID: o.ListID}
+	case RemoveListOperation:
+		return OperationRecord{Kind: RestoreListOperation, ListID: o.ListID, List: o.List}
+	case AddItemOperation, RestoreItemOperation:
+		return OperationRecord{Kind: RemoveItemOperation, ListID: o.ListID, ItemID: o.ItemID}
+	case RemoveItemOperation:
+		return OperationRecord{Kind: RestoreItemOperation, ListID: o.ListID, ItemID: o.ItemID, Item: o.Item}
+	}
+
+	inverse := o
+	inverse.Title, inverse.OldTitle = o.OldTitle, o.Title
+	inverse.Checked, inverse.OldChecked = o.OldChecked, o.Checked
+	inverse.Index, inverse.OldIndex = o.OldIndex, o.Index
+
+	return inverse
+}
+
+// ApplyOperation applies the given OperationRecord to the Notebook and
+// returns it as it has been applied, which includes the IDs of added
+// lists and items as well as the previous values needed to revert it.
+// Removed lists and items that can not be restored with their original
+// IDs, as they are backed by 2P-Sets, are re-created under new IDs
+func (n *Notebook) ApplyOperation(operation OperationRecord) (OperationRecord, error) {
+	switch operation.Kind {
+	case AddListOperation:
+		list, err := n.AddList(operation.Title)
+		if err != nil {
+			return OperationRecord{}, err
+		}
+		operation.ListID = list.ID
+
+		return operation, nil
+	case RemoveListOperation:
+		list, err := n.GetList(operation.ListID)
+		if err != nil {
+			return OperationRecord{}, err
+		}
+		operation.List, err = copyList(list)
+		if err != nil {
+			return OperationRecord{}, err
+		}
+		n.RemoveList(list.ID)
+
+		return operation, nil
+	case RestoreListOperation:
+		return n.restoreList(operation)
+	case MoveListOperation:
+		operation.OldIndex = listIndex(n.GetLists(), operation.ListID)
+		if err := n.MoveList(operation.ListID, operation.Index); err != nil {
+			return OperationRecord{}, err
+		}
+		operation.Index = listIndex(n.GetLists(), operation.ListID)
+
+		return operation, nil
+	}
+
+	list, err := n.GetList(operation.ListID)
+	if err != nil {
+		return OperationRecord{}, err
+	}
+
+	switch operation.Kind {
+	case RenameListOperation:
+		operation.OldTitle = list.Title.Value
+		_, err = list.Rename(operation.Title)
+	case AddItemOperation:
+		operation.ItemID, err = list.AddItem(operation.Title)
+	case RemoveItemOperation:
+		var item ToDoItem
+		if item, err = list.GetItem(operation.ItemID); err == nil {
+			operation.Item = &item
+			list.RemoveItem(item.ID)
+		}
+	case RestoreItemOperation:
+		return restoreItem(list, operation)
+	case RenameItemOperation:
+		var item ToDoItem
+		if item, err = list.GetItem(operation.ItemID); err == nil {
+			operation.OldTitle = item.Title.Value
+			_, err = list.RenameItem(item.ID, operation.Title)
+		}
+	case CheckItemOperation:
+		var item ToDoItem
+		if item, err = list.GetItem(operation.ItemID); err == nil {
+			operation.OldChecked = item.Checked.Value
+			_, err = list.setChecked(item.ID, operation.Checked)
+		}
+	case MoveItemOperation:
+		operation.OldIndex = itemIndex(list.GetItems(), operation.ItemID)
+		if err = list.MoveItem(operation.ItemID, operation.Index); err == nil {
+			operation.Index = itemIndex(list.GetItems(), operation.ItemID)
+		}
+	default:
+		err = fmt.Errorf("unknown operation kind '%s'", operation.Kind)
+	}
+	if err != nil {
+		return OperationRecord{}, err
+	}
+
+	return operation, nil
+}
+
+func (n *Notebook) restoreList(operation OperationRecord) (OperationRecord, error) {
+	if operation.List == nil {
+		return OperationRecord{}, fmt.Errorf("operation '%s' has no list", operation.Kind)
+	}
+	list, err := copyList(operation.List)
+	if err != nil {
+		return OperationRecord{}, err
+	}
+
+	err = n.RestoreList(list)
+	var notRestorableError *NotRestorableError
+	if !errors.As(err, &notRestorableError) {
+		return operation, err
+	}
+
+	recreated, err := n.AddList(list.Title.Value)
+	if err != nil {
+		return OperationRecord{}, err
+	}
+	recreated.OrderValue = OrderValue{
+		Value:     list.OrderValue.Value,
+		UpdatedAt: n.now(),
+	}
+	// The items of the new list keep their IDs, so operations that refer
+	// to them still apply
+	for _, item := range list.GetItems() {
+		if err := recreateItem(recreated, item, item.ID); err != nil {
+			return OperationRecord{}, err
+		}
+	}
+	if err := n.addList(recreated); err != nil {
+		return OperationRecord{}, err
+	}
+	n.recordList(recreated.ID)
+	operation.ListID = recreated.ID
+
+	return operation, nil
+}
+
+func restoreItem(list *ToDoList, operation OperationRecord) (OperationRecord, error) {
+	if operation.Item == nil {
+		return OperationRecord{}, fmt.Errorf("operation '%s' has no item", operation.Kind)
+	}
+
+	err := list.RestoreItem(*operation.Item)
+	var notRestorableError *NotRestorableError
+	if !errors.As(err, &notRestorableError) {
+		return operation, err
+	}
+
+	id, err := list.newID()
+	if err != nil {
+		return OperationRecord{}, err
+	}
+	if err := recreateItem(list, *operation.Item, id); err != nil {
+		return OperationRecord{}, err
+	}
+	operation.ItemID = id
+
+	return operation, nil
+}
+
+// recreateItem adds a new ToDoItem with the given ID and the same title,
+// checked state and position as the given one
+func recreateItem(list *ToDoList, item ToDoItem, id uuid.UUID) error {
+	now := list.now()
+	recreated := ToDoItem{
+		ID: id,
+		Title: Title{
+			Value:     item.Title.Value,
+			UpdatedAt: now,
+		},
+		Checked: Checked{
+			Value:     item.Checked.Value,
+			UpdatedAt: now,
+		},
+		OrderValue: OrderValue{
+			Value:     item.OrderValue.Value,
+			UpdatedAt: now,
+		},
+	}
+
+	return list.addItem(&recreated)
+}
+
+// copyList returns a deep copy of the given ToDoList
+func copyList(list *ToDoList) (*ToDoList, error) {
+	merged, err := list.Merge(list)
+	if err != nil {
+		return nil, err
+	}
+
+	return merged.(*ToDoList), nil
+}
+
+func listIndex(lists []*ToDoList, id uuid.UUID) int {
+	for i, list := range lists {
+		if list.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func itemIndex(items []ToDoItem, id uuid.UUID) int {
+	for i, item := range items {
+		if item.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,86 @@
+package solvent
+
+import (
+	"testing"
+
+	. "github.com/eldelto/solvent/internal/testutils"
+)
+
+type listState struct {
+	Title string
+	Items []itemState
+}
+
+type itemState struct {
+	Title   string
+	Checked bool
+}
+
+// liveState returns the visible state of the Notebook without any IDs,
+// as lists and items re-created by an undo get new ones
+func liveState(notebook *Notebook) []listState {
+	lists := []listState{}
+	for _, list := range notebook.GetLists() {
+		items := []itemState{}
+		for _, item := range list.GetItems() {
+			items = append(items, itemState{Title: item.Title.Value, Checked: item.Checked.Value})
+		}
+		lists = append(lists, listState{Title: list.Title.Value, Items: items})
+	}
+
+	return lists
+}
+
+func TestApplyOperationInverse(t *testing.T) {
+	for name, options := range map[string][]NotebookOption{
+		"2P-Sets": nil,
+		"OR-Sets": {WithORSets()},
+	} {
+		notebook, _ := NewNotebook(options...)
+		list0, _ := notebook.AddList(listTitle0)
+		itemID0, _ := list0.AddItem(itemTitle0)
+		itemID1, _ := list0.AddItem(itemTitle1)
+		list0.CheckItem(itemID1)
+		list1, _ := notebook.AddList(listTitle1)
+
+		for _, operation := range []OperationRecord{
+			{Kind: AddListOperation, Title: "new list"},
+			{Kind: RemoveListOperation, ListID: list0.ID},
+			{Kind: RenameListOperation, ListID: list0.ID, Title: "renamed"},
+			{Kind: MoveListOperation, ListID: list1.ID, Index: 1},
+			{Kind: AddItemOperation, ListID: list0.ID, Title: "new item"},
+			{Kind: RemoveItemOperation, ListID: list0.ID, ItemID: itemID1},
+			{Kind: RenameItemOperation, ListID: list0.ID, ItemID: itemID0, Title: "renamed"},
+			{Kind: CheckItemOperation, ListID: list0.ID, ItemID: itemID0, Checked: true},
+			{Kind: CheckItemOperation, ListID: list0.ID, ItemID: itemID1, Checked: false},
+			{Kind: MoveItemOperation, ListID: list0.ID, ItemID: itemID0, Index: 1},
+		} {
+			t.Run(name+"/"+string(operation.Kind), func(t *testing.T) {
+				replica, _ := notebook.Copy()
+				before := liveState(replica)
+
+				applied, err := replica.ApplyOperation(operation)
+				AssertEquals(t, nil, err, "ApplyOperation error")
+				after := liveState(replica)
+				AssertNotEquals(t, before, after, "state after the operation")
+
+				undone, err := replica.ApplyOperation(applied.Inverse())
+				AssertEquals(t, nil, err, "ApplyOperation inverse error")
+				AssertEquals(t, before, liveState(replica), "state after the undo")
+
+				_, err = replica.ApplyOperation(undone.Inverse())
+				AssertEquals(t, nil, err, "ApplyOperation redo error")
+				AssertEquals(t, after, liveState(replica), "state after the redo")
+			})
+		}
+	}
+}
+
+func TestApplyOperationWithUnknownList(t *testing.T) {
+	notebook, _ := NewNotebook()
+	list, _ := notebook.AddList(listTitle0)
+	notebook.RemoveList(list.ID)
+
+	_, err := notebook.ApplyOperation(OperationRecord{Kind: RenameListOperation, ListID: list.ID, Title: "renamed"})
+	AssertEquals(t, newNotFoundError(list.ID), err, "ApplyOperation error")
+}
@@ -6,97 +6,41 @@ import (
 )
 
 // ToDoItemMap is a custom type representing a mapping from ID -> ToDoItem
-type ToDoItemMap map[uuid.UUID]ToDoItem
+type ToDoItemMap = crdt.ItemMap[uuid.UUID, *ToDoItem]
 
-type ToDoItemPSet struct {
-	crdt.PSet
-}
+// ToDoItemPSet is the set holding the ToDoItems of a ToDoList. It is
+// either backed by a 2P-Set or by an OR-Set
+type ToDoItemPSet = crdt.Set[uuid.UUID, *ToDoItem]
 
+// NewToDoItemPSet creates a ToDoItemPSet backed by a 2P-Set
 func NewToDoItemPSet() ToDoItemPSet {
-	return ToDoItemPSet{
-		PSet: crdt.NewPSet("ToDoItemPSet"),
-	}
-}
-
-func (t *ToDoItemPSet) Add(item ToDoItem) error {
-	return t.PSet.Add(&item)
-}
-
-func (t *ToDoItemPSet) Remove(item ToDoItem) {
-	t.PSet.Remove(&item)
-}
-
-func (t *ToDoItemPSet) LiveView() ToDoItemMap {
-	liveView := t.PSet.LiveView()
-	convertedLiveView := make(map[uuid.UUID]ToDoItem, len(liveView))
-	for key, value := range liveView {
-		convertedLiveView[key.(uuid.UUID)] = *value.(*ToDoItem)
-	}
-
-	return convertedLiveView
-}
-
-func (t *ToDoItemPSet) Identifier() string {
-	return t.PSet.Identifier().(string)
+	pset := crdt.NewPSet[uuid.UUID, *ToDoItem]("ToDoItemPSet")
+	return &pset
 }
 
-func (t *ToDoItemPSet) Merge(other *ToDoItemPSet) (ToDoItemPSet, error) {
-	mergedPSet, err := t.PSet.Merge(&other.PSet)
-	if err != nil {
-		return ToDoItemPSet{}, err
-	}
-
-	mergedToDoItemPSet := ToDoItemPSet{
-		PSet: *mergedPSet.(*crdt.PSet),
-	}
-
-	return mergedToDoItemPSet, nil
+// NewToDoItemORSet creates a ToDoItemPSet backed by an OR-Set which
+// allows removed ToDoItems to be re-added with the same ID
+func NewToDoItemORSet() ToDoItemPSet {
+	orset := crdt.NewORSet[uuid.UUID, *ToDoItem]("ToDoItemPSet")
+	return &orset
 }
 
 // ToDoListMap is a custom type representing a mapping from ID -> ToDoList
-type ToDoListMap map[uuid.UUID]*ToDoList
+type ToDoListMap = crdt.ItemMap[uuid.UUID, *ToDoList]
 
-type ToDoListPSet struct {
-	crdt.PSet
-}
+// ToDoListPSet is the set holding the ToDoLists of a Notebook. It is
+// either backed by a 2P-Set or by an OR-Set
+type ToDoListPSet = crdt.Set[uuid.UUID, *ToDoList]
 
+// NewToDoListPSet creates a ToDoListPSet backed by a 2P-Set
 func NewToDoListPSet() ToDoListPSet {
-	return ToDoListPSet{
-		PSet: crdt.NewPSet("ToDoListPSet"),
-	}
-}
-
-func (t *ToDoListPSet) Add(list *ToDoList) error {
-	return t.PSet.Add(list)
-}
-
-func (t *ToDoListPSet) Remove(list *ToDoList) {
-	t.PSet.Remove(list)
-}
-
-func (t *ToDoListPSet) LiveView() ToDoListMap {
-	liveView := t.PSet.LiveView()
-	convertedLiveView := make(map[uuid.UUID]*ToDoList, len(liveView))
-	for key, value := range liveView {
-		convertedLiveView[key.(uuid.UUID)] = value.(*ToDoList)
-	}
-
-	return convertedLiveView
-}
-
-func (t *ToDoListPSet) Identifier() string {
-	return t.PSet.Identifier().(string)
+	pset := crdt.NewPSet[uuid.UUID, *ToDoList]("ToDoListPSet")
+	return &pset
 }
 
-func (t *ToDoListPSet) Merge(other *ToDoListPSet) (ToDoListPSet, error) {
-	mergedPSet, err := t.PSet.Merge(&other.PSet)
-	if err != nil {
-		return ToDoListPSet{}, err
-	}
-
-	mergedToDoListPSet := ToDoListPSet{
-		PSet: *mergedPSet.(*crdt.PSet),
-	}
-
-	return mergedToDoListPSet, nil
+// NewToDoListORSet creates a ToDoListPSet backed by an OR-Set which
+// allows removed ToDoLists to be re-added with the same ID
+func NewToDoListORSet() ToDoListPSet {
+	orset := crdt.NewORSet[uuid.UUID, *ToDoList]("ToDoListPSet")
+	return &orset
 }
@@ -11,21 +11,36 @@ import {
 import './App.css';
 import DetailView from './solvent/render/DetailView'
 import ListView from './solvent/render/ListView'
+import LoginView from './solvent/render/LoginView'
 
+import { v4 as uuid } from 'uuid'
 import { notebookFromDto, notebookToDto } from './solvent/Dto'
 
+// Identifies this client when acknowledging tombstones to the server
+const replicaId = uuid();
+
+const apiPath = process.env.REACT_APP_API_PATH;
+
+// Sends a request with the session cookie to the API
+function apiFetch(path, options = {}) {
+  return fetch(apiPath + path, { credentials: "include", ...options });
+}
+
 class App extends React.Component {
 
   constructor(props) {
     super(props);
 
     this.state = {
+      user: undefined,
+      notebookId: null,
       notebook: null,
       isListViewActive: true
     }
   }
 
-  componentDidMount() {
+  async componentDidMount() {
+    await this.fetchUser();
     this.syncState();
     this.timer = setInterval(() => this.syncState(), 1000);
   }
@@ -50,10 +65,67 @@ class App extends React.Component {
     }
   }
 
+  fetchUser = async () => {
+    const response = await apiFetch("/api/user");
+    if (!response.ok) {
+      this.setState({ user: null, notebookId: null, notebook: null });
+      return;
+    }
+    const user = await response.json();
+
+    const notebooksResponse = await apiFetch("/api/notebooks");
+    const notebooks = await notebooksResponse.json();
+    const ownNotebook = notebooks.find(notebook => notebook.role === "owner");
+    let notebookId = ownNotebook ? ownNotebook.id : undefined;
+    if (notebookId === undefined) {
+      const createResponse = await apiFetch("/api/notebook", {
+        method: "POST",
+        headers: { "Content-Type": "application/json" }
+      });
+      notebookId = (await createResponse.json()).id;
+    }
+
+    this.setState({ user: user, notebookId: notebookId });
+  }
+
+  login = async (name, password, register) => {
+    const body = JSON.stringify({ name: name, password: password });
+    const headers = { "Content-Type": "application/json" };
+    if (register) {
+      const response = await apiFetch("/api/users", { method: "POST", headers: headers, body: body });
+      if (!response.ok) {
+        return await response.text();
+      }
+    }
+
+    const response = await apiFetch("/api/session", { method: "POST", headers: headers, body: body });
+    if (!response.ok) {
+      return await response.text();
+    }
+
+    await this.fetchUser();
+    this.syncState();
+    return null;
+  }
+
   syncState = async () => {
+    if (!this.state.notebookId) {
+      return;
+    }
+
     if (this.state.notebook) {
+      const sentTombstones = this.state.notebook.tombstones();
       const newNotebook = await this.pushState(this.state.notebook);
-      this.updateNotebook(notebook => notebook.merge(newNotebook));
+
+      // Tombstones that were sent but are missing in the response have
+      // been garbage collected by the server
+      const remainingTombstones = new Set(newNotebook.tombstones());
+      const prunedTombstones = sentTombstones.filter(id => !remainingTombstones.has(id));
+      this.updateNotebook(notebook => {
+        const mergedNotebook = notebook.merge(newNotebook);
+        mergedNotebook.prune(prunedTombstones);
+        return mergedNotebook;
+      });
     } else {
       const newNotebook = await this.fetchState();
       this.setState({ notebook: newNotebook });
@@ -62,18 +134,21 @@ class App extends React.Component {
 
   pushState = async notebook => {
     const dto = notebookToDto(notebook);
-    const response = await fetch(process.env.REACT_APP_API_PATH + "/api/notebook", {
+    const response = await apiFetch("/api/notebook", {
       method: "PUT",
-      headers: { "Content-Type": "application/json" },
+      headers: { "Content-Type": "application/json", "X-Replica-ID": replicaId },
       body: JSON.stringify(dto)
     });
+    if (response.status === 401) {
+      this.setState({ user: null, notebookId: null, notebook: null });
+      return notebook;
+    }
     const responseBody = await response.json();
     return notebookFromDto(responseBody);
   }
 
   fetchState = async () => {
-    // TODO: Fetch for real user
-    const response = await fetch(process.env.REACT_APP_API_PATH + "/api/notebook/00000000-0000-0000-0000-000000000000");
+    const response = await apiFetch("/api/notebook/" + this.state.notebookId);
     const responseBody = await response.json();
     return notebookFromDto(responseBody);
   }
@@ -96,7 +171,7 @@ class App extends React.Component {
   }
 
   checkItem = (list, item) => {
-    if (item.checked) {
+    if (item.checked.value) {
       this.updateNotebook(notebook => {
         list.uncheckItem(item.id);
         return notebook;
@@ -140,6 +215,16 @@ class App extends React.Component {
   }
 
   render() {
+    if (this.state.user === null) {
+      return (
+        <div className="App">
+          <div className="ViewContainer">
+            <LoginView onLogin={this.login} />
+          </div>
+        </div>
+      );
+    }
+
     return (
       <Router basename={process.env.PUBLIC_URL}>
         <div className={"App" + (this.state.isListViewActive ? " overview" : "")}>
@@ -1,24 +1,80 @@
 import Notebook from './Notebook'
 import ToDoList, { Title } from './ToDoList'
-import ToDoItem, { OrderValue } from './ToDoItem'
+import ToDoItem, { Checked, OrderValue } from './ToDoItem'
 import PSet from './PSet';
 
+// Registers keep the counter and replica of the server's hybrid logical
+// clock timestamps so they can be sent back unchanged
+function withTimestampFromDto(register, dto) {
+  register.counter = dto.counter || 0;
+  register.replica = dto.replica || "00000000-0000-0000-0000-000000000000";
+
+  return register;
+}
+
+function timestampToDto(register, dto) {
+  dto.counter = register.counter || 0;
+  dto.replica = register.replica || "00000000-0000-0000-0000-000000000000";
+
+  return dto;
+}
+
 function orderValueFromDto(dto) {
-  return new OrderValue(dto.value, dto.updatedAt);
+  // Order values used to be floats which are mapped to positions that
+  // keep their order, see web/dto/dto.go
+  if (typeof dto.value === "number") {
+    return withTimestampFromDto(new OrderValue(legacyPosition(dto.value), dto.updatedAt), dto);
+  }
+
+  return withTimestampFromDto(new OrderValue(dto.value, dto.updatedAt), dto);
 }
 
 function orderValueToDto(orderValue) {
-  return {
+  return timestampToDto(orderValue, {
     "value": orderValue.value,
     "updatedAt": orderValue.updatedAt,
-  };
+  });
+}
+
+function legacyPosition(value) {
+  const view = new DataView(new ArrayBuffer(8));
+  view.setFloat64(0, value);
+
+  return view.getUint32(0).toString(16).padStart(8, "0") +
+    view.getUint32(4).toString(16).padStart(8, "0") + "V";
+}
+
+function listOrderValueFromDto(dto) {
+  // Lists used to be ordered by their creation time, newest first
+  if (!dto.orderValue || !dto.orderValue.value) {
+    const inverted = BigInt("0x7fffffffffffffff") - BigInt(dto.createdAt);
+    return new OrderValue(inverted.toString(16).padStart(16, "0") + "V", 0);
+  }
+
+  return orderValueFromDto(dto.orderValue);
+}
+
+function checkedFromDto(dto) {
+  // Checked states used to be plain booleans
+  if (typeof dto === "boolean") {
+    return new Checked(dto, 0);
+  }
+
+  return withTimestampFromDto(new Checked(dto.value, dto.updatedAt), dto);
+}
+
+function checkedToDto(checked) {
+  return timestampToDto(checked, {
+    "value": checked.value,
+    "updatedAt": checked.updatedAt
+  });
 }
 
 function toDoItemFromDto(dto) {
   return new ToDoItem(
     dto.id,
-    dto.title,
-    dto.checked,
+    titleFromDto(dto.title),
+    checkedFromDto(dto.checked),
     orderValueFromDto(dto.orderValue),
     dto.updatedAt
   );
@@ -27,8 +83,8 @@ function toDoItemFromDto(dto) {
 function toDoItemToDto(item) {
   return {
     "id": item.id,
-    "title": item.title,
-    "checked": item.checked,
+    "title": titleToDto(item.title),
+    "checked": checkedToDto(item.checked),
     "orderValue": orderValueToDto(item.orderValue),
     "updatedAt": item.updatedAt
   };
@@ -65,14 +121,19 @@ function toDoItemPSetToDto(pset) {
 }
 
 function titleFromDto(dto) {
-  return new Title(dto.value, dto.updatedAt);
+  // Item titles used to be plain strings
+  if (typeof dto === "string") {
+    return new Title(dto, 0);
+  }
+
+  return withTimestampFromDto(new Title(dto.value, dto.updatedAt), dto);
 }
 
 function titleToDto(title) {
-  return {
+  return timestampToDto(title, {
     "value": title.value,
     "updatedAt": title.updatedAt
-  };
+  });
 }
 
 function toDoListFromDto(dto) {
@@ -80,6 +141,7 @@ function toDoListFromDto(dto) {
     dto.id,
     titleFromDto(dto.title),
     toDoItemPSetFromDto(dto.toDoItems),
+    listOrderValueFromDto(dto),
     dto.createdAt
   );
 }
@@ -89,6 +151,7 @@ function toDoListToDto(list) {
     "id": list.id,
     "title": titleToDto(list.title),
     "toDoItems": toDoItemPSetToDto(list.toDoItems),
+    "orderValue": orderValueToDto(list.orderValue),
     "updatedAt": list.updatedAt,
     "createdAt": list.createdAt
   };
@@ -1,6 +1,7 @@
 import { v4 as uuid } from 'uuid'
 import PSet from './PSet';
 import ToDoList from './ToDoList';
+import { positionBetween, compareOrderValues } from './Position';
 
 export default class Notebook {
 
@@ -15,7 +16,10 @@ export default class Notebook {
   }
 
   addList(title) {
-    const list = ToDoList.new(title);
+    // New lists are put in front of all the others
+    const lists = this.getLists();
+    const firstOrderValue = lists.length > 0 ? lists[0].orderValue.value : "";
+    const list = ToDoList.new(title, positionBetween("", firstOrderValue));
     this.toDoLists.add(list);
 
     return list;
@@ -36,7 +40,19 @@ export default class Notebook {
     const lists = [];
     this.toDoLists.liveView().forEach((item, _) => lists.push(item));
 
-    return lists;
+    return lists.sort(compareOrderValues);
+  }
+
+  tombstones() {
+    const tombstones = this.toDoLists.tombstones();
+    this.toDoLists.liveView().forEach((list, _) => tombstones.push(...list.toDoItems.tombstones()));
+
+    return tombstones;
+  }
+
+  prune(ids) {
+    this.toDoLists.prune(ids);
+    this.toDoLists.liveView().forEach((list, _) => list.toDoItems.prune(ids));
   }
 
   identifier() {
@@ -36,6 +36,17 @@ export default class PSet {
     return liveView;
   }
 
+  tombstones() {
+    return Array.from(this.tombstoneSet.keys());
+  }
+
+  prune(keys) {
+    keys.forEach(key => {
+      this.liveSet.delete(key);
+      this.tombstoneSet.delete(key);
+    });
+  }
+
   identifier() {
     return this.id;
   }
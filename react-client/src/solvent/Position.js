@@ -0,0 +1,60 @@
+// Positions are fractional indices with base-62 digits that sort
+// lexicographically, see crdt/position.go for the server implementation
+const positionDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz";
+
+export function positionBetween(before, after) {
+  if (after === "") {
+    return positionAfter(before);
+  }
+  if (before >= after) {
+    throw new Error(`no position can be created between '${before}' and '${after}'`);
+  }
+
+  return positionMidpoint(before, after);
+}
+
+export function compareOrderValues(a, b) {
+  if (a.orderValue.value !== b.orderValue.value) {
+    return a.orderValue.value < b.orderValue.value ? -1 : 1;
+  }
+  if (a.id === b.id) {
+    return 0;
+  }
+
+  return a.id < b.id ? -1 : 1;
+}
+
+function positionAfter(position) {
+  for (let i = 0; i < position.length; i++) {
+    const digit = positionDigits.indexOf(position[i]);
+    if (digit < positionDigits.length - 1) {
+      return position.substring(0, i) + positionDigits[digit + 1];
+    }
+  }
+
+  return position + positionDigits[1];
+}
+
+function positionMidpoint(before, after) {
+  if (after !== "") {
+    let n = 0;
+    while (n < after.length && (before[n] || positionDigits[0]) === after[n]) {
+      n++;
+    }
+    if (n > 0) {
+      return after.substring(0, n) + positionMidpoint(before.substring(n), after.substring(n));
+    }
+  }
+
+  const digitBefore = before !== "" ? positionDigits.indexOf(before[0]) : 0;
+  const digitAfter = after !== "" ? positionDigits.indexOf(after[0]) : positionDigits.length;
+
+  if (digitAfter - digitBefore > 1) {
+    return positionDigits[Math.floor((digitBefore + digitAfter + 1) / 2)];
+  }
+  if (after.length > 1) {
+    return after.substring(0, 1);
+  }
+
+  return positionDigits[digitBefore] + positionMidpoint(before.substring(1), "");
+}
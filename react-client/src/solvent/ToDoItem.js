@@ -12,14 +12,23 @@ export default class ToDoItem {
   }
 
   merge(other) {
-    const checked = this.checked || other.checked
+    let mergedTitle = this.title;
+    if (other.title.updatedAt > this.title.updatedAt) {
+      mergedTitle = other.title;
+    }
+
+    let mergedChecked = this.checked;
+    if (other.checked.updatedAt > this.checked.updatedAt ||
+      (other.checked.updatedAt === this.checked.updatedAt && other.checked.value)) {
+      mergedChecked = other.checked;
+    }
 
     let mergedOrderValue = this.orderValue;
     if (other.orderValue.updatedAt > this.orderValue.updatedAt) {
       mergedOrderValue = other.orderValue;
     }
 
-    return new ToDoItem(this.id, this.title, checked, mergedOrderValue);
+    return new ToDoItem(this.id, mergedTitle, mergedChecked, mergedOrderValue);
   }
 }
 
@@ -29,4 +38,12 @@ export class OrderValue {
     this.value = value;
     this.updatedAt = updatedAt;
   }
-}
\ No newline at end of file
+}
+
+export class Checked {
+
+  constructor(value, updatedAt) {
+    this.value = value;
+    this.updatedAt = updatedAt;
+  }
+}
@@ -1,33 +1,38 @@
 import ToDoItem from './ToDoItem'
-import { OrderValue } from './ToDoItem'
+import { Checked, OrderValue } from './ToDoItem'
 import { v4 as uuid } from 'uuid'
 import PSet from './PSet'
+import { positionBetween, compareOrderValues } from './Position'
 
 export default class ToDoList {
 
-  constructor(id, title, toDoItems, createdAt) {
+  constructor(id, title, toDoItems, orderValue, createdAt) {
     this.id = id;
     this.title = title;
     this.toDoItems = toDoItems;
+    this.orderValue = orderValue;
     this.createdAt = createdAt;
   }
 
-  static new(title) {
+  static new(title, position) {
     const titleClass = new Title(title, currentNanos());
-    return new ToDoList(uuid(), titleClass, PSet.new("ToDoItemPSet"), currentNanos());
+    const orderValue = new OrderValue(position, titleClass.updatedAt);
+    return new ToDoList(uuid(), titleClass, PSet.new("ToDoItemPSet"), orderValue, currentNanos());
   }
 
   get items() {
     const items = [];
     this.toDoItems.liveView().forEach((item, _) => items.push(item));
 
-    return items;
+    return items.sort(compareOrderValues);
   }
 
   addItem(title) {
     const id = uuid();
-    const orderValue = new OrderValue(this.nextOrderValue(), currentNanos());
-    const item = new ToDoItem(id, title, false, orderValue);
+    const items = this.items;
+    const lastOrderValue = items.length > 0 ? items[items.length - 1].orderValue.value : "";
+    const orderValue = new OrderValue(positionBetween(lastOrderValue, ""), currentNanos());
+    const item = new ToDoItem(id, new Title(title, currentNanos()), new Checked(false, currentNanos()), orderValue);
     this.toDoItems.add(item);
 
     return id;
@@ -46,69 +51,52 @@ export default class ToDoList {
 
   checkItem(id) {
     const item = this.getItem(id);
-    item.checked = true;
+    item.checked = new Checked(true, currentNanos());
 
     return id;
   }
 
   uncheckItem(id) {
     const item = this.getItem(id);
-    this.removeItem(id);
-
-    const newId = uuid();
-    const newItem = new ToDoItem(newId, item.title, false, item.orderValue);
-    this.toDoItems.add(newItem);
+    item.checked = new Checked(false, currentNanos());
 
-    return newId;
+    return id;
   }
 
   moveItem(id, targetIndex) {
     const item = this.getItem(id);
-    const items = this.items.sort((a, b) => a.orderValue.value - b.orderValue.value);
-
-    const itemOrderValue = item.orderValue.value;
-    const orderValueMid = items[targetIndex].orderValue.value;
-    let orderValueAdjacent;
-    if (orderValueMid < itemOrderValue) {
-      // Movint item up
-      if ((targetIndex - 1) >= 0) {
-        orderValueAdjacent = items[targetIndex - 1].orderValue.value;
-      } else {
-        orderValueAdjacent = 0.0;
-      }
-    } else if (orderValueMid > itemOrderValue) {
-      // Movint item down
-      if ((targetIndex + 1) < items.length) {
-        orderValueAdjacent = items[targetIndex + 1].orderValue.value;
-      } else {
-        orderValueAdjacent = this.nextOrderValue();
-      }
-    } else {
+    const items = this.items;
+    const index = items.findIndex(other => other.id === id);
+    const orderValues = items.filter(other => other.id !== id)
+      .map(other => other.orderValue.value);
+
+    targetIndex = Math.max(0, Math.min(targetIndex, orderValues.length));
+    if (targetIndex === index) {
+      // Already on correct position
       return;
     }
 
-    const newOrderValue = new OrderValue((orderValueMid + orderValueAdjacent) / 2.0, currentNanos());
-    item.orderValue = newOrderValue;
+    // Skip positions equal to the one before to not end up between
+    // concurrently created duplicates
+    const before = targetIndex > 0 ? orderValues[targetIndex - 1] : "";
+    const after = orderValues.slice(targetIndex).find(value => value > before) || "";
+
+    item.orderValue = new OrderValue(positionBetween(before, after), currentNanos());
     this.toDoItems.add(item);
 
     return item.id;
   }
 
   renameItem(id, title) {
-    const oldItem = this.getItem(id);
-    if (oldItem.title === title) {
-      return oldItem.id;
+    const item = this.getItem(id);
+    if (item.title.value === title) {
+      return item.id;
     }
 
-    this.removeItem(oldItem.id);
-
-    const newId = this.addItem(title);
-    const newItem = this.getItem(newId);
-    newItem.orderValue = oldItem.orderValue;
-    newItem.updatedAt = oldItem.updatedAt;
-    this.toDoItems.add(newItem);
+    item.title = new Title(title, currentNanos());
+    this.toDoItems.add(item);
 
-    return newId;
+    return item.id;
   }
 
   rename(title) {
@@ -119,7 +107,7 @@ export default class ToDoList {
 
   isChecked() {
     const items = this.items;
-    if (items.length > 0 && items.find(item => !item.checked) === undefined) {
+    if (items.length > 0 && items.find(item => !item.checked.value) === undefined) {
       return true;
     } else {
       return false;
@@ -136,20 +124,14 @@ export default class ToDoList {
       mergedTitle = other.title;
     }
 
-    const mergedtoDoItems = this.toDoItems.merge(other.toDoItems);
-
-    return new ToDoList(this.id, mergedTitle, mergedtoDoItems, this.createdAt);
-  }
+    let mergedOrderValue = this.orderValue;
+    if (other.orderValue.updatedAt > this.orderValue.updatedAt) {
+      mergedOrderValue = other.orderValue;
+    }
 
-  nextOrderValue() {
-    let orderValue = 0.0;
-    this.toDoItems.liveView().forEach((item, _) => {
-      if (item.orderValue.value > orderValue) {
-        orderValue = item.orderValue.value;
-      }
-    });
+    const mergedtoDoItems = this.toDoItems.merge(other.toDoItems);
 
-    return orderValue + 10.0;
+    return new ToDoList(this.id, mergedTitle, mergedtoDoItems, mergedOrderValue, this.createdAt);
   }
 }
 
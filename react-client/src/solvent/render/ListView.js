@@ -4,6 +4,8 @@ import { Link } from "react-router-dom";
 import { ReactComponent as Magnify } from '../../icons/magnify.svg'
 import { ReactComponent as Plus } from '../../icons/plus.svg'
 
+import { compareOrderValues } from '../Position'
+
 export default function ListView(props) {
   return (
     <div className="ListView">
@@ -59,7 +61,7 @@ function ToDoLists(props) {
       {props.addButton ?
         <AddListButton onClick={props.onAddList} />
         : ""}
-      {props.toDoLists.sort((a, b) => b.createdAt - a.createdAt)
+      {props.toDoLists.sort(compareOrderValues)
         .map(toDoList =>
           <ToDoList key={toDoList.id} toDoList={toDoList} />
         )}
@@ -0,0 +1,52 @@
+import React from 'react';
+
+export default class LoginView extends React.Component {
+
+  constructor(props) {
+    super(props);
+
+    this.state = {
+      name: "",
+      password: "",
+      error: null
+    }
+  }
+
+  submit = async (event, register) => {
+    event.preventDefault();
+    const error = await this.props.onLogin(this.state.name, this.state.password, register);
+    this.setState({ error: error });
+  }
+
+  render() {
+    return (
+      <div className="LoginView">
+        <header>
+          <div className="header">
+            <h1 className="HeaderTitle">Solvent</h1>
+          </div>
+        </header>
+
+        <form className="LoginViewForm" onSubmit={event => this.submit(event, false)}>
+          <input
+            type="text"
+            placeholder="Name"
+            autoComplete="username"
+            value={this.state.name}
+            onChange={event => this.setState({ name: event.target.value })}
+          />
+          <input
+            type="password"
+            placeholder="Password"
+            autoComplete="current-password"
+            value={this.state.password}
+            onChange={event => this.setState({ password: event.target.value })}
+          />
+          {this.state.error ? <p className="LoginViewError">{this.state.error}</p> : null}
+          <button type="submit">Log In</button>
+          <button type="button" onClick={event => this.submit(event, true)}>Register</button>
+        </form>
+      </div>
+    );
+  }
+}
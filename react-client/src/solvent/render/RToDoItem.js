@@ -24,7 +24,7 @@ export default class RToDoItem extends React.Component {
   startEditing = () => {
     this.setState({
       editing: true,
-      newTitle: this.props.item.title
+      newTitle: this.props.item.title.value
     });
   }
 
@@ -45,16 +45,16 @@ export default class RToDoItem extends React.Component {
       <Draggable draggableId={this.props.item.id} index={this.props.index}>
         {provided => (
           <div
-            className={"ToDoItem" + (this.props.item.checked ? " checked" : "")}
+            className={"ToDoItem" + (this.props.item.checked.value ? " checked" : "")}
             ref={provided.innerRef}
             {...provided.draggableProps}
           >
             <button className="ToDoItemCheckbox" onClick={() => this.props.onCheck(this.props.item)}>
-              {this.props.item.checked ? <CheckedCircle /> : <CheckedCircleBlank />}
+              {this.props.item.checked.value ? <CheckedCircle /> : <CheckedCircleBlank />}
             </ button>
             <InputArea
               className="ToDoItemTitle"
-              value={this.state.editing ? this.state.newTitle : this.props.item.title}
+              value={this.state.editing ? this.state.newTitle : this.props.item.title.value}
               onFocus={this.startEditing}
               onChange={this.editItem}
               onBlur={this.renameItem}
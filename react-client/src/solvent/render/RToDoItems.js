@@ -2,6 +2,7 @@ import React from 'react'
 import { DragDropContext, Droppable } from 'react-beautiful-dnd'
 
 import RToDoItem from './RToDoItem'
+import { compareOrderValues } from '../Position'
 
 export default function RToDoItems(props) {
   return (
@@ -9,7 +10,7 @@ export default function RToDoItems(props) {
       <Droppable droppableId="ToDoItemsDroppable">
         {provided => (
           <div className="ToDoItems" {...provided.droppableProps} ref={provided.innerRef}>
-            {props.items.sort((a, b) => a.orderValue.value - b.orderValue.value)
+            {props.items.sort(compareOrderValues)
               .map((item, index) => (
                 <RToDoItem
                   key={item.id}
@@ -0,0 +1,585 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eldelto/solvent/service/errcode"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultSessionLifetime is the time after which a session expires if
+// no other lifetime is configured
+const DefaultSessionLifetime = 30 * 24 * time.Hour
+
+const (
+	minPasswordLength = 8
+	// bcrypt ignores everything after the first 72 bytes
+	maxPasswordLength = 72
+	maxUserNameLength = 64
+)
+
+// User is an account that owns notebooks
+type User struct {
+	ID           uuid.UUID
+	Name         string
+	PasswordHash []byte
+}
+
+// Session authenticates the requests of a logged in User. Only the hash
+// of its token is stored, so a leaked store cannot be used to log in
+type Session struct {
+	TokenHash string
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+}
+
+// Role is the level of access a user has to a notebook
+type Role string
+
+const (
+	// RoleViewer can only fetch a notebook
+	RoleViewer Role = "viewer"
+	// RoleEditor can fetch and change a notebook
+	RoleEditor Role = "editor"
+	// RoleOwner can additionally share and remove a notebook
+	RoleOwner Role = "owner"
+)
+
+// ParseRole returns the Role with the given name or a ValidationError
+// if there is none
+func ParseRole(name string) (Role, error) {
+	switch role := Role(name); role {
+	case RoleViewer, RoleEditor, RoleOwner:
+		return role, nil
+	default:
+		return "", errcode.NewValidationError("unknown role '" + name + "'")
+	}
+}
+
+// Includes reports whether the Role grants at least the access of the
+// other Role
+func (r Role) Includes(other Role) bool {
+	return r.rank() >= other.rank()
+}
+
+func (r Role) rank() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleEditor:
+		return 2
+	case RoleOwner:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Member is a user with access to a notebook
+type Member struct {
+	UserID uuid.UUID
+	Name   string
+	Role   Role
+}
+
+// NotebookAccess is the Role a user has for a notebook
+type NotebookAccess struct {
+	NotebookID uuid.UUID
+	Role       Role
+}
+
+// ShareLink grants everyone who knows its token read-only access to a
+// notebook or a single ToDoList of it. Like for sessions only the hash
+// of the token is stored
+type ShareLink struct {
+	ID         uuid.UUID
+	TokenHash  string
+	NotebookID uuid.UUID
+	// ListID is the ID of the only ToDoList the link shares or uuid.Nil
+	// if it shares the whole notebook
+	ListID uuid.UUID
+	// ExpiresAt is the zero time if the link never expires
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// IsExpired reports whether the ShareLink has expired at the given time
+func (l *ShareLink) IsExpired(now time.Time) bool {
+	return !l.ExpiresAt.IsZero() && !now.Before(l.ExpiresAt)
+}
+
+// ShareLinkRepository persists the ShareLinks of notebooks
+type ShareLinkRepository interface {
+	StoreShareLink(link *ShareLink) error
+	FetchShareLink(tokenHash string) (*ShareLink, error)
+	FetchShareLinks(notebookID uuid.UUID) ([]ShareLink, error)
+	// RemoveShareLink removes the ShareLink with the given ID or returns
+	// a NotFoundError if the notebook has no such link
+	RemoveShareLink(notebookID, id uuid.UUID) error
+}
+
+// AccountRepository persists the users, their sessions and the access
+// control lists of notebooks
+type AccountRepository interface {
+	ShareLinkRepository
+	// StoreUser stores a new user or returns a ConflictError if the
+	// name is already taken
+	StoreUser(user *User) error
+	FetchUser(id uuid.UUID) (*User, error)
+	FetchUserByName(name string) (*User, error)
+	StoreSession(session *Session) error
+	FetchSession(tokenHash string) (*Session, error)
+	RemoveSession(tokenHash string) error
+	// StoreOwner stores the user as owner of the notebook
+	StoreOwner(notebookID, userID uuid.UUID) error
+	// FetchOwner returns the ID of the user owning the notebook or a
+	// NotFoundError if the notebook has no owner
+	FetchOwner(notebookID uuid.UUID) (uuid.UUID, error)
+	FetchOwnedNotebooks(userID uuid.UUID) ([]uuid.UUID, error)
+	// StoreMember grants the user the given Role for the notebook or
+	// replaces the Role the user had before
+	StoreMember(notebookID, userID uuid.UUID, role Role) error
+	RemoveMember(notebookID, userID uuid.UUID) error
+	// FetchMembers returns the Roles of all the users the notebook is
+	// shared with, except for its owner
+	FetchMembers(notebookID uuid.UUID) (map[uuid.UUID]Role, error)
+	// FetchSharedNotebooks returns the Roles the user has for the
+	// notebooks that have been shared with it
+	FetchSharedNotebooks(userID uuid.UUID) (map[uuid.UUID]Role, error)
+}
+
+// Accounts registers and authenticates users and decides which
+// notebooks they can access with which Role
+type Accounts struct {
+	repository      AccountRepository
+	sessionLifetime time.Duration
+	now             func() time.Time
+	// claims serializes the claims of notebooks without an owner
+	claims sync.Mutex
+}
+
+// AccountsOption configures optional behaviour of Accounts
+type AccountsOption func(a *Accounts)
+
+// WithSessionLifetime sets the time after which sessions expire
+func WithSessionLifetime(lifetime time.Duration) AccountsOption {
+	return func(a *Accounts) {
+		a.sessionLifetime = lifetime
+	}
+}
+
+func NewAccounts(repository AccountRepository, options ...AccountsOption) *Accounts {
+	accounts := &Accounts{
+		repository:      repository,
+		sessionLifetime: DefaultSessionLifetime,
+		now:             time.Now,
+	}
+	for _, option := range options {
+		option(accounts)
+	}
+
+	return accounts
+}
+
+// Register creates a new User with the given name and password
+func (a *Accounts) Register(name, password string) (*User, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxUserNameLength {
+		return nil, errcode.NewValidationError("user name must be between 1 and 64 characters long")
+	}
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return nil, errcode.NewValidationError("password must be between 8 and 72 bytes long")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, errcode.NewUnknownError(err, "could not hash password")
+	}
+
+	user := User{
+		ID:           uuid.New(),
+		Name:         name,
+		PasswordHash: hash,
+	}
+	if err := a.repository.StoreUser(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// Login starts a new Session for the user with the given name and
+// password and returns the token that authenticates it
+func (a *Accounts) Login(name, password string) (string, *Session, error) {
+	user, err := a.repository.FetchUserByName(strings.TrimSpace(name))
+	var notFoundError *errcode.NotFoundError
+	if errors.As(err, &notFoundError) {
+		// Compare anyway so unknown names take as long as wrong passwords
+		bcrypt.CompareHashAndPassword(unknownUserHash(), []byte(password))
+		return "", nil, errcode.NewUnauthorizedError("invalid user name or password")
+	} else if err != nil {
+		return "", nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password)); err != nil {
+		return "", nil, errcode.NewUnauthorizedError("invalid user name or password")
+	}
+
+	token, err := newSessionToken()
+	if err != nil {
+		return "", nil, err
+	}
+	session := Session{
+		TokenHash: hashSessionToken(token),
+		UserID:    user.ID,
+		ExpiresAt: a.now().Add(a.sessionLifetime),
+	}
+	if err := a.repository.StoreSession(&session); err != nil {
+		return "", nil, err
+	}
+
+	return token, &session, nil
+}
+
+// Authenticate returns the User of the Session with the given token or
+// an UnauthorizedError if the session is unknown or has expired
+func (a *Accounts) Authenticate(token string) (*User, error) {
+	tokenHash := hashSessionToken(token)
+	session, err := a.repository.FetchSession(tokenHash)
+	var notFoundError *errcode.NotFoundError
+	if errors.As(err, &notFoundError) {
+		return nil, errcode.NewUnauthorizedError("unknown session")
+	} else if err != nil {
+		return nil, err
+	}
+
+	if !a.now().Before(session.ExpiresAt) {
+		a.repository.RemoveSession(tokenHash)
+		return nil, errcode.NewUnauthorizedError("session has expired")
+	}
+
+	user, err := a.repository.FetchUser(session.UserID)
+	if errors.As(err, &notFoundError) {
+		return nil, errcode.NewUnauthorizedError("unknown session")
+	}
+
+	return user, err
+}
+
+// Logout ends the Session with the given token
+func (a *Accounts) Logout(token string) error {
+	return a.repository.RemoveSession(hashSessionToken(token))
+}
+
+// Own makes the given User the owner of the notebook with the given ID
+func (a *Accounts) Own(user *User, notebookID uuid.UUID) error {
+	return a.repository.StoreOwner(notebookID, user.ID)
+}
+
+// Claim makes the given User the owner of the notebook with the given
+// ID if the notebook has no owner yet, which is the case for notebooks
+// stored before there were any accounts. Claiming a notebook of another
+// owner returns a ForbiddenError
+func (a *Accounts) Claim(user *User, notebookID uuid.UUID) error {
+	a.claims.Lock()
+	defer a.claims.Unlock()
+
+	owner, err := a.repository.FetchOwner(notebookID)
+	var notFoundError *errcode.NotFoundError
+	if errors.As(err, &notFoundError) {
+		return a.Own(user, notebookID)
+	} else if err != nil {
+		return err
+	}
+	if owner != user.ID {
+		return errcode.NewForbiddenError(user.ID, notebookID)
+	}
+
+	return nil
+}
+
+// Role returns the Role the given User has for the notebook with the
+// given ID, a ForbiddenError if the user has no access or a
+// NotFoundError if the notebook has no owner
+func (a *Accounts) Role(user *User, notebookID uuid.UUID) (Role, error) {
+	owner, err := a.repository.FetchOwner(notebookID)
+	if err != nil {
+		return "", err
+	}
+	if owner == user.ID {
+		return RoleOwner, nil
+	}
+
+	members, err := a.repository.FetchMembers(notebookID)
+	if err != nil {
+		return "", err
+	}
+	role, ok := members[user.ID]
+	if !ok {
+		return "", errcode.NewForbiddenError(user.ID, notebookID)
+	}
+
+	return role, nil
+}
+
+// Authorize returns a ForbiddenError if the given User does not have at
+// least the required Role for the notebook with the given ID or a
+// NotFoundError if the notebook has no owner
+func (a *Accounts) Authorize(user *User, notebookID uuid.UUID, required Role) error {
+	role, err := a.Role(user, notebookID)
+	if err != nil {
+		return err
+	}
+	if !role.Includes(required) {
+		return errcode.NewForbiddenError(user.ID, notebookID)
+	}
+
+	return nil
+}
+
+// Share grants the user with the given name the given Role for the
+// notebook with the given ID. Only the owner can share a notebook and
+// there can only be one owner
+func (a *Accounts) Share(user *User, notebookID uuid.UUID, name string, role Role) (*Member, error) {
+	if err := a.Authorize(user, notebookID, RoleOwner); err != nil {
+		return nil, err
+	}
+	if role != RoleEditor && role != RoleViewer {
+		return nil, errcode.NewValidationError("notebooks can only be shared with editors or viewers")
+	}
+
+	member, err := a.repository.FetchUserByName(strings.TrimSpace(name))
+	if err != nil {
+		return nil, err
+	}
+	if member.ID == user.ID {
+		return nil, errcode.NewValidationError("owners cannot share notebooks with themselves")
+	}
+
+	if err := a.repository.StoreMember(notebookID, member.ID, role); err != nil {
+		return nil, err
+	}
+
+	return &Member{UserID: member.ID, Name: member.Name, Role: role}, nil
+}
+
+// Unshare revokes the access of the member with the given ID to the
+// notebook with the given ID. Only the owner can revoke the access of
+// others while members can always leave a notebook
+func (a *Accounts) Unshare(user *User, notebookID, memberID uuid.UUID) error {
+	required := RoleOwner
+	if memberID == user.ID {
+		required = RoleViewer
+	}
+	if err := a.Authorize(user, notebookID, required); err != nil {
+		return err
+	}
+
+	return a.repository.RemoveMember(notebookID, memberID)
+}
+
+// Members returns the owner and all the members of the notebook with
+// the given ID ordered by their names
+func (a *Accounts) Members(user *User, notebookID uuid.UUID) ([]Member, error) {
+	if err := a.Authorize(user, notebookID, RoleViewer); err != nil {
+		return nil, err
+	}
+
+	owner, err := a.repository.FetchOwner(notebookID)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := a.repository.FetchMembers(notebookID)
+	if err != nil {
+		return nil, err
+	}
+	roles[owner] = RoleOwner
+
+	members := make([]Member, 0, len(roles))
+	for userID, role := range roles {
+		member, err := a.repository.FetchUser(userID)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, Member{UserID: userID, Name: member.Name, Role: role})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Name < members[j].Name
+	})
+
+	return members, nil
+}
+
+// Notebooks returns the Roles of the given User for all the notebooks
+// it owns or that have been shared with it
+func (a *Accounts) Notebooks(user *User) ([]NotebookAccess, error) {
+	owned, err := a.repository.FetchOwnedNotebooks(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := a.repository.FetchSharedNotebooks(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	notebooks := make([]NotebookAccess, 0, len(owned)+len(shared))
+	for _, notebookID := range owned {
+		notebooks = append(notebooks, NotebookAccess{NotebookID: notebookID, Role: RoleOwner})
+	}
+	for notebookID, role := range shared {
+		notebooks = append(notebooks, NotebookAccess{NotebookID: notebookID, Role: role})
+	}
+	sort.Slice(notebooks, func(i, j int) bool {
+		return bytes.Compare(notebooks[i].NotebookID[:], notebooks[j].NotebookID[:]) < 0
+	})
+
+	return notebooks, nil
+}
+
+// CreateShareLink creates a new ShareLink for the notebook with the
+// given ID or only for one of its lists if the list ID is not uuid.Nil
+// and returns the token of the link. Only the owner can create links.
+// Links to lists can only be created if the AccountRepository also
+// implements the Repository interface to check that the list exists
+func (a *Accounts) CreateShareLink(user *User, notebookID, listID uuid.UUID, expiresAt time.Time) (string, *ShareLink, error) {
+	if err := a.Authorize(user, notebookID, RoleOwner); err != nil {
+		return "", nil, err
+	}
+	now := a.now()
+	if !expiresAt.IsZero() && !now.Before(expiresAt) {
+		return "", nil, errcode.NewValidationError("share links have to expire in the future")
+	}
+	if listID != uuid.Nil {
+		if err := a.validateList(notebookID, listID); err != nil {
+			return "", nil, err
+		}
+	}
+
+	token, err := newSessionToken()
+	if err != nil {
+		return "", nil, err
+	}
+	link := ShareLink{
+		ID:         uuid.New(),
+		TokenHash:  hashSessionToken(token),
+		NotebookID: notebookID,
+		ListID:     listID,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  now,
+	}
+	if err := a.repository.StoreShareLink(&link); err != nil {
+		return "", nil, err
+	}
+
+	return token, &link, nil
+}
+
+// validateList returns a ValidationError if the notebook with the given
+// ID has no list with the given ID
+func (a *Accounts) validateList(notebookID, listID uuid.UUID) error {
+	notebooks, ok := a.repository.(Repository)
+	if !ok {
+		return errcode.NewValidationError("lists can not be shared")
+	}
+
+	notebook, err := notebooks.Fetch(notebookID)
+	if err != nil {
+		return err
+	}
+	if _, err := notebook.GetList(listID); err != nil {
+		return errcode.NewValidationError(fmt.Sprintf("notebook '%v' has no list with ID '%v'", notebookID, listID))
+	}
+
+	return nil
+}
+
+// ShareLinks returns all the ShareLinks of the notebook with the given
+// ID that have not expired yet ordered by their creation time
+func (a *Accounts) ShareLinks(user *User, notebookID uuid.UUID) ([]ShareLink, error) {
+	if err := a.Authorize(user, notebookID, RoleOwner); err != nil {
+		return nil, err
+	}
+
+	links, err := a.repository.FetchShareLinks(notebookID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := a.now()
+	active := make([]ShareLink, 0, len(links))
+	for _, link := range links {
+		if !link.IsExpired(now) {
+			active = append(active, link)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].CreatedAt.Before(active[j].CreatedAt)
+	})
+
+	return active, nil
+}
+
+// RevokeShareLink removes the ShareLink with the given ID from the
+// notebook with the given ID, so its token cannot be used anymore
+func (a *Accounts) RevokeShareLink(user *User, notebookID, linkID uuid.UUID) error {
+	if err := a.Authorize(user, notebookID, RoleOwner); err != nil {
+		return err
+	}
+
+	return a.repository.RemoveShareLink(notebookID, linkID)
+}
+
+// ResolveShareLink returns the ShareLink with the given token or a
+// NotFoundError if there is none or it has expired
+func (a *Accounts) ResolveShareLink(token string) (*ShareLink, error) {
+	tokenHash := hashSessionToken(token)
+	link, err := a.repository.FetchShareLink(tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if link.IsExpired(a.now()) {
+		a.repository.RemoveShareLink(link.NotebookID, link.ID)
+		return nil, errcode.NewNotFoundError("share link", link.ID)
+	}
+
+	return link, nil
+}
+
+var unknownUserHashOnce sync.Once
+var unknownUserHashValue []byte
+
+// unknownUserHash returns the hash the passwords of unknown users are
+// compared against
+func unknownUserHash() []byte {
+	unknownUserHashOnce.Do(func() {
+		unknownUserHashValue, _ = bcrypt.GenerateFromPassword([]byte("unknown-user"), bcrypt.DefaultCost)
+	})
+
+	return unknownUserHashValue
+}
+
+func newSessionToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", errcode.NewUnknownError(err, "could not generate session token")
+	}
+
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+func hashSessionToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
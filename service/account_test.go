@@ -0,0 +1,264 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eldelto/solvent"
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/eldelto/solvent/service/errcode"
+	"github.com/google/uuid"
+)
+
+func TestRegisterValidatesCredentials(t *testing.T) {
+	accounts := NewAccounts(newTestRepository())
+
+	_, err := accounts.Register(" ", "password")
+	var validationError *errcode.ValidationError
+	AssertEquals(t, true, errors.As(err, &validationError), "empty name is invalid")
+
+	_, err = accounts.Register("user", "short")
+	AssertEquals(t, true, errors.As(err, &validationError), "short password is invalid")
+
+	user, err := accounts.Register("user", "password")
+	AssertEquals(t, nil, err, "accounts.Register error")
+	AssertNotEquals(t, []byte("password"), user.PasswordHash, "user.PasswordHash")
+
+	_, err = accounts.Register("user", "password")
+	var conflictError *errcode.ConflictError
+	AssertEquals(t, true, errors.As(err, &conflictError), "taken name is a conflict")
+}
+
+func TestLoginAndAuthenticate(t *testing.T) {
+	now := time.Now()
+	accounts := NewAccounts(newTestRepository(), WithSessionLifetime(time.Hour))
+	accounts.now = func() time.Time { return now }
+	user, _ := accounts.Register("user", "password")
+
+	_, _, err := accounts.Login("user", "wrong password")
+	var unauthorizedError *errcode.UnauthorizedError
+	AssertEquals(t, true, errors.As(err, &unauthorizedError), "wrong password is unauthorized")
+
+	_, _, err = accounts.Login("unknown", "password")
+	AssertEquals(t, true, errors.As(err, &unauthorizedError), "unknown user is unauthorized")
+
+	token, session, err := accounts.Login("user", "password")
+	AssertEquals(t, nil, err, "accounts.Login error")
+	AssertEquals(t, now.Add(time.Hour), session.ExpiresAt, "session.ExpiresAt")
+
+	authenticated, err := accounts.Authenticate(token)
+	AssertEquals(t, nil, err, "accounts.Authenticate error")
+	AssertEquals(t, user.ID, authenticated.ID, "authenticated.ID")
+
+	// Sessions expire after their lifetime
+	now = now.Add(time.Hour)
+	_, err = accounts.Authenticate(token)
+	AssertEquals(t, true, errors.As(err, &unauthorizedError), "expired session is unauthorized")
+
+	now = now.Add(-time.Hour)
+	token, _, _ = accounts.Login("user", "password")
+	accounts.Logout(token)
+	_, err = accounts.Authenticate(token)
+	AssertEquals(t, true, errors.As(err, &unauthorizedError), "logged out session is unauthorized")
+}
+
+func TestAuthorize(t *testing.T) {
+	accounts := NewAccounts(newTestRepository())
+	owner, _ := accounts.Register("owner", "password")
+	viewer, _ := accounts.Register("viewer", "password")
+	other, _ := accounts.Register("other", "password")
+	notebookID := uuid.New()
+
+	err := accounts.Authorize(owner, notebookID, RoleViewer)
+	var notFoundError *errcode.NotFoundError
+	AssertEquals(t, true, errors.As(err, &notFoundError), "notebook without owner is not found")
+
+	accounts.Own(owner, notebookID)
+	AssertEquals(t, nil, accounts.Authorize(owner, notebookID, RoleOwner), "owner error")
+
+	_, err = accounts.Share(owner, notebookID, "viewer", RoleViewer)
+	AssertEquals(t, nil, err, "accounts.Share error")
+	AssertEquals(t, nil, accounts.Authorize(viewer, notebookID, RoleViewer), "viewer error")
+
+	err = accounts.Authorize(viewer, notebookID, RoleEditor)
+	var forbiddenError *errcode.ForbiddenError
+	AssertEquals(t, true, errors.As(err, &forbiddenError), "viewer cannot edit")
+
+	err = accounts.Authorize(other, notebookID, RoleViewer)
+	AssertEquals(t, true, errors.As(err, &forbiddenError), "other user is forbidden")
+
+	notebooks, _ := accounts.Notebooks(viewer)
+	AssertEquals(t, []NotebookAccess{{NotebookID: notebookID, Role: RoleViewer}}, notebooks, "viewer notebooks")
+}
+
+func TestShare(t *testing.T) {
+	accounts := NewAccounts(newTestRepository())
+	owner, _ := accounts.Register("owner", "password")
+	editor, _ := accounts.Register("editor", "password")
+	accounts.Register("viewer", "password")
+	notebookID := uuid.New()
+	accounts.Own(owner, notebookID)
+
+	_, err := accounts.Share(owner, notebookID, "editor", RoleOwner)
+	var validationError *errcode.ValidationError
+	AssertEquals(t, true, errors.As(err, &validationError), "there is only one owner")
+
+	accounts.Share(owner, notebookID, "editor", RoleEditor)
+
+	// Only the owner can share a notebook
+	_, err = accounts.Share(editor, notebookID, "viewer", RoleViewer)
+	var forbiddenError *errcode.ForbiddenError
+	AssertEquals(t, true, errors.As(err, &forbiddenError), "editor cannot share")
+
+	accounts.Share(owner, notebookID, "viewer", RoleViewer)
+	members, _ := accounts.Members(editor, notebookID)
+	AssertEquals(t, []Role{RoleEditor, RoleOwner, RoleViewer}, memberRoles(members), "member roles")
+
+	// Members can leave but cannot remove others
+	viewer := members[2]
+	err = accounts.Unshare(editor, notebookID, viewer.UserID)
+	AssertEquals(t, true, errors.As(err, &forbiddenError), "editor cannot unshare")
+	AssertEquals(t, nil, accounts.Unshare(editor, notebookID, editor.ID), "leave error")
+
+	members, _ = accounts.Members(owner, notebookID)
+	AssertEquals(t, []Role{RoleOwner, RoleViewer}, memberRoles(members), "member roles")
+}
+
+func TestServiceEnforcesRoles(t *testing.T) {
+	accounts := NewAccounts(newTestRepository())
+	owner, _ := accounts.Register("owner", "password")
+	viewer, _ := accounts.Register("viewer", "password")
+	service := NewService(newTestRepository(), WithAccounts(accounts))
+
+	notebook, err := service.As(owner).Create()
+	AssertEquals(t, nil, err, "service.Create error")
+	accounts.Share(owner, notebook.ID, "viewer", RoleViewer)
+
+	_, err = service.As(viewer).Fetch(notebook.ID)
+	AssertEquals(t, nil, err, "viewer service.Fetch error")
+
+	// Viewers can fetch a notebook but cannot change it
+	notebook.AddList("list0")
+	_, err = service.As(viewer).Update(notebook)
+	var forbiddenError *errcode.ForbiddenError
+	AssertEquals(t, true, errors.As(err, &forbiddenError), "viewer cannot update")
+
+	_, _, err = service.As(viewer).Sync(notebook.ID, nil, notebook)
+	AssertEquals(t, true, errors.As(err, &forbiddenError), "viewer cannot sync")
+
+	err = service.As(viewer).Remove(notebook.ID)
+	AssertEquals(t, true, errors.As(err, &forbiddenError), "viewer cannot remove")
+
+	err = service.As(viewer).Acknowledge(uuid.New(), notebook)
+	AssertEquals(t, true, errors.As(err, &forbiddenError), "viewer cannot acknowledge")
+
+	stored, _ := service.As(owner).Update(notebook)
+	AssertEquals(t, 1, len(stored.GetLists()), "len(stored.GetLists)")
+}
+
+func TestClaimNotebookWithoutOwner(t *testing.T) {
+	accounts := NewAccounts(newTestRepository())
+	owner, _ := accounts.Register("owner", "password")
+	other, _ := accounts.Register("other", "password")
+	service := NewService(newTestRepository(), WithAccounts(accounts))
+
+	// Notebooks stored before there were accounts have no owner
+	notebook, _ := service.Create()
+	_, err := service.As(owner).Fetch(notebook.ID)
+	var notFoundError *errcode.NotFoundError
+	AssertEquals(t, true, errors.As(err, &notFoundError), "notebook without owner is not found")
+
+	_, err = service.As(owner).Claim(uuid.New())
+	AssertEquals(t, true, errors.As(err, &notFoundError), "unknown notebook cannot be claimed")
+
+	claimed, err := service.As(owner).Claim(notebook.ID)
+	AssertEquals(t, nil, err, "service.Claim error")
+	AssertEquals(t, notebook.ID, claimed.ID, "claimed.ID")
+	_, err = service.As(owner).Claim(notebook.ID)
+	AssertEquals(t, nil, err, "repeated service.Claim error")
+
+	_, err = service.As(other).Claim(notebook.ID)
+	var forbiddenError *errcode.ForbiddenError
+	AssertEquals(t, true, errors.As(err, &forbiddenError), "other user cannot claim")
+
+	_, err = service.As(owner).Fetch(notebook.ID)
+	AssertEquals(t, nil, err, "owner service.Fetch error")
+}
+
+func TestServiceAttributesChangesToItsUser(t *testing.T) {
+	accounts := NewAccounts(newTestRepository())
+	owner, _ := accounts.Register("owner", "password")
+	repository := newTestRepository()
+	service := NewService(repository, WithAccounts(accounts))
+
+	notebook, _ := service.As(owner).Create()
+	AssertEquals(t, owner.ID, repository.changedBy[notebook.ID], "stored by owner")
+
+	notebook, _ = service.Create()
+	AssertEquals(t, uuid.Nil, repository.changedBy[notebook.ID], "stored by nobody")
+}
+
+func TestShareLinks(t *testing.T) {
+	now := time.Now()
+	repository := newTestRepository()
+	accounts := NewAccounts(repository)
+	accounts.now = func() time.Time { return now }
+	owner, _ := accounts.Register("owner", "password")
+	editor, _ := accounts.Register("editor", "password")
+	notebook, _ := solvent.NewNotebook()
+	list, _ := notebook.AddList("list0")
+	repository.Store(notebook)
+	notebookID := notebook.ID
+	accounts.Own(owner, notebookID)
+	accounts.Share(owner, notebookID, "editor", RoleEditor)
+
+	// Only the owner can publish a notebook
+	_, _, err := accounts.CreateShareLink(editor, notebookID, uuid.Nil, time.Time{})
+	var forbiddenError *errcode.ForbiddenError
+	AssertEquals(t, true, errors.As(err, &forbiddenError), "editor cannot create share links")
+
+	_, _, err = accounts.CreateShareLink(owner, notebookID, uuid.Nil, now.Add(-time.Hour))
+	var validationError *errcode.ValidationError
+	AssertEquals(t, true, errors.As(err, &validationError), "expiry in the past is invalid")
+
+	token, link, err := accounts.CreateShareLink(owner, notebookID, uuid.Nil, time.Time{})
+	AssertEquals(t, nil, err, "accounts.CreateShareLink error")
+	AssertNotEquals(t, token, link.TokenHash, "link.TokenHash")
+
+	_, _, err = accounts.CreateShareLink(owner, notebookID, uuid.New(), time.Time{})
+	AssertEquals(t, true, errors.As(err, &validationError), "unknown list is invalid")
+
+	listID := list.ID
+	expiringToken, _, _ := accounts.CreateShareLink(owner, notebookID, listID, now.Add(time.Hour))
+
+	resolved, err := accounts.ResolveShareLink(expiringToken)
+	AssertEquals(t, nil, err, "accounts.ResolveShareLink error")
+	AssertEquals(t, listID, resolved.ListID, "resolved.ListID")
+
+	// Links expire at their expiry time
+	now = now.Add(time.Hour)
+	_, err = accounts.ResolveShareLink(expiringToken)
+	var notFoundError *errcode.NotFoundError
+	AssertEquals(t, true, errors.As(err, &notFoundError), "expired link is not found")
+
+	links, _ := accounts.ShareLinks(owner, notebookID)
+	AssertEquals(t, []ShareLink{*link}, links, "links")
+
+	// Revoked links cannot be used anymore
+	AssertEquals(t, nil, accounts.RevokeShareLink(owner, notebookID, link.ID), "accounts.RevokeShareLink error")
+	_, err = accounts.ResolveShareLink(token)
+	AssertEquals(t, true, errors.As(err, &notFoundError), "revoked link is not found")
+
+	err = accounts.RevokeShareLink(owner, notebookID, link.ID)
+	AssertEquals(t, true, errors.As(err, &notFoundError), "revoked link cannot be revoked again")
+}
+
+func memberRoles(members []Member) []Role {
+	roles := []Role{}
+	for _, member := range members {
+		roles = append(roles, member.Role)
+	}
+
+	return roles
+}
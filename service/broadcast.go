@@ -0,0 +1,76 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Subscription notifies its owner whenever the stored state of the
+// subscribed notebook has changed. Notifications are coalesced, so a
+// subscriber has to fetch all the changes since the Version it has seen
+// last after receiving from C
+type Subscription struct {
+	NotebookID uuid.UUID
+	C          <-chan struct{}
+	c          chan struct{}
+}
+
+// Broadcaster keeps track of the Subscriptions to notebooks and
+// notifies them about changes
+type Broadcaster struct {
+	subscriptions map[uuid.UUID]map[*Subscription]struct{}
+	mutex         sync.Mutex
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{
+		subscriptions: map[uuid.UUID]map[*Subscription]struct{}{},
+	}
+}
+
+// Subscribe returns a new Subscription to the changes of the notebook
+// with the given ID
+func (b *Broadcaster) Subscribe(id uuid.UUID) *Subscription {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	c := make(chan struct{}, 1)
+	subscription := &Subscription{NotebookID: id, C: c, c: c}
+
+	subscriptions, ok := b.subscriptions[id]
+	if !ok {
+		subscriptions = map[*Subscription]struct{}{}
+		b.subscriptions[id] = subscriptions
+	}
+	subscriptions[subscription] = struct{}{}
+
+	return subscription
+}
+
+// Unsubscribe stops notifying the given Subscription
+func (b *Broadcaster) Unsubscribe(subscription *Subscription) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	subscriptions := b.subscriptions[subscription.NotebookID]
+	delete(subscriptions, subscription)
+	if len(subscriptions) == 0 {
+		delete(b.subscriptions, subscription.NotebookID)
+	}
+}
+
+// Publish notifies all the Subscriptions to the notebook with the given
+// ID without blocking on subscribers that have not received the
+// previous notification yet
+func (b *Broadcaster) Publish(id uuid.UUID) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for subscription := range b.subscriptions[id] {
+		select {
+		case subscription.c <- struct{}{}:
+		default:
+		}
+	}
+}
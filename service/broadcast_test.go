@@ -0,0 +1,52 @@
+package service
+
+import (
+	"testing"
+
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/google/uuid"
+)
+
+func TestBroadcaster(t *testing.T) {
+	broadcaster := NewBroadcaster()
+	id := uuid.New()
+	subscription0 := broadcaster.Subscribe(id)
+	subscription1 := broadcaster.Subscribe(id)
+	other := broadcaster.Subscribe(uuid.New())
+
+	// Notifications are coalesced instead of blocking the publisher
+	broadcaster.Publish(id)
+	broadcaster.Publish(id)
+	AssertEquals(t, 1, len(subscription0.C), "len(subscription0.C)")
+	AssertEquals(t, 1, len(subscription1.C), "len(subscription1.C)")
+	AssertEquals(t, 0, len(other.C), "len(other.C)")
+
+	<-subscription0.C
+	broadcaster.Unsubscribe(subscription0)
+	broadcaster.Publish(id)
+	AssertEquals(t, 0, len(subscription0.C), "len(subscription0.C)")
+}
+
+func TestUpdatePublishesChanges(t *testing.T) {
+	service := NewService(newTestRepository())
+	notebook, _ := service.Create()
+	version := service.Version(notebook.ID)
+	subscription := service.Subscribe(notebook.ID)
+	defer service.Unsubscribe(subscription)
+
+	// Updates without any changes are not published
+	service.Update(notebook)
+	AssertEquals(t, 0, len(subscription.C), "len(subscription.C)")
+
+	list, _ := notebook.AddList("list0")
+	service.Update(notebook)
+	AssertEquals(t, 1, len(subscription.C), "len(subscription.C)")
+
+	delta, newVersion, err := service.Changes(notebook.ID, &version)
+	AssertEquals(t, nil, err, "service.Changes error")
+	AssertEquals(t, service.Version(notebook.ID), newVersion, "newVersion")
+	AssertEquals(t, []uuid.UUID{list.ID}, listIDs(delta), "delta.GetLists")
+
+	delta, _, _ = service.Changes(notebook.ID, &newVersion)
+	AssertEquals(t, []uuid.UUID{}, listIDs(delta), "delta.GetLists")
+}
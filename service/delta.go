@@ -0,0 +1,133 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/eldelto/solvent"
+	"github.com/google/uuid"
+)
+
+// DefaultDeltaLogSize is the default number of deltas kept per notebook
+const DefaultDeltaLogSize = 100
+
+// Version identifies the state of a notebook a client has last received
+// from the server. The epoch changes whenever the server restarts as
+// the delta log is only kept in memory
+type Version struct {
+	Epoch   uuid.UUID
+	Counter uint64
+}
+
+// DeltaLog keeps the most recent deltas that have been merged into each
+// notebook so clients only need to receive the deltas they are missing
+// since the Version they have last seen
+type DeltaLog struct {
+	epoch     uuid.UUID
+	size      int
+	notebooks map[uuid.UUID]*notebookDeltas
+	mutex     sync.Mutex
+}
+
+// notebookDeltas holds the deltas with the counters from offset + 1 to
+// offset + len(deltas). A nil delta marks an update whose delta is not
+// known and forces a full sync
+type notebookDeltas struct {
+	offset uint64
+	deltas []*solvent.Notebook
+}
+
+func NewDeltaLog(size int) *DeltaLog {
+	return &DeltaLog{
+		epoch:     uuid.New(),
+		size:      size,
+		notebooks: map[uuid.UUID]*notebookDeltas{},
+	}
+}
+
+// Append records the delta that has been merged into the notebook with
+// the given ID and returns the new Version of the notebook
+func (l *DeltaLog) Append(id uuid.UUID, delta *solvent.Notebook) Version {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	log, ok := l.notebooks[id]
+	if !ok {
+		log = &notebookDeltas{}
+		l.notebooks[id] = log
+	}
+
+	log.deltas = append(log.deltas, delta)
+	if len(log.deltas) > l.size {
+		dropped := len(log.deltas) - l.size
+		log.deltas = log.deltas[dropped:]
+		log.offset += uint64(dropped)
+	}
+
+	return l.version(log)
+}
+
+// Current returns the current Version of the notebook with the given ID
+func (l *DeltaLog) Current(id uuid.UUID) Version {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	log, ok := l.notebooks[id]
+	if !ok {
+		return Version{Epoch: l.epoch}
+	}
+
+	return l.version(log)
+}
+
+// Since returns the deltas that have been merged into the notebook with
+// the given ID after the given Version except the one that created the
+// excluded Version. It returns false if the deltas are not known
+// anymore and the client has to receive the whole notebook instead
+func (l *DeltaLog) Since(id uuid.UUID, since, excluded Version) ([]*solvent.Notebook, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if since.Epoch != l.epoch {
+		return nil, false
+	}
+
+	log, ok := l.notebooks[id]
+	if !ok {
+		return []*solvent.Notebook{}, since.Counter == 0
+	}
+
+	current := l.version(log)
+	if since.Counter < log.offset || since.Counter > current.Counter {
+		return nil, false
+	}
+
+	deltas := []*solvent.Notebook{}
+	for i := since.Counter - log.offset; i < uint64(len(log.deltas)); i++ {
+		if excluded.Epoch == l.epoch && log.offset+i+1 == excluded.Counter {
+			continue
+		}
+
+		delta := log.deltas[i]
+		if delta == nil {
+			return nil, false
+		}
+		deltas = append(deltas, delta)
+	}
+
+	return deltas, true
+}
+
+// Forget drops all the deltas of the notebook with the given ID
+func (l *DeltaLog) Forget(id uuid.UUID) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.notebooks, id)
+}
+
+func (l *DeltaLog) version(log *notebookDeltas) Version {
+	return Version{
+		Epoch:   l.epoch,
+		Counter: log.offset + uint64(len(log.deltas)),
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/eldelto/solvent"
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/google/uuid"
+)
+
+func TestSync(t *testing.T) {
+	service := NewService(newTestRepository())
+	notebook, _ := service.Create()
+
+	// First sync without a version returns the whole notebook
+	client0, version0, err := service.Sync(notebook.ID, nil, emptyDelta(notebook))
+	AssertEquals(t, nil, err, "service.Sync error")
+	AssertEquals(t, uint64(0), version0.Counter, "version0.Counter")
+	client1, version1, _ := service.Sync(notebook.ID, nil, emptyDelta(notebook))
+
+	list, _ := client0.AddList("list0")
+	list.AddItem("item0")
+	_, version0, err = service.Sync(notebook.ID, &version0, client0.Delta())
+	AssertEquals(t, nil, err, "service.Sync error")
+	AssertEquals(t, uint64(1), version0.Counter, "version0.Counter")
+	client0.ResetDelta()
+
+	delta, version1, err := service.Sync(notebook.ID, &version1, client1.Delta())
+	AssertEquals(t, nil, err, "service.Sync error")
+	AssertEquals(t, version0, version1, "version1")
+	AssertEquals(t, []uuid.UUID{list.ID}, listIDs(delta), "delta.GetLists")
+
+	// Clients do not receive their own changes again
+	delta, _, _ = service.Sync(notebook.ID, &version0, client0.Delta())
+	AssertEquals(t, []uuid.UUID{}, listIDs(delta), "delta.GetLists")
+}
+
+func TestSyncWithUnknownVersion(t *testing.T) {
+	service := NewService(newTestRepository(), WithDeltaLogSize(1))
+	notebook, _ := service.Create()
+	client, version, _ := service.Sync(notebook.ID, nil, emptyDelta(notebook))
+
+	client.AddList("list0")
+	client.AddList("list1")
+	service.Update(client)
+
+	delta, _, err := service.Sync(notebook.ID, &version, emptyDelta(notebook))
+	AssertEquals(t, nil, err, "service.Sync error")
+	AssertEquals(t, 2, len(listIDs(delta)), "len(delta.GetLists)")
+
+	unknownVersion := Version{Epoch: uuid.New()}
+	delta, _, _ = service.Sync(notebook.ID, &unknownVersion, emptyDelta(notebook))
+	AssertEquals(t, 2, len(listIDs(delta)), "len(delta.GetLists)")
+}
+
+func TestApply(t *testing.T) {
+	service := NewService(newTestRepository())
+	notebook, _ := service.Create()
+	version := service.Version(notebook.ID)
+
+	var list *solvent.ToDoList
+	applied, err := service.Apply(notebook.ID, func(notebook *solvent.Notebook) error {
+		var err error
+		list, err = notebook.AddList("list0")
+		return err
+	})
+	AssertEquals(t, nil, err, "service.Apply error")
+	AssertEquals(t, []uuid.UUID{list.ID}, listIDs(applied), "applied.GetLists")
+
+	// Applied operations are synced like merged updates
+	delta, _, err := service.Changes(notebook.ID, &version)
+	AssertEquals(t, nil, err, "service.Changes error")
+	AssertEquals(t, []uuid.UUID{list.ID}, listIDs(delta), "delta.GetLists")
+
+	// Failed operations do not change the stored notebook
+	_, err = service.Apply(notebook.ID, func(notebook *solvent.Notebook) error {
+		notebook.RemoveList(list.ID)
+		_, err := notebook.GetList(list.ID)
+		return err
+	})
+	AssertNotEquals(t, nil, err, "service.Apply error")
+	stored, _ := service.Fetch(notebook.ID)
+	AssertEquals(t, []uuid.UUID{list.ID}, listIDs(stored), "stored.GetLists")
+}
+
+func emptyDelta(notebook *solvent.Notebook) *solvent.Notebook {
+	delta, _ := notebook.DeltaSince(notebook)
+	return delta
+}
+
+func listIDs(notebook *solvent.Notebook) []uuid.UUID {
+	ids := []uuid.UUID{}
+	for _, list := range notebook.GetLists() {
+		ids = append(ids, list.ID)
+	}
+
+	return ids
+}
@@ -77,3 +77,74 @@ func (e *UnknownError) Error() string {
 func (e *UnknownError) Unwrap() error {
 	return e.err
 }
+
+// UnauthorizedError indicates that a request could not be
+// authenticated
+type UnauthorizedError struct {
+	message string
+}
+
+func NewUnauthorizedError(message string) error {
+	return &UnauthorizedError{
+		message: message,
+	}
+}
+
+func (e *UnauthorizedError) Error() string {
+	return e.message
+}
+
+// ForbiddenError indicates that a user is not allowed to access the
+// notebook with the given ID
+type ForbiddenError struct {
+	UserID     uuid.UUID
+	NotebookID uuid.UUID
+	message    string
+}
+
+func NewForbiddenError(userID, notebookID uuid.UUID) error {
+	return &ForbiddenError{
+		UserID:     userID,
+		NotebookID: notebookID,
+		message:    fmt.Sprintf("user '%v' is not allowed to access notebook '%v'", userID, notebookID),
+	}
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.message
+}
+
+// ConflictError indicates that an entity of the given kind with the
+// given name already exists
+type ConflictError struct {
+	Kind    string
+	Name    string
+	message string
+}
+
+func NewConflictError(kind, name string) error {
+	return &ConflictError{
+		Kind:    kind,
+		Name:    name,
+		message: fmt.Sprintf("%s with name '%s' already exists", kind, name),
+	}
+}
+
+func (e *ConflictError) Error() string {
+	return e.message
+}
+
+// ValidationError indicates that a request contains invalid values
+type ValidationError struct {
+	message string
+}
+
+func NewValidationError(message string) error {
+	return &ValidationError{
+		message: message,
+	}
+}
+
+func (e *ValidationError) Error() string {
+	return e.message
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/eldelto/solvent"
+	"github.com/google/uuid"
+)
+
+// Compactor is implemented by Repositories that are able to drop the
+// stable tombstones of a stored notebook in a single atomic operation
+type Compactor interface {
+	Compact(id uuid.UUID, stable func(id uuid.UUID) bool) ([]uuid.UUID, error)
+}
+
+// TombstoneTracker keeps track of which replica has acknowledged which
+// tombstone of a notebook to decide when a tombstone is causally stable
+// and can be dropped.
+//
+// A replica acknowledges a tombstone by sending a notebook state that
+// contains it. A tombstone is stable once every replica that synced
+// within the horizon has acknowledged it or once the tombstone has been
+// known for longer than the horizon. Replicas that have not synced for
+// longer than the horizon are considered gone.
+//
+// The acknowledgements are only kept in memory, so after a restart
+// tombstones only become stable through acknowledgements once the
+// TombstoneTracker has been running for the horizon and every active
+// replica had the chance to sync again.
+//
+// The IDs of pruned tombstones are remembered, so when a replica that
+// still holds one of them syncs it back it is stable right away instead
+// of being tracked as a new tombstone again.
+type TombstoneTracker struct {
+	horizon   time.Duration
+	now       func() time.Time
+	startedAt time.Time
+	notebooks map[uuid.UUID]*notebookAcks
+	mutex     sync.Mutex
+}
+
+type notebookAcks struct {
+	replicas  map[uuid.UUID]time.Time
+	firstSeen map[uuid.UUID]time.Time
+	ackedBy   map[uuid.UUID]map[uuid.UUID]struct{}
+	pruned    map[uuid.UUID]struct{}
+}
+
+func NewTombstoneTracker(horizon time.Duration) *TombstoneTracker {
+	return newTombstoneTracker(horizon, time.Now)
+}
+
+func newTombstoneTracker(horizon time.Duration, now func() time.Time) *TombstoneTracker {
+	return &TombstoneTracker{
+		horizon:   horizon,
+		now:       now,
+		startedAt: now(),
+		notebooks: map[uuid.UUID]*notebookAcks{},
+	}
+}
+
+// Acknowledge records that the given replica holds all the tombstones
+// contained in the notebook
+func (t *TombstoneTracker) Acknowledge(replica uuid.UUID, notebook *solvent.Notebook) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+	acks := t.notebookAcks(notebook.ID)
+	acks.replicas[replica] = now
+
+	for _, id := range notebook.Tombstones() {
+		if _, ok := acks.pruned[id]; ok {
+			continue
+		}
+		if _, ok := acks.firstSeen[id]; !ok {
+			acks.firstSeen[id] = now
+		}
+
+		ackedBy, ok := acks.ackedBy[id]
+		if !ok {
+			ackedBy = map[uuid.UUID]struct{}{}
+			acks.ackedBy[id] = ackedBy
+		}
+		ackedBy[replica] = struct{}{}
+	}
+}
+
+// Observe records the tombstones of the given notebook and forgets the
+// tombstones that are no longer part of it
+func (t *TombstoneTracker) Observe(notebook *solvent.Notebook) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+	acks := t.notebookAcks(notebook.ID)
+
+	tombstones := map[uuid.UUID]struct{}{}
+	for _, id := range notebook.Tombstones() {
+		tombstones[id] = struct{}{}
+		if _, ok := acks.pruned[id]; ok {
+			continue
+		}
+		if _, ok := acks.firstSeen[id]; !ok {
+			acks.firstSeen[id] = now
+		}
+	}
+
+	for id := range acks.firstSeen {
+		if _, ok := tombstones[id]; !ok {
+			delete(acks.firstSeen, id)
+			delete(acks.ackedBy, id)
+		}
+	}
+
+	for replica, lastSeen := range acks.replicas {
+		if now.Sub(lastSeen) > t.horizon {
+			delete(acks.replicas, replica)
+		}
+	}
+}
+
+// IsStable reports whether the tombstone with the given ID of the given
+// notebook can be dropped safely
+func (t *TombstoneTracker) IsStable(notebookID, id uuid.UUID) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	acks, ok := t.notebooks[notebookID]
+	if !ok {
+		return false
+	}
+
+	if _, ok := acks.pruned[id]; ok {
+		return true
+	}
+	firstSeen, ok := acks.firstSeen[id]
+	if !ok {
+		return false
+	}
+
+	now := t.now()
+	if now.Sub(firstSeen) >= t.horizon {
+		return true
+	}
+
+	if now.Sub(t.startedAt) < t.horizon || len(acks.replicas) == 0 {
+		return false
+	}
+
+	ackedBy := acks.ackedBy[id]
+	for replica, lastSeen := range acks.replicas {
+		if now.Sub(lastSeen) > t.horizon {
+			continue
+		}
+
+		if _, ok := ackedBy[replica]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Pruned records that the tombstones with the given IDs have been
+// dropped from the notebook and forgets their acknowledgements
+func (t *TombstoneTracker) Pruned(notebookID uuid.UUID, ids []uuid.UUID) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	acks := t.notebookAcks(notebookID)
+	for _, id := range ids {
+		delete(acks.firstSeen, id)
+		delete(acks.ackedBy, id)
+		acks.pruned[id] = struct{}{}
+	}
+}
+
+// Notebooks returns the IDs of all the notebooks that have tracked
+// tombstones
+func (t *TombstoneTracker) Notebooks() []uuid.UUID {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	ids := []uuid.UUID{}
+	for id, acks := range t.notebooks {
+		if len(acks.firstSeen) > 0 {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// Forget drops all the tracked data of the notebook with the given ID
+func (t *TombstoneTracker) Forget(notebookID uuid.UUID) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.notebooks, notebookID)
+}
+
+func (t *TombstoneTracker) notebookAcks(notebookID uuid.UUID) *notebookAcks {
+	acks, ok := t.notebooks[notebookID]
+	if !ok {
+		acks = &notebookAcks{
+			replicas:  map[uuid.UUID]time.Time{},
+			firstSeen: map[uuid.UUID]time.Time{},
+			ackedBy:   map[uuid.UUID]map[uuid.UUID]struct{}{},
+			pruned:    map[uuid.UUID]struct{}{},
+		}
+		t.notebooks[notebookID] = acks
+	}
+
+	return acks
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eldelto/solvent"
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/google/uuid"
+)
+
+const horizon = time.Hour
+
+var replica0 = uuid.UUID{15: 1}
+var replica1 = uuid.UUID{15: 2}
+
+type testTime struct {
+	now time.Time
+}
+
+func (t *testTime) Now() time.Time {
+	return t.now
+}
+
+func (t *testTime) advance(d time.Duration) {
+	t.now = t.now.Add(d)
+}
+
+func newTestNotebook() (*solvent.Notebook, uuid.UUID) {
+	notebook, _ := solvent.NewNotebook()
+	list, _ := notebook.AddList("list0")
+	itemID, _ := list.AddItem("item0")
+	list.RemoveItem(itemID)
+
+	return notebook, itemID
+}
+
+func TestTombstoneStableAfterAllAcks(t *testing.T) {
+	clock := &testTime{now: time.Unix(0, 0)}
+	tracker := newTombstoneTracker(horizon, clock.Now)
+	clock.advance(horizon)
+
+	notebook, itemID := newTestNotebook()
+	tracker.Acknowledge(replica0, notebook)
+	tracker.Acknowledge(replica1, &solvent.Notebook{ID: notebook.ID, ToDoLists: solvent.NewToDoListPSet()})
+	tracker.Observe(notebook)
+	AssertEquals(t, false, tracker.IsStable(notebook.ID, itemID), "tracker.IsStable")
+
+	tracker.Acknowledge(replica1, notebook)
+	AssertEquals(t, true, tracker.IsStable(notebook.ID, itemID), "tracker.IsStable")
+}
+
+func TestTombstoneStableAfterHorizon(t *testing.T) {
+	clock := &testTime{now: time.Unix(0, 0)}
+	tracker := newTombstoneTracker(horizon, clock.Now)
+
+	notebook, itemID := newTestNotebook()
+	tracker.Observe(notebook)
+	AssertEquals(t, false, tracker.IsStable(notebook.ID, itemID), "tracker.IsStable")
+
+	clock.advance(horizon)
+	AssertEquals(t, true, tracker.IsStable(notebook.ID, itemID), "tracker.IsStable")
+}
+
+func TestTombstoneNotStableRightAfterStart(t *testing.T) {
+	clock := &testTime{now: time.Unix(0, 0)}
+	tracker := newTombstoneTracker(horizon, clock.Now)
+
+	notebook, itemID := newTestNotebook()
+	tracker.Acknowledge(replica0, notebook)
+	AssertEquals(t, false, tracker.IsStable(notebook.ID, itemID), "tracker.IsStable")
+}
+
+func TestUpdateCompactsStableTombstones(t *testing.T) {
+	clock := &testTime{now: time.Unix(0, 0)}
+	service := NewService(newTestRepository())
+	service.tracker = newTombstoneTracker(horizon, clock.Now)
+	clock.advance(horizon)
+
+	notebook, itemID := newTestNotebook()
+	service.repository.Store(notebook)
+
+	service.Acknowledge(replica0, notebook)
+	merged, err := service.Update(notebook)
+	AssertEquals(t, nil, err, "service.Update error")
+	AssertEquals(t, []uuid.UUID{}, merged.Tombstones(), "merged.Tombstones")
+
+	list := merged.GetLists()[0]
+	_, err = list.GetItem(itemID)
+	AssertNotEquals(t, nil, err, "list.GetItem error")
+}
+
+func TestCompactStoredNotebook(t *testing.T) {
+	clock := &testTime{now: time.Unix(0, 0)}
+	service := NewService(newTestRepository())
+	service.tracker = newTombstoneTracker(horizon, clock.Now)
+
+	notebook, itemID := newTestNotebook()
+	service.repository.Store(notebook)
+	service.Update(notebook)
+	AssertEquals(t, []uuid.UUID{itemID}, notebook.Tombstones(), "notebook.Tombstones")
+
+	clock.advance(horizon)
+	err := service.CollectGarbage()
+	AssertEquals(t, nil, err, "service.CollectGarbage error")
+
+	stored, _ := service.Fetch(notebook.ID)
+	AssertEquals(t, []uuid.UUID{}, stored.Tombstones(), "stored.Tombstones")
+	AssertEquals(t, []uuid.UUID{}, service.tracker.Notebooks(), "tracker.Notebooks")
+}
+
+func TestPrunedTombstonesAreNotSyncedBack(t *testing.T) {
+	clock := &testTime{now: time.Unix(0, 0)}
+	service := NewService(newTestRepository())
+	service.tracker = newTombstoneTracker(horizon, clock.Now)
+
+	notebook, _ := newTestNotebook()
+	service.repository.Store(notebook)
+	service.Update(notebook)
+	clock.advance(horizon)
+	service.CollectGarbage()
+
+	// A replica that has not pruned the tombstone yet sends it again
+	merged, err := service.Update(notebook)
+	AssertEquals(t, nil, err, "service.Update error")
+	AssertEquals(t, []uuid.UUID{}, merged.Tombstones(), "merged.Tombstones")
+	AssertEquals(t, []uuid.UUID{}, service.tracker.Notebooks(), "tracker.Notebooks")
+}
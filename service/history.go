@@ -0,0 +1,205 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/eldelto/solvent"
+	"github.com/eldelto/solvent/service/errcode"
+	"github.com/google/uuid"
+)
+
+// Revision is a state of a notebook that has been recorded right after
+// it was created or changed
+type Revision struct {
+	NotebookID uuid.UUID
+	Number     int64
+	// Notebook is nil if only the list of revisions has been fetched
+	Notebook  *solvent.Notebook
+	CreatedAt time.Time
+}
+
+// RevisionRepository is implemented by Repositories that keep the
+// history of the stored notebooks. Store records the first revision of
+// a notebook and Modify a new one whenever the update function changes
+// its lists or items, both within the same transaction as the notebook
+// itself. The numbers of the revisions increase with every stored
+// revision but are not necessarily consecutive
+type RevisionRepository interface {
+	// FetchRevisions returns all the revisions of the notebook without
+	// their notebooks, ordered by their numbers
+	FetchRevisions(notebookID uuid.UUID) ([]Revision, error)
+	// FetchRevision returns the revision with the given number or a
+	// NotFoundError if the notebook has no such revision
+	FetchRevision(notebookID uuid.UUID, number int64) (*Revision, error)
+}
+
+// History returns the revisions of the notebook with the given ID
+// without their notebooks. Notebooks have no history if the Repository
+// does not implement the RevisionRepository interface
+func (s *Service) History(id uuid.UUID) ([]Revision, error) {
+	if err := s.authorize(id, RoleViewer); err != nil {
+		return nil, err
+	}
+
+	revisions, ok := s.repository.(RevisionRepository)
+	if !ok {
+		if _, err := s.repository.Fetch(id); err != nil {
+			return nil, err
+		}
+		return []Revision{}, nil
+	}
+
+	return revisions.FetchRevisions(id)
+}
+
+// Revision returns the revision with the given number of the notebook
+// with the given ID
+func (s *Service) Revision(id uuid.UUID, number int64) (*Revision, error) {
+	if err := s.authorize(id, RoleViewer); err != nil {
+		return nil, err
+	}
+
+	return s.revision(id, number)
+}
+
+func (s *Service) revision(id uuid.UUID, number int64) (*Revision, error) {
+	revisions, ok := s.repository.(RevisionRepository)
+	if !ok {
+		return nil, errcode.NewNotFoundError("revision of notebook", id)
+	}
+
+	return revisions.FetchRevision(id, number)
+}
+
+// Restore re-creates the lists and items of the revision with the given
+// number that have been removed from the notebook with the given ID
+// since. Lists and items that can not be re-added with their original
+// IDs, as they are backed by a 2P-Set, are re-created under new IDs,
+// which are recorded in the RestoreLog. Lists and items that still
+// exist or whose re-created ones still exist are left as they are, so
+// restoring the same revision again does not change the notebook
+func (s *Service) Restore(id uuid.UUID, number int64) (*solvent.Notebook, error) {
+	if err := s.authorize(id, RoleEditor); err != nil {
+		return nil, err
+	}
+
+	revision, err := s.revision(id, number)
+	if err != nil {
+		return nil, err
+	}
+
+	var recreated map[uuid.UUID]uuid.UUID
+	notebook, err := s.apply(id, func(notebook *solvent.Notebook) error {
+		recreated = s.restores.Recreated(id)
+		return restoreRevision(notebook, revision.Notebook, recreated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.restores.Record(id, recreated)
+
+	return notebook, nil
+}
+
+// restoreRevision re-creates the lists and items of the revision that
+// are missing from the notebook. The lists and items re-created under
+// new IDs are added to the given map of the re-created IDs by their
+// original ones
+func restoreRevision(notebook, revision *solvent.Notebook, recreated map[uuid.UUID]uuid.UUID) error {
+	for _, oldList := range revision.GetLists() {
+		list, err := notebook.GetList(oldList.ID)
+		if err != nil {
+			list, err = notebook.GetList(recreated[oldList.ID])
+		}
+		if err == nil {
+			if err := restoreItems(notebook, list, oldList.GetItems(), recreated); err != nil {
+				return err
+			}
+			continue
+		}
+
+		applied, err := notebook.ApplyOperation(solvent.OperationRecord{
+			Kind:   solvent.RestoreListOperation,
+			ListID: oldList.ID,
+			List:   oldList,
+		})
+		if err != nil {
+			return errcode.NewNotebookError(notebook.ID, err, "could not restore list")
+		}
+		if applied.ListID != oldList.ID {
+			recreated[oldList.ID] = applied.ListID
+		}
+	}
+
+	return nil
+}
+
+func restoreItems(notebook *solvent.Notebook, list *solvent.ToDoList, items []solvent.ToDoItem, recreated map[uuid.UUID]uuid.UUID) error {
+	for i := range items {
+		if _, err := list.GetItem(items[i].ID); err == nil {
+			continue
+		}
+		if _, err := list.GetItem(recreated[items[i].ID]); err == nil {
+			continue
+		}
+
+		applied, err := notebook.ApplyOperation(solvent.OperationRecord{
+			Kind:   solvent.RestoreItemOperation,
+			ListID: list.ID,
+			ItemID: items[i].ID,
+			Item:   &items[i],
+		})
+		if err != nil {
+			return errcode.NewNotebookError(list.ID, err, "could not restore item")
+		}
+		if applied.ItemID != items[i].ID {
+			recreated[items[i].ID] = applied.ItemID
+		}
+	}
+
+	return nil
+}
+
+// RestoreLog keeps the IDs of the lists and items that have been
+// re-created under new IDs by restoring a revision, by their original
+// IDs per notebook. Like the UndoLog it is only kept in memory
+type RestoreLog struct {
+	recreated map[uuid.UUID]map[uuid.UUID]uuid.UUID
+	mutex     sync.Mutex
+}
+
+func NewRestoreLog() *RestoreLog {
+	return &RestoreLog{recreated: map[uuid.UUID]map[uuid.UUID]uuid.UUID{}}
+}
+
+// Recreated returns a copy of the re-created IDs of the lists and items
+// of the notebook by their original IDs
+func (l *RestoreLog) Recreated(notebookID uuid.UUID) map[uuid.UUID]uuid.UUID {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	recreated := map[uuid.UUID]uuid.UUID{}
+	for original, id := range l.recreated[notebookID] {
+		recreated[original] = id
+	}
+
+	return recreated
+}
+
+// Record replaces the re-created IDs of the lists and items of the
+// notebook
+func (l *RestoreLog) Record(notebookID uuid.UUID, recreated map[uuid.UUID]uuid.UUID) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.recreated[notebookID] = recreated
+}
+
+// Forget drops the re-created IDs of the notebook with the given ID
+func (l *RestoreLog) Forget(notebookID uuid.UUID) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.recreated, notebookID)
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/eldelto/solvent"
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/eldelto/solvent/service/errcode"
+	"github.com/google/uuid"
+)
+
+func TestHistory(t *testing.T) {
+	service := NewService(newTestRepository())
+	notebook, _ := service.Create()
+
+	notebook.AddList("list0")
+	service.Update(notebook)
+	// Updates without any changes do not add a revision
+	service.Update(notebook)
+	service.Apply(notebook.ID, func(notebook *solvent.Notebook) error {
+		_, err := notebook.AddList("list1")
+		return err
+	})
+
+	revisions, err := service.History(notebook.ID)
+	AssertEquals(t, nil, err, "service.History error")
+	AssertEquals(t, 3, len(revisions), "len(revisions)")
+	AssertEquals(t, (*solvent.Notebook)(nil), revisions[0].Notebook, "listed revision notebook")
+
+	revision, err := service.Revision(notebook.ID, revisions[1].Number)
+	AssertEquals(t, nil, err, "service.Revision error")
+	AssertEquals(t, 1, len(revision.Notebook.GetLists()), "len(revision.GetLists)")
+
+	_, err = service.Revision(notebook.ID, 42)
+	var notFoundError *errcode.NotFoundError
+	AssertEquals(t, true, errors.As(err, &notFoundError), "unknown revision")
+}
+
+func TestHistoryWithoutRevisionRepository(t *testing.T) {
+	// Embedding the fake hides all the methods beyond the Repository ones
+	service := NewService(struct{ Repository }{newTestRepository()})
+	notebook, _ := service.Create()
+
+	revisions, err := service.History(notebook.ID)
+	AssertEquals(t, nil, err, "service.History error")
+	AssertEquals(t, 0, len(revisions), "len(revisions)")
+
+	_, err = service.History(uuid.New())
+	var notFoundError *errcode.NotFoundError
+	AssertEquals(t, true, errors.As(err, &notFoundError), "unknown notebook")
+}
+
+func TestRestore(t *testing.T) {
+	for name, options := range map[string][]solvent.NotebookOption{
+		"2P-Sets": nil,
+		"OR-Sets": {solvent.WithORSets()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			repository := newTestRepository()
+			service := NewService(repository)
+			notebook, _ := solvent.NewNotebook(options...)
+			repository.Store(notebook)
+
+			list0, _ := notebook.AddList("list0")
+			list0.AddItem("item0")
+			checkedID, _ := list0.AddItem("item1")
+			list0.CheckItem(checkedID)
+			list1, _ := notebook.AddList("list1")
+			list1.AddItem("item2")
+			service.Update(notebook)
+			revisions, _ := service.History(notebook.ID)
+			latest := revisions[len(revisions)-1]
+
+			notebook.RemoveList(list0.ID)
+			list1.RemoveItem(list1.GetItems()[0].ID)
+			list1.Rename("renamed")
+			service.Update(notebook)
+
+			restored, err := service.Restore(notebook.ID, latest.Number)
+			AssertEquals(t, nil, err, "service.Restore error")
+
+			titles := map[string][]solvent.ToDoItem{}
+			for _, list := range restored.GetLists() {
+				titles[list.Title.Value] = list.GetItems()
+			}
+			AssertEquals(t, 2, len(titles), "len(restored.GetLists)")
+			AssertEquals(t, 1, len(titles["renamed"]), "items of the kept list")
+			AssertEquals(t, "item2", titles["renamed"][0].Title.Value, "restored item")
+			AssertEquals(t, 2, len(titles["list0"]), "items of the restored list")
+			AssertEquals(t, "item1", titles["list0"][1].Title.Value, "restored item")
+			AssertEquals(t, true, titles["list0"][1].Checked.Value, "restored item checked")
+
+			// Restoring the same revision again does not duplicate anything
+			again, err := service.Restore(notebook.ID, latest.Number)
+			AssertEquals(t, nil, err, "service.Restore error")
+			AssertEquals(t, 2, len(again.GetLists()), "len(GetLists) after restoring twice")
+			for _, list := range again.GetLists() {
+				AssertEquals(t, len(titles[list.Title.Value]), len(list.GetItems()), "items of "+list.Title.Value+" after restoring twice")
+			}
+		})
+	}
+}
+
+func TestRestoreWithSameTitle(t *testing.T) {
+	for name, options := range map[string][]solvent.NotebookOption{
+		"2P-Sets": nil,
+		"OR-Sets": {solvent.WithORSets()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			repository := newTestRepository()
+			service := NewService(repository)
+			notebook, _ := solvent.NewNotebook(options...)
+			repository.Store(notebook)
+
+			kept, _ := notebook.AddList("kept")
+			kept.AddItem("item0")
+			itemID, _ := kept.AddItem("item1")
+			removed, _ := notebook.AddList("removed")
+			removed.AddItem("item2")
+			service.Update(notebook)
+			revisions, _ := service.History(notebook.ID)
+			latest := revisions[len(revisions)-1]
+
+			// New lists and items with the same titles get the same
+			// positions as the removed ones
+			kept.RemoveItem(itemID)
+			kept.AddItem("item1")
+			notebook.RemoveList(removed.ID)
+			added, _ := notebook.AddList("removed")
+			service.Update(notebook)
+
+			for i := 0; i < 2; i++ {
+				restored, err := service.Restore(notebook.ID, latest.Number)
+				AssertEquals(t, nil, err, "service.Restore error")
+
+				items := map[uuid.UUID]int{}
+				for _, list := range restored.GetLists() {
+					items[list.ID] = len(list.GetItems())
+				}
+				AssertEquals(t, 3, len(items), "len(restored.GetLists)")
+				AssertEquals(t, 3, items[kept.ID], "items of the kept list")
+				AssertEquals(t, 0, items[added.ID], "items of the added list")
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/eldelto/solvent"
 	"github.com/eldelto/solvent/service/errcode"
@@ -11,20 +13,118 @@ import (
 type Repository interface {
 	Store(notbook *solvent.Notebook) error
 	Update(notebook *solvent.Notebook) error
+	// Modify atomically replaces the stored notebook with the given ID
+	// by the result of the update function, so no concurrent update
+	// of the notebook can get lost in between. The update function may
+	// be called more than once if the Repository retries the update
+	Modify(id uuid.UUID, update UpdateFunc) (*solvent.Notebook, error)
 	Fetch(id uuid.UUID) (*solvent.Notebook, error)
 	Remove(id uuid.UUID) error
 }
 
+// ActorRepository is implemented by Repositories that record which user
+// has made a change. As returns a Repository that attributes all the
+// changes made through it to the user with the given ID
+type ActorRepository interface {
+	As(userID uuid.UUID) Repository
+}
+
+// UpdateFunc returns the new state of a notebook based on its currently
+// stored state. Repositories compare both states to tell whether the
+// notebook has changed, so it returns a changed copy instead of changing
+// the stored notebook in place
+type UpdateFunc func(stored *solvent.Notebook) (*solvent.Notebook, error)
+
 type Service struct {
-	repository Repository
+	repository  Repository
+	tracker     *TombstoneTracker
+	deltas      *DeltaLog
+	undo        *UndoLog
+	restores    *RestoreLog
+	broadcaster *Broadcaster
+	accounts    *Accounts
+	// user is the User the Service acts on behalf of or nil if it is
+	// not restricted to the notebooks of any user
+	user *User
 }
 
-func NewService(repository Repository) Service {
-	return Service{
-		repository: repository,
+// ServiceOption configures optional behaviour of a Service
+type ServiceOption func(s *Service)
+
+// WithTombstoneGC enables the garbage collection of tombstones that
+// have either been acknowledged by every active replica or are older
+// than the given horizon
+func WithTombstoneGC(horizon time.Duration) ServiceOption {
+	return func(s *Service) {
+		s.tracker = NewTombstoneTracker(horizon)
 	}
 }
 
+// WithDeltaLogSize sets the number of deltas that are kept per notebook
+// for clients syncing with deltas
+func WithDeltaLogSize(size int) ServiceOption {
+	return func(s *Service) {
+		s.deltas = NewDeltaLog(size)
+	}
+}
+
+// WithUndoDepth sets the number of operations each user can undo per
+// notebook
+func WithUndoDepth(depth int) ServiceOption {
+	return func(s *Service) {
+		s.undo = NewUndoLog(depth)
+	}
+}
+
+// WithAccounts restricts the Service returned by As to the notebooks the
+// user has access to according to the given Accounts
+func WithAccounts(accounts *Accounts) ServiceOption {
+	return func(s *Service) {
+		s.accounts = accounts
+	}
+}
+
+func NewService(repository Repository, options ...ServiceOption) Service {
+	service := Service{
+		repository:  repository,
+		deltas:      NewDeltaLog(DefaultDeltaLogSize),
+		undo:        NewUndoLog(DefaultUndoDepth),
+		restores:    NewRestoreLog(),
+		broadcaster: NewBroadcaster(),
+	}
+	for _, option := range options {
+		option(&service)
+	}
+
+	return service
+}
+
+// As returns a Service that acts on behalf of the given User. It shares
+// all its state with the original Service but only allows the user to
+// fetch the notebooks it can view, to change the ones it can edit and
+// to remove the ones it owns. New notebooks are owned by the user and
+// Repositories that implement the ActorRepository interface attribute
+// the changes to the user
+func (s *Service) As(user *User) *Service {
+	scoped := *s
+	scoped.user = user
+	if actor, ok := s.repository.(ActorRepository); ok && user != nil {
+		scoped.repository = actor.As(user.ID)
+	}
+
+	return &scoped
+}
+
+// authorize returns a ForbiddenError if the user of the Service does
+// not have at least the required Role for the notebook with the given ID
+func (s *Service) authorize(id uuid.UUID, required Role) error {
+	if s.accounts == nil || s.user == nil {
+		return nil
+	}
+
+	return s.accounts.Authorize(s.user, id, required)
+}
+
 // TODO: Wrap returned errors with custom ones
 func (s *Service) Create() (*solvent.Notebook, error) {
 	notebook, err := solvent.NewNotebook()
@@ -38,33 +138,312 @@ func (s *Service) Create() (*solvent.Notebook, error) {
 		return nil, err
 	}
 
+	if s.accounts != nil && s.user != nil {
+		if err := s.accounts.Own(s.user, notebook.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return notebook, nil
+}
+
+// Claim makes the user of the Service the owner of the stored notebook
+// with the given ID if it has no owner yet. Before there were accounts
+// everyone who knew the ID of a notebook had access to it, so knowing
+// the ID is enough to claim such a notebook
+func (s *Service) Claim(id uuid.UUID) (*solvent.Notebook, error) {
+	if s.accounts == nil || s.user == nil {
+		return nil, errcode.NewValidationError("notebooks can only be claimed by users")
+	}
+
+	notebook, err := s.repository.Fetch(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.accounts.Claim(s.user, id); err != nil {
+		return nil, err
+	}
+
 	return notebook, nil
 }
 
 func (s *Service) Fetch(id uuid.UUID) (*solvent.Notebook, error) {
+	if err := s.authorize(id, RoleViewer); err != nil {
+		return nil, err
+	}
+
 	return s.repository.Fetch(id)
 }
 
 func (s *Service) Update(notebook *solvent.Notebook) (*solvent.Notebook, error) {
-	oldNotebook, err := s.Fetch(notebook.ID)
-	if err != nil {
+	if err := s.authorize(notebook.ID, RoleEditor); err != nil {
 		return nil, err
 	}
 
-	merged, err := oldNotebook.Merge(notebook)
+	oldNotebook, mergedNotebook, err := s.merge(notebook.ID, notebook)
 	if err != nil {
-		return nil, errcode.NewNotebookError(notebook.ID, err, "could not merge with old notebook")
+		return nil, err
+	}
+	if _, ok := s.appendDelta(oldNotebook, mergedNotebook); ok {
+		s.broadcaster.Publish(notebook.ID)
+	}
+
+	return mergedNotebook, nil
+}
+
+// NotebookOperation changes a notebook locally the same way a client would,
+// e.g. by adding a list or checking an item
+type NotebookOperation func(notebook *solvent.Notebook) error
+
+// Apply atomically applies the given NotebookOperation to the stored notebook
+// with the given ID and returns the new state of the notebook. Its
+// changes are recorded and published like the ones of a merged update
+func (s *Service) Apply(id uuid.UUID, operation NotebookOperation) (*solvent.Notebook, error) {
+	if err := s.authorize(id, RoleEditor); err != nil {
+		return nil, err
 	}
-	mergedNotebook := merged.(*solvent.Notebook)
 
-	err = s.repository.Update(mergedNotebook)
+	return s.apply(id, operation)
+}
+
+func (s *Service) apply(id uuid.UUID, operation NotebookOperation) (*solvent.Notebook, error) {
+	var oldNotebook *solvent.Notebook
+	newNotebook, err := s.repository.Modify(id, func(stored *solvent.Notebook) (*solvent.Notebook, error) {
+		updated, err := stored.Copy()
+		if err != nil {
+			return nil, errcode.NewNotebookError(id, err, "could not copy notebook")
+		}
+		if err := operation(updated); err != nil {
+			return nil, err
+		}
+		s.compact(updated)
+		oldNotebook = stored
+
+		return updated, nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	if _, ok := s.appendDelta(oldNotebook, newNotebook); ok {
+		s.broadcaster.Publish(id)
+	}
 
-	return mergedNotebook, nil
+	return newNotebook, nil
+}
+
+// Sync merges the given delta into the stored notebook with the given
+// ID and returns the delta the client is missing since the given
+// Version together with the new Version of the notebook. The whole
+// notebook is returned instead if no Version is given or the missing
+// deltas are not known anymore
+func (s *Service) Sync(id uuid.UUID, since *Version, delta *solvent.Notebook) (*solvent.Notebook, Version, error) {
+	if err := s.authorize(id, RoleEditor); err != nil {
+		return nil, Version{}, err
+	}
+
+	oldNotebook, mergedNotebook, err := s.merge(id, delta)
+	if err != nil {
+		return nil, Version{}, err
+	}
+	// The changes of the client's own delta are not sent back to it
+	excluded, ok := s.appendDelta(oldNotebook, mergedNotebook)
+	version := excluded
+	if ok {
+		s.broadcaster.Publish(id)
+	} else {
+		version = s.deltas.Current(id)
+	}
+
+	responseDelta, err := s.changesSince(mergedNotebook, since, excluded)
+	if err != nil {
+		return nil, Version{}, err
+	}
+
+	return responseDelta, version, nil
+}
+
+// Changes returns the delta of the stored notebook with the given ID
+// since the given Version together with the current Version of the
+// notebook, the same way as Sync but without merging anything into it
+func (s *Service) Changes(id uuid.UUID, since *Version) (*solvent.Notebook, Version, error) {
+	// The Version is read first, so changes that happen in between are
+	// sent again instead of being skipped
+	version := s.deltas.Current(id)
+	notebook, err := s.Fetch(id)
+	if err != nil {
+		return nil, Version{}, err
+	}
+
+	delta, err := s.changesSince(notebook, since, Version{})
+	if err != nil {
+		return nil, Version{}, err
+	}
+
+	return delta, version, nil
+}
+
+// changesSince returns the merged deltas since the given Version except
+// the excluded one or the whole notebook if no Version is given or the
+// missing deltas are not known anymore
+func (s *Service) changesSince(notebook *solvent.Notebook, since *Version, excluded Version) (*solvent.Notebook, error) {
+	if since == nil {
+		return notebook, nil
+	}
+
+	deltas, ok := s.deltas.Since(notebook.ID, *since, excluded)
+	if !ok {
+		return notebook, nil
+	}
+
+	// An empty delta is backed by the same kind of sets as the stored
+	// notebook
+	responseDelta, _ := notebook.DeltaSince(notebook)
+	for _, delta := range deltas {
+		merged, err := responseDelta.Merge(delta)
+		if err != nil {
+			return nil, errcode.NewNotebookError(notebook.ID, err, "could not merge deltas")
+		}
+		responseDelta = merged.(*solvent.Notebook)
+	}
+
+	return responseDelta, nil
+}
+
+// Version returns the current Version of the notebook with the given ID
+func (s *Service) Version(id uuid.UUID) Version {
+	return s.deltas.Current(id)
+}
+
+// Subscribe returns a Subscription that is notified whenever the
+// stored state of the notebook with the given ID changes
+func (s *Service) Subscribe(id uuid.UUID) *Subscription {
+	return s.broadcaster.Subscribe(id)
+}
+
+// Unsubscribe stops notifying the given Subscription
+func (s *Service) Unsubscribe(subscription *Subscription) {
+	s.broadcaster.Unsubscribe(subscription)
 }
 
 func (s *Service) Remove(id uuid.UUID) error {
-	return s.repository.Remove(id)
+	if err := s.authorize(id, RoleOwner); err != nil {
+		return err
+	}
+
+	if err := s.repository.Remove(id); err != nil {
+		return err
+	}
+
+	if s.tracker != nil {
+		s.tracker.Forget(id)
+	}
+	s.deltas.Forget(id)
+	s.undo.Forget(id)
+	s.restores.Forget(id)
+	s.broadcaster.Publish(id)
+
+	return nil
+}
+
+// Acknowledge records that the replica with the given ID holds all the
+// tombstones of the given notebook state. Only editors of the notebook
+// can acknowledge tombstones, as acknowledgements allow to drop them
+func (s *Service) Acknowledge(replica uuid.UUID, notebook *solvent.Notebook) error {
+	if err := s.authorize(notebook.ID, RoleEditor); err != nil {
+		return err
+	}
+	if s.tracker == nil {
+		return nil
+	}
+
+	s.tracker.Acknowledge(replica, notebook)
+	return nil
+}
+
+// Compact drops the stable tombstones of the stored notebook with the
+// given ID and returns their IDs. Stored notebooks are only compacted
+// if the Repository implements the Compactor interface
+func (s *Service) Compact(id uuid.UUID) ([]uuid.UUID, error) {
+	compactor, ok := s.repository.(Compactor)
+	if s.tracker == nil || !ok {
+		return []uuid.UUID{}, nil
+	}
+
+	pruned, err := compactor.Compact(id, func(tombstoneID uuid.UUID) bool {
+		return s.tracker.IsStable(id, tombstoneID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.tracker.Pruned(id, pruned)
+
+	return pruned, nil
+}
+
+// CollectGarbage compacts all the notebooks with tracked tombstones
+func (s *Service) CollectGarbage() error {
+	if s.tracker == nil {
+		return nil
+	}
+
+	for _, id := range s.tracker.Notebooks() {
+		_, err := s.Compact(id)
+		var notFoundError *errcode.NotFoundError
+		if errors.As(err, &notFoundError) {
+			s.tracker.Forget(id)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// merge atomically merges the given notebook into the stored one and
+// returns the previously stored and the merged state
+func (s *Service) merge(id uuid.UUID, notebook *solvent.Notebook) (*solvent.Notebook, *solvent.Notebook, error) {
+	var oldNotebook *solvent.Notebook
+	mergedNotebook, err := s.repository.Modify(id, func(stored *solvent.Notebook) (*solvent.Notebook, error) {
+		merged, err := stored.Merge(notebook)
+		if err != nil {
+			return nil, errcode.NewNotebookError(id, err, "could not merge with old notebook")
+		}
+		mergedNotebook := merged.(*solvent.Notebook)
+		s.compact(mergedNotebook)
+		oldNotebook = stored
+
+		return mergedNotebook, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return oldNotebook, mergedNotebook, nil
+}
+
+// appendDelta records the changes between the old and the new state of
+// a notebook in the DeltaLog and returns the new Version if there have
+// been any changes
+func (s *Service) appendDelta(oldNotebook, newNotebook *solvent.Notebook) (Version, bool) {
+	delta, changed := newNotebook.DeltaSince(oldNotebook)
+	if !changed {
+		return Version{}, false
+	}
+
+	return s.deltas.Append(newNotebook.ID, delta), true
+}
+
+// compact drops the stable tombstones of the given notebook before it
+// gets stored so replicas that already pruned them are not sent them
+// again
+func (s *Service) compact(notebook *solvent.Notebook) {
+	if s.tracker == nil {
+		return
+	}
+
+	s.tracker.Observe(notebook)
+	pruned := notebook.Compact(func(id uuid.UUID) bool {
+		return s.tracker.IsStable(notebook.ID, id)
+	})
+	s.tracker.Pruned(notebook.ID, pruned)
 }
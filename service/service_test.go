@@ -0,0 +1,310 @@
+package service
+
+import (
+	"time"
+
+	"github.com/eldelto/solvent"
+	"github.com/eldelto/solvent/service/errcode"
+	"github.com/google/uuid"
+)
+
+// testRepository is the in-memory fake of all the repository interfaces
+// the tests of the service run against. Like the transaction of a real
+// repository, Store and Modify either store the notebook together with
+// its revision or leave everything as it was. The repositories returned
+// by As share their state with the original one
+type testRepository struct {
+	*testState
+	// userID is the ID of the user the changes are attributed to
+	userID uuid.UUID
+}
+
+type testState struct {
+	notebooks map[uuid.UUID]*solvent.Notebook
+	revisions []Revision
+	// changedBy holds the ID of the user who has stored or changed each
+	// notebook last
+	changedBy map[uuid.UUID]uuid.UUID
+	users     map[uuid.UUID]User
+	sessions  map[string]Session
+	owners    map[uuid.UUID]uuid.UUID
+	members   map[uuid.UUID]map[uuid.UUID]Role
+	links     map[string]ShareLink
+	// err is returned by Modify and Remove instead of changing anything
+	// if set
+	err error
+}
+
+func newTestRepository() *testRepository {
+	return &testRepository{testState: &testState{
+		notebooks: map[uuid.UUID]*solvent.Notebook{},
+		changedBy: map[uuid.UUID]uuid.UUID{},
+		users:     map[uuid.UUID]User{},
+		sessions:  map[string]Session{},
+		owners:    map[uuid.UUID]uuid.UUID{},
+		members:   map[uuid.UUID]map[uuid.UUID]Role{},
+		links:     map[string]ShareLink{},
+	}}
+}
+
+func (r *testRepository) As(userID uuid.UUID) Repository {
+	scoped := *r
+	scoped.userID = userID
+
+	return &scoped
+}
+
+func (r *testRepository) Store(notebook *solvent.Notebook) error {
+	return r.put(notebook, true)
+}
+
+func (r *testRepository) Update(notebook *solvent.Notebook) error {
+	return r.put(notebook, false)
+}
+
+func (r *testRepository) Modify(id uuid.UUID, update UpdateFunc) (*solvent.Notebook, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	notebook, err := r.Fetch(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := update(notebook)
+	if err != nil {
+		return nil, err
+	}
+	_, changed := updated.DeltaSince(r.notebooks[id])
+
+	return updated, r.put(updated, changed)
+}
+
+func (r *testRepository) Fetch(id uuid.UUID) (*solvent.Notebook, error) {
+	notebook, ok := r.notebooks[id]
+	if !ok {
+		return nil, errcode.NewNotFoundError("notebook", id)
+	}
+
+	return copyTestNotebook(notebook)
+}
+
+func (r *testRepository) Remove(id uuid.UUID) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	delete(r.notebooks, id)
+	return nil
+}
+
+func (r *testRepository) Compact(id uuid.UUID, stable func(id uuid.UUID) bool) ([]uuid.UUID, error) {
+	return r.notebooks[id].Compact(stable), nil
+}
+
+// put stores a copy of the notebook and records it as new revision if
+// it has changed. Nothing is stored if copying fails
+func (r *testRepository) put(notebook *solvent.Notebook, changed bool) error {
+	stored, err := copyTestNotebook(notebook)
+	if err != nil {
+		return err
+	}
+	revision, err := copyTestNotebook(notebook)
+	if err != nil {
+		return err
+	}
+
+	r.notebooks[notebook.ID] = stored
+	r.changedBy[notebook.ID] = r.userID
+	if changed {
+		r.revisions = append(r.revisions, Revision{
+			NotebookID: notebook.ID,
+			Number:     int64(len(r.revisions) + 1),
+			Notebook:   revision,
+			CreatedAt:  time.Now().UTC(),
+		})
+	}
+
+	return nil
+}
+
+func (r *testRepository) FetchRevisions(notebookID uuid.UUID) ([]Revision, error) {
+	revisions := []Revision{}
+	for _, revision := range r.revisions {
+		if revision.NotebookID == notebookID {
+			revision.Notebook = nil
+			revisions = append(revisions, revision)
+		}
+	}
+
+	return revisions, nil
+}
+
+func (r *testRepository) FetchRevision(notebookID uuid.UUID, number int64) (*Revision, error) {
+	for _, revision := range r.revisions {
+		if revision.NotebookID == notebookID && revision.Number == number {
+			copied, err := copyTestNotebook(revision.Notebook)
+			if err != nil {
+				return nil, err
+			}
+			revision.Notebook = copied
+
+			return &revision, nil
+		}
+	}
+
+	return nil, errcode.NewNotFoundError("revision of notebook", notebookID)
+}
+
+func (r *testRepository) StoreUser(user *User) error {
+	if _, err := r.FetchUserByName(user.Name); err == nil {
+		return errcode.NewConflictError("user", user.Name)
+	}
+
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *testRepository) FetchUser(id uuid.UUID) (*User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, errcode.NewNotFoundError("user", id)
+	}
+
+	return &user, nil
+}
+
+func (r *testRepository) FetchUserByName(name string) (*User, error) {
+	for _, user := range r.users {
+		if user.Name == name {
+			return &user, nil
+		}
+	}
+
+	return nil, errcode.NewNotFoundError("user", uuid.Nil)
+}
+
+func (r *testRepository) StoreSession(session *Session) error {
+	r.sessions[session.TokenHash] = *session
+	return nil
+}
+
+func (r *testRepository) FetchSession(tokenHash string) (*Session, error) {
+	session, ok := r.sessions[tokenHash]
+	if !ok {
+		return nil, errcode.NewNotFoundError("session", uuid.Nil)
+	}
+
+	return &session, nil
+}
+
+func (r *testRepository) RemoveSession(tokenHash string) error {
+	delete(r.sessions, tokenHash)
+	return nil
+}
+
+func (r *testRepository) StoreOwner(notebookID, userID uuid.UUID) error {
+	r.owners[notebookID] = userID
+	return nil
+}
+
+func (r *testRepository) FetchOwner(notebookID uuid.UUID) (uuid.UUID, error) {
+	owner, ok := r.owners[notebookID]
+	if !ok {
+		return uuid.Nil, errcode.NewNotFoundError("notebook", notebookID)
+	}
+
+	return owner, nil
+}
+
+func (r *testRepository) FetchOwnedNotebooks(userID uuid.UUID) ([]uuid.UUID, error) {
+	notebooks := []uuid.UUID{}
+	for notebookID, owner := range r.owners {
+		if owner == userID {
+			notebooks = append(notebooks, notebookID)
+		}
+	}
+
+	return notebooks, nil
+}
+
+func (r *testRepository) StoreMember(notebookID, userID uuid.UUID, role Role) error {
+	if _, ok := r.members[notebookID]; !ok {
+		r.members[notebookID] = map[uuid.UUID]Role{}
+	}
+
+	r.members[notebookID][userID] = role
+	return nil
+}
+
+func (r *testRepository) RemoveMember(notebookID, userID uuid.UUID) error {
+	delete(r.members[notebookID], userID)
+	return nil
+}
+
+func (r *testRepository) FetchMembers(notebookID uuid.UUID) (map[uuid.UUID]Role, error) {
+	members := map[uuid.UUID]Role{}
+	for userID, role := range r.members[notebookID] {
+		members[userID] = role
+	}
+
+	return members, nil
+}
+
+func (r *testRepository) FetchSharedNotebooks(userID uuid.UUID) (map[uuid.UUID]Role, error) {
+	notebooks := map[uuid.UUID]Role{}
+	for notebookID, members := range r.members {
+		if role, ok := members[userID]; ok {
+			notebooks[notebookID] = role
+		}
+	}
+
+	return notebooks, nil
+}
+
+func (r *testRepository) StoreShareLink(link *ShareLink) error {
+	r.links[link.TokenHash] = *link
+	return nil
+}
+
+func (r *testRepository) FetchShareLink(tokenHash string) (*ShareLink, error) {
+	link, ok := r.links[tokenHash]
+	if !ok {
+		return nil, errcode.NewNotFoundError("share link", uuid.Nil)
+	}
+
+	return &link, nil
+}
+
+func (r *testRepository) FetchShareLinks(notebookID uuid.UUID) ([]ShareLink, error) {
+	links := []ShareLink{}
+	for _, link := range r.links {
+		if link.NotebookID == notebookID {
+			links = append(links, link)
+		}
+	}
+
+	return links, nil
+}
+
+func (r *testRepository) RemoveShareLink(notebookID, id uuid.UUID) error {
+	for tokenHash, link := range r.links {
+		if link.NotebookID == notebookID && link.ID == id {
+			delete(r.links, tokenHash)
+			return nil
+		}
+	}
+
+	return errcode.NewNotFoundError("share link", id)
+}
+
+// copyTestNotebook copies the notebook by merging it with itself
+func copyTestNotebook(notebook *solvent.Notebook) (*solvent.Notebook, error) {
+	merged, err := notebook.Merge(notebook)
+	if err != nil {
+		return nil, err
+	}
+
+	return merged.(*solvent.Notebook), nil
+}
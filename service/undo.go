@@ -0,0 +1,306 @@
+package service
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/eldelto/solvent"
+	"github.com/eldelto/solvent/service/errcode"
+	"github.com/google/uuid"
+)
+
+// DefaultUndoDepth is the default number of operations each user can
+// undo per notebook
+const DefaultUndoDepth = 100
+
+// UndoLog keeps the operations each user has done and undone per
+// notebook so they can be undone and redone again. Like the DeltaLog it
+// is only kept in memory
+type UndoLog struct {
+	depth  int
+	stacks map[undoKey]*undoStacks
+	mutex  sync.Mutex
+}
+
+type undoKey struct {
+	userID     uuid.UUID
+	notebookID uuid.UUID
+}
+
+// undoStacks holds the operations that can be undone and the ones that
+// can be redone, the most recent ones last
+type undoStacks struct {
+	done   []solvent.OperationRecord
+	undone []solvent.OperationRecord
+}
+
+func NewUndoLog(depth int) *UndoLog {
+	return &UndoLog{
+		depth:  depth,
+		stacks: map[undoKey]*undoStacks{},
+	}
+}
+
+// Record records an operation the user has applied to the notebook. The
+// operations the user has undone before can not be redone anymore
+func (l *UndoLog) Record(userID, notebookID uuid.UUID, operation solvent.OperationRecord) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	stacks := l.stacksFor(userID, notebookID)
+	stacks.done = l.push(stacks.done, operation)
+	stacks.undone = nil
+}
+
+// Undo removes the most recent operation the user has applied to the
+// notebook and returns it or false if there is none
+func (l *UndoLog) Undo(userID, notebookID uuid.UUID) (solvent.OperationRecord, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	stacks := l.stacksFor(userID, notebookID)
+	operation, ok := pop(&stacks.done)
+
+	return operation, ok
+}
+
+// Undone records the compensating operation that has been applied to
+// undo an operation, so it can be redone
+func (l *UndoLog) Undone(userID, notebookID uuid.UUID, operation solvent.OperationRecord) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	stacks := l.stacksFor(userID, notebookID)
+	stacks.undone = l.push(stacks.undone, operation)
+}
+
+// UndoFailed puts an operation Undo has returned back on top of the
+// operations that can be undone, as undoing it has failed but may
+// succeed later on
+func (l *UndoLog) UndoFailed(userID, notebookID uuid.UUID, operation solvent.OperationRecord) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	stacks := l.stacksFor(userID, notebookID)
+	stacks.done = l.push(stacks.done, operation)
+}
+
+// Redo removes the most recent compensating operation the user has
+// applied to the notebook and returns it or false if there is none
+func (l *UndoLog) Redo(userID, notebookID uuid.UUID) (solvent.OperationRecord, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	stacks := l.stacksFor(userID, notebookID)
+	operation, ok := pop(&stacks.undone)
+
+	return operation, ok
+}
+
+// Redone records the operation that has been applied to redo an undone
+// operation, so it can be undone again
+func (l *UndoLog) Redone(userID, notebookID uuid.UUID, operation solvent.OperationRecord) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	stacks := l.stacksFor(userID, notebookID)
+	stacks.done = l.push(stacks.done, operation)
+}
+
+// RedoFailed puts an operation Redo has returned back on top of the
+// operations that can be redone, as redoing it has failed but may
+// succeed later on
+func (l *UndoLog) RedoFailed(userID, notebookID uuid.UUID, operation solvent.OperationRecord) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	stacks := l.stacksFor(userID, notebookID)
+	stacks.undone = l.push(stacks.undone, operation)
+}
+
+// Replace replaces the ID of a list or item in the recorded operations
+// of all users for the notebook with the given ID. Removed lists and
+// items that are backed by 2P-Sets are restored under new IDs, which
+// the operations recorded before have to refer to from then on
+func (l *UndoLog) Replace(notebookID, oldID, newID uuid.UUID) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for key, stacks := range l.stacks {
+		if key.notebookID != notebookID {
+			continue
+		}
+		replaceID(stacks.done, oldID, newID)
+		replaceID(stacks.undone, oldID, newID)
+	}
+}
+
+func replaceID(operations []solvent.OperationRecord, oldID, newID uuid.UUID) {
+	for i := range operations {
+		if operations[i].ListID == oldID {
+			operations[i].ListID = newID
+		}
+		if operations[i].ItemID == oldID {
+			operations[i].ItemID = newID
+		}
+	}
+}
+
+// Forget drops the operations of all users for the notebook with the
+// given ID
+func (l *UndoLog) Forget(notebookID uuid.UUID) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for key := range l.stacks {
+		if key.notebookID == notebookID {
+			delete(l.stacks, key)
+		}
+	}
+}
+
+func (l *UndoLog) stacksFor(userID, notebookID uuid.UUID) *undoStacks {
+	key := undoKey{userID: userID, notebookID: notebookID}
+	stacks, ok := l.stacks[key]
+	if !ok {
+		stacks = &undoStacks{}
+		l.stacks[key] = stacks
+	}
+
+	return stacks
+}
+
+// push appends the operation and drops the oldest ones beyond the depth
+// of the UndoLog
+func (l *UndoLog) push(operations []solvent.OperationRecord, operation solvent.OperationRecord) []solvent.OperationRecord {
+	operations = append(operations, operation)
+	if len(operations) > l.depth {
+		operations = operations[len(operations)-l.depth:]
+	}
+
+	return operations
+}
+
+func pop(operations *[]solvent.OperationRecord) (solvent.OperationRecord, bool) {
+	if len(*operations) == 0 {
+		return solvent.OperationRecord{}, false
+	}
+
+	last := len(*operations) - 1
+	operation := (*operations)[last]
+	*operations = (*operations)[:last]
+
+	return operation, true
+}
+
+// Do applies the operation to the notebook with the given ID the same
+// way as Apply and records it, so the user of the Service can undo it
+// later on. It returns the new state of the notebook together with the
+// operation as it has been applied
+func (s *Service) Do(id uuid.UUID, operation solvent.OperationRecord) (*solvent.Notebook, solvent.OperationRecord, error) {
+	notebook, applied, err := s.applyOperation(id, operation)
+	if err != nil {
+		return nil, solvent.OperationRecord{}, err
+	}
+	s.undo.Record(s.userID(), id, applied)
+
+	return notebook, applied, nil
+}
+
+// Undo applies the inverse of the most recent operation the user of the
+// Service has done on the notebook with the given ID as a new change,
+// so the undo converges with the changes of all other replicas.
+// Operations that can not be undone anymore, e.g. as their list has
+// been removed in the meantime, are dropped while the ones that fail for
+// any other reason are kept, so undoing them can be tried again
+func (s *Service) Undo(id uuid.UUID) (*solvent.Notebook, error) {
+	if err := s.authorize(id, RoleEditor); err != nil {
+		return nil, err
+	}
+
+	operation, ok := s.undo.Undo(s.userID(), id)
+	if !ok {
+		return nil, errcode.NewValidationError("there is no operation to undo")
+	}
+
+	notebook, applied, err := s.applyOperation(id, operation.Inverse())
+	if err != nil {
+		if !isObsolete(err) {
+			s.undo.UndoFailed(s.userID(), id, operation)
+		}
+		return nil, err
+	}
+	s.undo.Undone(s.userID(), id, applied)
+
+	return notebook, nil
+}
+
+// Redo applies the most recent operation the user of the Service has
+// undone on the notebook with the given ID again. Like Undo it only
+// keeps the operation if it has failed for another reason than not
+// being applicable anymore
+func (s *Service) Redo(id uuid.UUID) (*solvent.Notebook, error) {
+	if err := s.authorize(id, RoleEditor); err != nil {
+		return nil, err
+	}
+
+	operation, ok := s.undo.Redo(s.userID(), id)
+	if !ok {
+		return nil, errcode.NewValidationError("there is no operation to redo")
+	}
+
+	notebook, applied, err := s.applyOperation(id, operation.Inverse())
+	if err != nil {
+		if !isObsolete(err) {
+			s.undo.RedoFailed(s.userID(), id, operation)
+		}
+		return nil, err
+	}
+	s.undo.Redone(s.userID(), id, applied)
+
+	return notebook, nil
+}
+
+func (s *Service) applyOperation(id uuid.UUID, operation solvent.OperationRecord) (*solvent.Notebook, solvent.OperationRecord, error) {
+	var applied solvent.OperationRecord
+	notebook, err := s.Apply(id, func(notebook *solvent.Notebook) error {
+		var err error
+		applied, err = notebook.ApplyOperation(operation)
+		return err
+	})
+	if err != nil {
+		return nil, solvent.OperationRecord{}, err
+	}
+
+	switch operation.Kind {
+	case solvent.RestoreListOperation, solvent.RestoreItemOperation:
+		if applied.ListID != operation.ListID {
+			s.undo.Replace(id, operation.ListID, applied.ListID)
+		}
+		if applied.ItemID != operation.ItemID {
+			s.undo.Replace(id, operation.ItemID, applied.ItemID)
+		}
+	}
+
+	return notebook, applied, nil
+}
+
+// isObsolete reports whether applying an undone or redone operation
+// has failed as it does not apply to the notebook anymore, e.g. as its
+// list or item has been removed in the meantime
+func isObsolete(err error) bool {
+	var notFoundError *solvent.NotFoundError
+	var validationError *errcode.ValidationError
+
+	return errors.As(err, &notFoundError) || errors.As(err, &validationError)
+}
+
+// userID returns the ID of the user the Service acts on behalf of or
+// uuid.Nil if it is not restricted to any user
+func (s *Service) userID() uuid.UUID {
+	if s.user == nil {
+		return uuid.Nil
+	}
+
+	return s.user.ID
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/eldelto/solvent"
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/eldelto/solvent/service/errcode"
+)
+
+func TestUndoAndRedo(t *testing.T) {
+	service := NewService(newTestRepository())
+	notebook, _ := service.Create()
+
+	_, added, err := service.Do(notebook.ID, solvent.OperationRecord{
+		Kind:  solvent.AddListOperation,
+		Title: "list0",
+	})
+	AssertEquals(t, nil, err, "service.Do error")
+	service.Do(notebook.ID, solvent.OperationRecord{
+		Kind:   solvent.RenameListOperation,
+		ListID: added.ListID,
+		Title:  "renamed",
+	})
+
+	undone, err := service.Undo(notebook.ID)
+	AssertEquals(t, nil, err, "service.Undo error")
+	AssertEquals(t, "list0", undone.GetLists()[0].Title.Value, "undone title")
+
+	undone, _ = service.Undo(notebook.ID)
+	AssertEquals(t, 0, len(undone.GetLists()), "len(undone.GetLists)")
+
+	_, err = service.Undo(notebook.ID)
+	var validationError *errcode.ValidationError
+	AssertEquals(t, true, errors.As(err, &validationError), "nothing to undo")
+
+	redone, err := service.Redo(notebook.ID)
+	AssertEquals(t, nil, err, "service.Redo error")
+	AssertEquals(t, "list0", redone.GetLists()[0].Title.Value, "redone title")
+
+	redone, _ = service.Redo(notebook.ID)
+	AssertEquals(t, "renamed", redone.GetLists()[0].Title.Value, "redone title")
+
+	_, err = service.Redo(notebook.ID)
+	AssertEquals(t, true, errors.As(err, &validationError), "nothing to redo")
+
+	// Redone operations can be undone again
+	undone, _ = service.Undo(notebook.ID)
+	AssertEquals(t, "list0", undone.GetLists()[0].Title.Value, "undone title")
+}
+
+func TestUndoKeepsOperationsThatFailed(t *testing.T) {
+	repository := newTestRepository()
+	service := NewService(repository)
+	notebook, _ := service.Create()
+	service.Do(notebook.ID, solvent.OperationRecord{Kind: solvent.AddListOperation, Title: "list0"})
+
+	repository.err = errors.New("connection lost")
+	_, err := service.Undo(notebook.ID)
+	AssertNotEquals(t, nil, err, "service.Undo error")
+	repository.err = nil
+
+	undone, err := service.Undo(notebook.ID)
+	AssertEquals(t, nil, err, "service.Undo error after failure")
+	AssertEquals(t, 0, len(undone.GetLists()), "len(undone.GetLists)")
+
+	repository.err = errors.New("connection lost")
+	_, err = service.Redo(notebook.ID)
+	AssertNotEquals(t, nil, err, "service.Redo error")
+	repository.err = nil
+
+	redone, err := service.Redo(notebook.ID)
+	AssertEquals(t, nil, err, "service.Redo error after failure")
+	AssertEquals(t, 1, len(redone.GetLists()), "len(redone.GetLists)")
+}
+
+func TestUndoDropsObsoleteOperations(t *testing.T) {
+	service := NewService(newTestRepository())
+	notebook, _ := service.Create()
+	_, added, _ := service.Do(notebook.ID, solvent.OperationRecord{Kind: solvent.AddListOperation, Title: "list0"})
+
+	// Another replica removes the list in the meantime
+	service.Apply(notebook.ID, func(notebook *solvent.Notebook) error {
+		notebook.RemoveList(added.ListID)
+		return nil
+	})
+
+	_, err := service.Undo(notebook.ID)
+	var notFoundError *solvent.NotFoundError
+	AssertEquals(t, true, errors.As(err, &notFoundError), "list of the operation is gone")
+
+	_, err = service.Undo(notebook.ID)
+	var validationError *errcode.ValidationError
+	AssertEquals(t, true, errors.As(err, &validationError), "obsolete operation is dropped")
+}
+
+func TestDoClearsRedo(t *testing.T) {
+	service := NewService(newTestRepository())
+	notebook, _ := service.Create()
+
+	service.Do(notebook.ID, solvent.OperationRecord{Kind: solvent.AddListOperation, Title: "list0"})
+	service.Undo(notebook.ID)
+	service.Do(notebook.ID, solvent.OperationRecord{Kind: solvent.AddListOperation, Title: "list1"})
+
+	_, err := service.Redo(notebook.ID)
+	var validationError *errcode.ValidationError
+	AssertEquals(t, true, errors.As(err, &validationError), "nothing to redo")
+}
+
+func TestUndoIsPerUser(t *testing.T) {
+	accounts := NewAccounts(newTestRepository())
+	owner, _ := accounts.Register("owner", "password")
+	editor, _ := accounts.Register("editor", "password")
+	viewer, _ := accounts.Register("viewer", "password")
+	service := NewService(newTestRepository(), WithAccounts(accounts))
+
+	notebook, _ := service.As(owner).Create()
+	accounts.Share(owner, notebook.ID, "editor", RoleEditor)
+	accounts.Share(owner, notebook.ID, "viewer", RoleViewer)
+
+	service.As(owner).Do(notebook.ID, solvent.OperationRecord{Kind: solvent.AddListOperation, Title: "list0"})
+	service.As(editor).Do(notebook.ID, solvent.OperationRecord{Kind: solvent.AddListOperation, Title: "list1"})
+
+	// Undo only reverts the changes of the same user
+	undone, err := service.As(owner).Undo(notebook.ID)
+	AssertEquals(t, nil, err, "owner service.Undo error")
+	lists := undone.GetLists()
+	AssertEquals(t, 1, len(lists), "len(undone.GetLists)")
+	AssertEquals(t, "list1", lists[0].Title.Value, "remaining list")
+
+	_, err = service.As(owner).Undo(notebook.ID)
+	var validationError *errcode.ValidationError
+	AssertEquals(t, true, errors.As(err, &validationError), "owner has nothing to undo")
+
+	_, err = service.As(viewer).Undo(notebook.ID)
+	var forbiddenError *errcode.ForbiddenError
+	AssertEquals(t, true, errors.As(err, &forbiddenError), "viewer cannot undo")
+
+	undone, err = service.As(editor).Undo(notebook.ID)
+	AssertEquals(t, nil, err, "editor service.Undo error")
+	AssertEquals(t, 0, len(undone.GetLists()), "len(undone.GetLists)")
+}
+
+func TestUndoConvergesWithOtherReplicas(t *testing.T) {
+	service := NewService(newTestRepository())
+	notebook, _ := service.Create()
+
+	_, added, _ := service.Do(notebook.ID, solvent.OperationRecord{Kind: solvent.AddListOperation, Title: "list0"})
+	service.Do(notebook.ID, solvent.OperationRecord{
+		Kind:   solvent.AddItemOperation,
+		ListID: added.ListID,
+		Title:  "item0",
+	})
+	service.Do(notebook.ID, solvent.OperationRecord{Kind: solvent.RemoveListOperation, ListID: added.ListID})
+
+	// A replica that has seen the removal adds a list concurrently
+	replica, _ := service.Fetch(notebook.ID)
+	replica.AddList("list1")
+
+	restored, err := service.Undo(notebook.ID)
+	AssertEquals(t, nil, err, "service.Undo error")
+	AssertEquals(t, 1, len(restored.GetLists()), "len(restored.GetLists)")
+
+	merged, _ := service.Update(replica)
+	AssertEquals(t, 2, len(merged.GetLists()), "len(merged.GetLists)")
+	for _, list := range merged.GetLists() {
+		if list.Title.Value == "list0" {
+			AssertEquals(t, 1, len(list.GetItems()), "len(restored items)")
+		}
+	}
+
+	// Redoing the removal removes the restored list again
+	redone, err := service.Redo(notebook.ID)
+	AssertEquals(t, nil, err, "service.Redo error")
+	lists := redone.GetLists()
+	AssertEquals(t, 1, len(lists), "len(redone.GetLists)")
+	AssertEquals(t, "list1", lists[0].Title.Value, "remaining list")
+}
+
+func TestRemoveForgetsUndo(t *testing.T) {
+	service := NewService(newTestRepository())
+	notebook, _ := service.Create()
+
+	service.Do(notebook.ID, solvent.OperationRecord{Kind: solvent.AddListOperation, Title: "list0"})
+	service.Remove(notebook.ID)
+
+	_, ok := service.undo.Undo(service.userID(), notebook.ID)
+	AssertEquals(t, false, ok, "undo after remove")
+}
+
+func TestFailedRemoveKeepsUndo(t *testing.T) {
+	repository := newTestRepository()
+	service := NewService(repository)
+	notebook, _ := service.Create()
+	subscription := service.Subscribe(notebook.ID)
+	defer service.Unsubscribe(subscription)
+
+	service.Do(notebook.ID, solvent.OperationRecord{Kind: solvent.AddListOperation, Title: "list0"})
+	<-subscription.C
+	repository.err = errors.New("connection lost")
+	err := service.Remove(notebook.ID)
+	AssertEquals(t, repository.err, err, "service.Remove error")
+	AssertEquals(t, 0, len(subscription.C), "len(subscription.C)")
+	repository.err = nil
+
+	undone, err := service.Undo(notebook.ID)
+	AssertEquals(t, nil, err, "service.Undo error")
+	AssertEquals(t, 0, len(undone.GetLists()), "len(undone.GetLists)")
+}
+
+func TestUndoLogDepth(t *testing.T) {
+	service := NewService(newTestRepository(), WithUndoDepth(1))
+	notebook, _ := service.Create()
+
+	service.Do(notebook.ID, solvent.OperationRecord{Kind: solvent.AddListOperation, Title: "list0"})
+	service.Do(notebook.ID, solvent.OperationRecord{Kind: solvent.AddListOperation, Title: "list1"})
+
+	undone, _ := service.Undo(notebook.ID)
+	AssertEquals(t, 1, len(undone.GetLists()), "len(undone.GetLists)")
+
+	_, err := service.Undo(notebook.ID)
+	var validationError *errcode.ValidationError
+	AssertEquals(t, true, errors.As(err, &validationError), "oldest operation is dropped")
+}
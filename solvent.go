@@ -1,31 +1,85 @@
 package solvent
 
 import (
+	"bytes"
 	"fmt"
 	"sort"
-	"time"
 
 	"github.com/eldelto/solvent/crdt"
 	"github.com/google/uuid"
 )
 
-// OrderValue represents an ordering value with its correspondent update timestamp
+// OrderValue represents an ordering value with its correspondent update
+// timestamp. The value is a position as created by crdt.PositionBetween
+// and equal values are ordered by the ID of their owners
 type OrderValue struct {
-	Value     float64
-	UpdatedAt int64
+	Value     string
+	UpdatedAt crdt.Timestamp
+}
+
+// merge returns the most recently updated OrderValue. OrderValues
+// with the same timestamp resolve to the greater value
+func (o OrderValue) merge(other OrderValue) OrderValue {
+	switch other.UpdatedAt.Compare(o.UpdatedAt) {
+	case 1:
+		return other
+	case 0:
+		if other.Value > o.Value {
+			return other
+		}
+	}
+
+	return o
 }
 
 // Title represents a title value with its correspondent update timestamp
 type Title struct {
 	Value     string
-	UpdatedAt int64
+	UpdatedAt crdt.Timestamp
+}
+
+// merge returns the most recently updated Title. Titles with the same
+// timestamp resolve to the lexicographically greater value
+func (t Title) merge(other Title) Title {
+	switch other.UpdatedAt.Compare(t.UpdatedAt) {
+	case 1:
+		return other
+	case 0:
+		if other.Value > t.Value {
+			return other
+		}
+	}
+
+	return t
+}
+
+// Checked represents a checked state with its correspondent update
+// timestamp
+type Checked struct {
+	Value     bool
+	UpdatedAt crdt.Timestamp
+}
+
+// merge returns the most recently updated Checked state. Checked states
+// with the same timestamp resolve to checked
+func (c Checked) merge(other Checked) Checked {
+	switch other.UpdatedAt.Compare(c.UpdatedAt) {
+	case 1:
+		return other
+	case 0:
+		if other.Value {
+			return other
+		}
+	}
+
+	return c
 }
 
 // ToDoItem representa a single task that needs to be done
 type ToDoItem struct {
 	ID         uuid.UUID
-	Title      string
-	Checked    bool
+	Title      Title
+	Checked    Checked
 	OrderValue OrderValue
 }
 
@@ -34,6 +88,11 @@ func (t *ToDoItem) Identifier() interface{} {
 	return t.ID
 }
 
+// Key returns the ID of the ToDoItem
+func (t *ToDoItem) Key() uuid.UUID {
+	return t.ID
+}
+
 // Merge combines the current ToDoItem with the one passed in as
 // parameter or returns a CannotBeMerged error if the ToDoItems
 // cannot be merged (e.g. they have different IDs)
@@ -51,17 +110,9 @@ func (t *ToDoItem) Merge(other crdt.Mergeable) (crdt.Mergeable, error) {
 
 	mergedToDoItem := ToDoItem{
 		ID:         t.ID,
-		Title:      t.Title,
-		Checked:    t.Checked,
-		OrderValue: t.OrderValue,
-	}
-
-	if otherToDoItem.Checked {
-		mergedToDoItem.Checked = true
-	}
-
-	if otherToDoItem.OrderValue.UpdatedAt > t.OrderValue.UpdatedAt {
-		mergedToDoItem.OrderValue = otherToDoItem.OrderValue
+		Title:      t.Title.merge(otherToDoItem.Title),
+		Checked:    t.Checked.merge(otherToDoItem.Checked),
+		OrderValue: t.OrderValue.merge(otherToDoItem.OrderValue),
 	}
 
 	return &mergedToDoItem, nil
@@ -69,29 +120,37 @@ func (t *ToDoItem) Merge(other crdt.Mergeable) (crdt.Mergeable, error) {
 
 // ToDoList represents a whole list of ToDoItems
 type ToDoList struct {
-	ID        uuid.UUID
-	Title     Title
-	ToDoItems ToDoItemPSet
-	CreatedAt int64
+	ID         uuid.UUID
+	Title      Title
+	ToDoItems  ToDoItemPSet
+	OrderValue OrderValue
+	CreatedAt  int64
+	environment
 }
 
 // NewToDoList create a new ToDoList object with the given title
 // or returns an UnknownError when the ID generation fails
 func newToDoList(title string) (*ToDoList, error) {
-	id, err := randomUUID()
+	return environment{}.newToDoList(title)
+}
+
+func (e environment) newToDoList(title string) (*ToDoList, error) {
+	id, err := e.newID()
 	if err != nil {
 		return nil, err
 	}
 
+	now := e.now()
 	titleStruct := Title{
 		Value:     title,
-		UpdatedAt: time.Now().UTC().UnixNano(),
+		UpdatedAt: now,
 	}
 	toDoList := ToDoList{
-		ID:        id,
-		Title:     titleStruct,
-		ToDoItems: NewToDoItemPSet(),
-		CreatedAt: time.Now().UTC().UnixNano(),
+		ID:          id,
+		Title:       titleStruct,
+		ToDoItems:   NewToDoItemPSet(),
+		CreatedAt:   now.WallTime,
+		environment: e,
 	}
 
 	return &toDoList, nil
@@ -103,9 +162,10 @@ func newToDoList(title string) (*ToDoList, error) {
 func (tdl *ToDoList) Rename(title string) (uuid.UUID, error) {
 	newTitle := Title{
 		Value:     title,
-		UpdatedAt: time.Now().UTC().UnixNano(),
+		UpdatedAt: tdl.now(),
 	}
 	tdl.Title = newTitle
+	tdl.recordList(tdl.ID)
 
 	return tdl.ID, nil
 }
@@ -113,23 +173,37 @@ func (tdl *ToDoList) Rename(title string) (uuid.UUID, error) {
 // AddItem creates a new ToDoItem object and adds it to the ToDoList
 // it is called on
 func (tdl *ToDoList) AddItem(title string) (uuid.UUID, error) {
-	id, err := randomUUID()
+	id, err := tdl.newID()
 	if err != nil {
 		return uuid.Nil, err
 	}
 
+	now := tdl.now()
+	titleStruct := Title{
+		Value:     title,
+		UpdatedAt: now,
+	}
+	checked := Checked{
+		Value:     false,
+		UpdatedAt: now,
+	}
+	position, err := crdt.PositionBetween(lastOrderValue(tdl.GetItems()), "")
+	if err != nil {
+		return uuid.Nil, err
+	}
 	orderValue := OrderValue{
-		Value:     tdl.nextOrderValue(),
-		UpdatedAt: time.Now().UTC().UnixNano(),
+		Value:     position,
+		UpdatedAt: now,
 	}
 
 	item := ToDoItem{
 		ID:         id,
-		Title:      title,
-		Checked:    false,
+		Title:      titleStruct,
+		Checked:    checked,
 		OrderValue: orderValue,
 	}
-	err = tdl.ToDoItems.Add(item)
+	err = tdl.addItem(&item)
+	tdl.recordItem(tdl.ID, id)
 
 	return id, err
 }
@@ -137,12 +211,12 @@ func (tdl *ToDoList) AddItem(title string) (uuid.UUID, error) {
 // GetItem returns the ToDoItem matching the given id or returns a
 // NotFoundError if no match could be found
 func (tdl *ToDoList) GetItem(id uuid.UUID) (ToDoItem, error) {
-	item, ok := tdl.ToDoItems.LiveView()[id]
+	item, ok := tdl.ToDoItems.Get(id)
 	if ok == false {
-		return item, newNotFoundError(id)
+		return ToDoItem{}, newNotFoundError(id)
 	}
 
-	return item, nil
+	return *item, nil
 }
 
 // RemoveItem removes the ToDoItem with the given id from the ToDoList
@@ -151,58 +225,88 @@ func (tdl *ToDoList) GetItem(id uuid.UUID) (ToDoItem, error) {
 func (tdl *ToDoList) RemoveItem(id uuid.UUID) {
 	item, err := tdl.GetItem(id)
 	if err == nil {
-		tdl.ToDoItems.Remove(item)
+		tdl.ToDoItems.Remove(&item)
+		tdl.recordItem(tdl.ID, id)
 	}
 }
 
 // CheckItem checks the ToDoItem with the given id or returns a
 // NotFoundError if no match could be found
 func (tdl *ToDoList) CheckItem(id uuid.UUID) (uuid.UUID, error) {
-	item, err := tdl.GetItem(id)
-	if err == nil {
-		item.Checked = true
-		tdl.ToDoItems.Add(item)
-	}
-
-	return item.ID, err
+	return tdl.setChecked(id, true)
 }
 
-// UncheckItem unchecks the ToDoItem with the given id by creating a new
-// ToDoItem object with the same attributes or returns a NotfoundError
-// if no match could be found
+// UncheckItem unchecks the ToDoItem with the given id or returns a
+// NotFoundError if no match could be found
 func (tdl *ToDoList) UncheckItem(id uuid.UUID) (uuid.UUID, error) {
+	return tdl.setChecked(id, false)
+}
+
+func (tdl *ToDoList) setChecked(id uuid.UUID, value bool) (uuid.UUID, error) {
 	item, err := tdl.GetItem(id)
 	if err != nil {
 		return uuid.Nil, err
 	}
-	tdl.RemoveItem(item.ID)
 
-	newID, err := randomUUID()
+	checked := Checked{
+		Value:     value,
+		UpdatedAt: tdl.now(),
+	}
+	item.Checked = checked
+	tdl.recordItem(tdl.ID, id)
+
+	return item.ID, tdl.addItem(&item)
+}
+
+// RestoreItem re-adds a previously removed ToDoItem with its original
+// ID or returns a NotRestorableError if the ToDoItems are backed by a
+// 2P-Set which does not allow re-adding removed items
+func (tdl *ToDoList) RestoreItem(item ToDoItem) error {
+	err := tdl.addItem(&item)
 	if err != nil {
-		return newID, err
+		return err
 	}
-	newItem := ToDoItem{
-		ID:         newID,
-		Title:      item.Title,
-		Checked:    false,
-		OrderValue: item.OrderValue,
+	tdl.recordItem(tdl.ID, item.ID)
+
+	if _, ok := tdl.ToDoItems.Get(item.ID); !ok {
+		return newNotRestorableError(item.ID)
 	}
-	err = tdl.ToDoItems.Add(newItem)
 
-	return newID, err
+	return nil
 }
 
-// TODO: Implement RenameItem(id uuid.UUID, title string)
+// RenameItem sets the title of the ToDoItem with the given id and
+// updates its UpdatedAt field or returns a NotFoundError if no match
+// could be found
+func (tdl *ToDoList) RenameItem(id uuid.UUID, title string) (uuid.UUID, error) {
+	item, err := tdl.GetItem(id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	newTitle := Title{
+		Value:     title,
+		UpdatedAt: tdl.now(),
+	}
+	item.Title = newTitle
+	tdl.recordItem(tdl.ID, id)
+
+	return item.ID, tdl.addItem(&item)
+}
 
 // GetItems returns a slice with all ToDoItems that are in the liveSet
 // but not in the tombstoneSet and are therefore considered active
+// ordered by their OrderValues
 func (tdl *ToDoList) GetItems() []ToDoItem {
 	// TODO: Benchmark pre-allocation
 	liveView := tdl.ToDoItems.LiveView()
 	items := make([]ToDoItem, 0, len(liveView))
 	for _, item := range liveView {
-		items = append(items, item)
+		items = append(items, *item)
 	}
+	sort.Slice(items, func(i, j int) bool {
+		return isOrderedBefore(items[i].OrderValue, items[i].ID, items[j].OrderValue, items[j].ID)
+	})
 
 	return items
 }
@@ -216,36 +320,41 @@ func (tdl *ToDoList) MoveItem(id uuid.UUID, targetIndex int) error {
 	}
 
 	items := tdl.GetItems()
-	index := clampIndex(targetIndex, items)
-	sort.Slice(items, func(i, j int) bool { return items[i].OrderValue.Value < items[j].OrderValue.Value })
-
-	orderValueMid := items[index].OrderValue.Value
-	var orderValueAdjacent float64
-	if orderValueMid < item.OrderValue.Value {
-		// Moving item up
-		if (index - 1) >= 0 {
-			orderValueAdjacent = items[index-1].OrderValue.Value
+	orderValues := make([]OrderValue, 0, len(items))
+	index := 0
+	for i, other := range items {
+		if other.ID == id {
+			index = i
 		} else {
-			orderValueAdjacent = 0.0
+			orderValues = append(orderValues, other.OrderValue)
 		}
-	} else if orderValueMid > item.OrderValue.Value {
-		// Moving item down
-		if (index + 1) < len(items) {
-			orderValueAdjacent = items[index+1].OrderValue.Value
-		} else {
-			orderValueAdjacent = tdl.nextOrderValue()
-		}
-	} else {
+	}
+
+	targetIndex = clampIndex(targetIndex, len(orderValues))
+	if targetIndex == index {
 		// Already on correct position
 		return nil
 	}
 
+	position, err := orderValueAt(orderValues, targetIndex)
+	if err != nil {
+		return err
+	}
+
 	newOrderValue := OrderValue{
-		Value:     (orderValueMid + orderValueAdjacent) / 2,
-		UpdatedAt: time.Now().UTC().UnixNano(),
+		Value:     position,
+		UpdatedAt: tdl.now(),
 	}
 	item.OrderValue = newOrderValue
+	tdl.recordItem(tdl.ID, id)
 
+	return tdl.addItem(&item)
+}
+
+// addItem adds the given ToDoItem to the ToDoItems and tags it with
+// the IDGenerator of the ToDoList if they are backed by an OR-Set
+func (tdl *ToDoList) addItem(item *ToDoItem) error {
+	tdl.tagWith(tdl.ToDoItems)
 	return tdl.ToDoItems.Add(item)
 }
 
@@ -254,6 +363,11 @@ func (tdl *ToDoList) Identifier() interface{} {
 	return tdl.ID
 }
 
+// Key returns the ID of the ToDoList
+func (tdl *ToDoList) Key() uuid.UUID {
+	return tdl.ID
+}
+
 // Merge combines the current ToDoList with the one passed in as
 // parameter or returns a CannotBeMerged error if the ToDoLists or
 // their ToDoListItems cannot be merged (e.g. they have different IDs)
@@ -268,23 +382,18 @@ func (tdl *ToDoList) Merge(other crdt.Mergeable) (crdt.Mergeable, error) {
 		return nil, err
 	}
 
-	var title Title
-	if otherToDoList.Title.UpdatedAt > tdl.Title.UpdatedAt {
-		title = otherToDoList.Title
-	} else {
-		title = tdl.Title
-	}
-
-	mergedToDoItems, err := tdl.ToDoItems.Merge(&otherToDoList.ToDoItems)
+	mergedToDoItems, err := crdt.MergeSets(tdl.ToDoItems, otherToDoList.ToDoItems)
 	if err != nil {
 		return nil, err
 	}
 
 	mergedToDoList := ToDoList{
-		ID:        tdl.ID,
-		Title:     title,
-		ToDoItems: mergedToDoItems,
-		CreatedAt: tdl.CreatedAt,
+		ID:          tdl.ID,
+		Title:       tdl.Title.merge(otherToDoList.Title),
+		ToDoItems:   mergedToDoItems,
+		OrderValue:  tdl.OrderValue.merge(otherToDoList.OrderValue),
+		CreatedAt:   tdl.CreatedAt,
+		environment: tdl.environment,
 	}
 	return &mergedToDoList, nil
 }
@@ -293,32 +402,94 @@ type Notebook struct {
 	ID        uuid.UUID
 	ToDoLists ToDoListPSet
 	CreatedAt int64
+	environment
 }
 
-func NewNotebook() (*Notebook, error) {
-	id, err := randomUUID()
-	if err != nil {
-		return nil, err
+// NotebookOption configures a Notebook created by NewNotebook
+type NotebookOption func(n *Notebook)
+
+// WithORSets backs the ToDoLists of the Notebook and the ToDoItems of
+// its ToDoLists with OR-Sets instead of 2P-Sets so removed lists and
+// items can be restored with their original IDs
+func WithORSets() NotebookOption {
+	return func(n *Notebook) {
+		n.ToDoLists = NewToDoListORSet()
+	}
+}
+
+// WithClock sets the Clock used to timestamp the updates of the
+// Notebook and its ToDoLists
+func WithClock(clock Clock) NotebookOption {
+	return func(n *Notebook) {
+		n.SetClock(clock)
+	}
+}
+
+// WithIDGenerator sets the IDGenerator used to create the IDs of the
+// Notebook and its ToDoLists and ToDoItems
+func WithIDGenerator(idGenerator IDGenerator) NotebookOption {
+	return func(n *Notebook) {
+		n.SetIDGenerator(idGenerator)
 	}
+}
 
+func NewNotebook(options ...NotebookOption) (*Notebook, error) {
 	notebook := Notebook{
-		ID:        id,
 		ToDoLists: NewToDoListPSet(),
-		CreatedAt: time.Now().UTC().UnixNano(),
 	}
+	notebook.trackChanges()
+	for _, option := range options {
+		option(&notebook)
+	}
+
+	id, err := notebook.newID()
+	if err != nil {
+		return nil, err
+	}
+	notebook.ID = id
+	notebook.CreatedAt = notebook.now().WallTime
+
 	return &notebook, nil
 }
 
+// SetClock sets the Clock used to timestamp the updates of the
+// Notebook and its ToDoLists
+func (n *Notebook) SetClock(clock Clock) {
+	n.clock = clock
+}
+
+// SetIDGenerator sets the IDGenerator used to create the IDs of the
+// Notebook and its ToDoLists and ToDoItems
+func (n *Notebook) SetIDGenerator(idGenerator IDGenerator) {
+	n.idGenerator = idGenerator
+}
+
 func (n *Notebook) AddList(title string) (*ToDoList, error) {
-	list, err := newToDoList(title)
+	n.trackChanges()
+	list, err := n.environment.newToDoList(title)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := n.ToDoLists.(*crdt.ORSet[uuid.UUID, *ToDoList]); ok {
+		list.ToDoItems = NewToDoItemORSet()
+	}
+
+	// New lists are put in front of the existing ones
+	position, err := crdt.PositionBetween("", firstOrderValue(n.GetLists()))
 	if err != nil {
 		return nil, err
 	}
+	list.OrderValue = OrderValue{
+		Value:     position,
+		UpdatedAt: list.Title.UpdatedAt,
+	}
 
-	err = n.ToDoLists.Add(list)
+	err = n.addList(list)
 	if err != nil {
 		return nil, err
 	}
+	n.recordList(list.ID)
 
 	return list, nil
 }
@@ -327,29 +498,101 @@ func (n *Notebook) RemoveList(id uuid.UUID) {
 	list, err := n.GetList(id)
 	if err == nil {
 		n.ToDoLists.Remove(list)
+		n.recordList(id)
+	}
+}
+
+// RestoreList re-adds a previously removed ToDoList with its original
+// ID or returns a NotRestorableError if the ToDoLists are backed by a
+// 2P-Set which does not allow re-adding removed lists
+func (n *Notebook) RestoreList(list *ToDoList) error {
+	err := n.addList(list)
+	if err != nil {
+		return err
+	}
+	n.recordList(list.ID)
+
+	if _, ok := n.ToDoLists.Get(list.ID); !ok {
+		return newNotRestorableError(list.ID)
 	}
+
+	return nil
+}
+
+// addList adds the given ToDoList to the ToDoLists and tags it with
+// the IDGenerator of the Notebook if they are backed by an OR-Set
+func (n *Notebook) addList(list *ToDoList) error {
+	n.tagWith(n.ToDoLists)
+	return n.ToDoLists.Add(list)
 }
 
 func (n *Notebook) GetList(id uuid.UUID) (*ToDoList, error) {
-	// TODO: Move Get method to PSet?
-	list, ok := n.ToDoLists.LiveView()[id]
+	n.trackChanges()
+	list, ok := n.ToDoLists.Get(id)
 	if ok == false {
 		return nil, newNotFoundError(id)
 	}
+	list.environment = n.environment
 
 	return list, nil
 }
 
+// GetLists returns all the active ToDoLists ordered by their
+// OrderValues
 func (n *Notebook) GetLists() []*ToDoList {
+	n.trackChanges()
 	liveView := n.ToDoLists.LiveView()
 	lists := make([]*ToDoList, 0, len(liveView))
 	for _, list := range liveView {
+		list.environment = n.environment
 		lists = append(lists, list)
 	}
+	sort.Slice(lists, func(i, j int) bool {
+		return isOrderedBefore(lists[i].OrderValue, lists[i].ID, lists[j].OrderValue, lists[j].ID)
+	})
 
 	return lists
 }
 
+// MoveList moves the ToDoList with the given id to the targeted index
+// or returns a NotFoundError if no match could be found
+func (n *Notebook) MoveList(id uuid.UUID, targetIndex int) error {
+	list, err := n.GetList(id)
+	if err != nil {
+		return err
+	}
+
+	lists := n.GetLists()
+	orderValues := make([]OrderValue, 0, len(lists))
+	index := 0
+	for i, other := range lists {
+		if other.ID == id {
+			index = i
+		} else {
+			orderValues = append(orderValues, other.OrderValue)
+		}
+	}
+
+	targetIndex = clampIndex(targetIndex, len(orderValues))
+	if targetIndex == index {
+		// Already on correct position
+		return nil
+	}
+
+	position, err := orderValueAt(orderValues, targetIndex)
+	if err != nil {
+		return err
+	}
+
+	list.OrderValue = OrderValue{
+		Value:     position,
+		UpdatedAt: n.now(),
+	}
+	n.recordList(id)
+
+	return n.addList(list)
+}
+
 func (n *Notebook) Identifier() interface{} {
 	return n.ID
 }
@@ -366,44 +609,162 @@ func (n *Notebook) Merge(other crdt.Mergeable) (crdt.Mergeable, error) {
 		return nil, err
 	}
 
-	mergedToDoLists, err := n.ToDoLists.Merge(&otherNotebook.ToDoLists)
+	mergedToDoLists, err := crdt.MergeSets(n.ToDoLists, otherNotebook.ToDoLists)
 	if err != nil {
 		return nil, err
 	}
 
+	// Advance the clock past the merged updates so subsequent local
+	// updates are ordered after them. Timestamps that drifted too far
+	// into the future are not observed but still take part in the merge
+	_ = n.observe(otherNotebook.latestTimestamp())
+
 	mergedNotebook := Notebook{
-		ID:        n.ID,
-		ToDoLists: mergedToDoLists,
-		CreatedAt: n.CreatedAt,
+		ID:          n.ID,
+		ToDoLists:   mergedToDoLists,
+		CreatedAt:   n.CreatedAt,
+		environment: n.environment,
 	}
 	return &mergedNotebook, nil
 }
 
-func randomUUID() (uuid.UUID, error) {
-	id, err := uuid.NewRandom()
+// Copy returns a deep copy of the Notebook that tracks its local
+// changes independently of the original one
+func (n *Notebook) Copy() (*Notebook, error) {
+	merged, err := n.Merge(n)
 	if err != nil {
-		err = &UnknownError{
-			message: "item creation failed with nested error",
-			err:     err,
+		return nil, err
+	}
+
+	copied := merged.(*Notebook)
+	copied.changes = newChangeSet()
+
+	return copied, nil
+}
+
+// Tombstones returns the IDs of all the removed ToDoLists and of the
+// removed ToDoItems of the remaining ToDoLists. Notebooks backed by
+// OR-Sets do not keep any removed items and have no tombstones
+func (n *Notebook) Tombstones() []uuid.UUID {
+	tombstones := []uuid.UUID{}
+	if lists, ok := n.ToDoLists.(crdt.Compactable[uuid.UUID]); ok {
+		tombstones = append(tombstones, lists.Tombstones()...)
+	}
+
+	for _, list := range n.ToDoLists.LiveView() {
+		if items, ok := list.ToDoItems.(crdt.Compactable[uuid.UUID]); ok {
+			tombstones = append(tombstones, items.Tombstones()...)
 		}
 	}
 
-	return id, err
+	return tombstones
 }
 
-func (tdl *ToDoList) nextOrderValue() float64 {
-	orderValue := 0.0
+// Compact drops all the tombstones for which stable returns true and
+// returns their IDs. A tombstone must only be reported as stable once
+// every replica of the Notebook has observed the removal
+func (n *Notebook) Compact(stable func(id uuid.UUID) bool) []uuid.UUID {
+	pruned := []uuid.UUID{}
+	if lists, ok := n.ToDoLists.(crdt.Compactable[uuid.UUID]); ok {
+		pruned = append(pruned, lists.Prune(stable)...)
+	}
+
+	for _, list := range n.ToDoLists.LiveView() {
+		if items, ok := list.ToDoItems.(crdt.Compactable[uuid.UUID]); ok {
+			pruned = append(pruned, items.Prune(stable)...)
+		}
+	}
+
+	return pruned
+}
+
+// latestTimestamp returns the most recent update timestamp of the
+// ToDoItem
+func (t *ToDoItem) latestTimestamp() crdt.Timestamp {
+	return latestTimestamp(t.Title.UpdatedAt, t.Checked.UpdatedAt, t.OrderValue.UpdatedAt)
+}
+
+// latestTimestamp returns the most recent update timestamp of the
+// ToDoList and its ToDoItems
+func (tdl *ToDoList) latestTimestamp() crdt.Timestamp {
+	latest := latestTimestamp(tdl.Title.UpdatedAt, tdl.OrderValue.UpdatedAt)
 	for _, item := range tdl.ToDoItems.LiveView() {
-		if item.OrderValue.Value > orderValue {
-			orderValue = item.OrderValue.Value
+		latest = latestTimestamp(latest, item.latestTimestamp())
+	}
+
+	return latest
+}
+
+// latestTimestamp returns the most recent update timestamp of all the
+// ToDoLists of the Notebook
+func (n *Notebook) latestTimestamp() crdt.Timestamp {
+	latest := crdt.Timestamp{}
+	for _, list := range n.ToDoLists.LiveView() {
+		latest = latestTimestamp(latest, list.latestTimestamp())
+	}
+
+	return latest
+}
+
+func latestTimestamp(timestamps ...crdt.Timestamp) crdt.Timestamp {
+	latest := crdt.Timestamp{}
+	for _, timestamp := range timestamps {
+		if timestamp.After(latest) {
+			latest = timestamp
 		}
 	}
 
-	return orderValue + 10
+	return latest
+}
+
+// isOrderedBefore reports whether the first OrderValue sorts before
+// the second one. Equal values are ordered by the IDs of their owners
+func isOrderedBefore(orderValue OrderValue, id uuid.UUID, otherOrderValue OrderValue, otherID uuid.UUID) bool {
+	if orderValue.Value != otherOrderValue.Value {
+		return orderValue.Value < otherOrderValue.Value
+	}
+
+	return bytes.Compare(id[:], otherID[:]) < 0
 }
 
-func clampIndex(index int, list []ToDoItem) int {
-	max := len(list) - 1
+// orderValueAt returns a position that sorts right before the
+// OrderValue at the given index of the ordered OrderValues or after the
+// last one if the index is out of range. If multiple OrderValues share
+// the same value the position is created after all of them
+func orderValueAt(orderValues []OrderValue, index int) (string, error) {
+	before := ""
+	if index > 0 {
+		before = orderValues[index-1].Value
+	}
+
+	after := ""
+	for _, orderValue := range orderValues[index:] {
+		if orderValue.Value > before {
+			after = orderValue.Value
+			break
+		}
+	}
+
+	return crdt.PositionBetween(before, after)
+}
+
+func lastOrderValue(items []ToDoItem) string {
+	if len(items) == 0 {
+		return ""
+	}
+
+	return items[len(items)-1].OrderValue.Value
+}
+
+func firstOrderValue(lists []*ToDoList) string {
+	if len(lists) == 0 {
+		return ""
+	}
+
+	return lists[0].OrderValue.Value
+}
+
+func clampIndex(index int, max int) int {
 	if index < 0 {
 		return 0
 	} else if index > max {
@@ -431,6 +792,24 @@ func (e *NotFoundError) Error() string {
 	return e.message
 }
 
+// NotRestorableError indicates that a removed entity cannot be re-added
+// with its original ID
+type NotRestorableError struct {
+	ID      uuid.UUID
+	message string
+}
+
+func newNotRestorableError(id uuid.UUID) *NotRestorableError {
+	return &NotRestorableError{
+		ID:      id,
+		message: fmt.Sprintf("item with ID '%v' cannot be restored", id),
+	}
+}
+
+func (e *NotRestorableError) Error() string {
+	return e.message
+}
+
 // UnknownError indicates an unhandled error from another library that
 // gets wrapped
 type UnknownError struct {
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/eldelto/solvent/crdt"
 	. "github.com/eldelto/solvent/internal/testutils"
 	"github.com/google/uuid"
 )
@@ -23,8 +24,8 @@ func TestNewToDoList(t *testing.T) {
 
 	AssertEquals(t, nil, err, "newToDoList error")
 	AssertEquals(t, listTitle0, list.Title.Value, "list.Title.Value")
-	AssertEquals(t, 0, len(list.ToDoItems.LiveSet), "list.ToDoItems.LiveSet length")
-	AssertEquals(t, 0, len(list.ToDoItems.TombstoneSet), "list.ToDoItems.TombstoneSet length")
+	AssertEquals(t, 0, len(toDoItemPSet(list).LiveSet), "list.ToDoItems.LiveSet length")
+	AssertEquals(t, 0, len(toDoItemPSet(list).TombstoneSet), "list.ToDoItems.TombstoneSet length")
 }
 
 func TestRename(t *testing.T) {
@@ -36,7 +37,7 @@ func TestRename(t *testing.T) {
 	AssertEquals(t, nil, err, "list.Rename error")
 	AssertEquals(t, listTitle1, list.Title.Value, "title.Value")
 	AssertEquals(t, list.ID, id, "id")
-	AssertEquals(t, true, list.Title.UpdatedAt > oldTs, "title.UpdatedAt")
+	AssertEquals(t, true, list.Title.UpdatedAt.After(oldTs), "title.UpdatedAt")
 }
 
 func TestAddItem(t *testing.T) {
@@ -47,8 +48,8 @@ func TestAddItem(t *testing.T) {
 
 	item, err := list.GetItem(id)
 	AssertEquals(t, nil, err, "list.GetItem error")
-	AssertEquals(t, itemTitle0, item.Title, "item.Title")
-	AssertEquals(t, false, item.Checked, "item.Checked")
+	AssertEquals(t, itemTitle0, item.Title.Value, "item.Title.Value")
+	AssertEquals(t, false, item.Checked.Value, "item.Checked.Value")
 }
 
 func TestRemoveItem(t *testing.T) {
@@ -74,8 +75,72 @@ func TestCheckItem(t *testing.T) {
 	AssertEquals(t, id, id1, "list.CheckItem id")
 
 	item, _ := list.GetItem(id1)
-	AssertEquals(t, itemTitle0, item.Title, "item.Title")
-	AssertEquals(t, true, item.Checked, "item.Checked")
+	AssertEquals(t, itemTitle0, item.Title.Value, "item.Title.Value")
+	AssertEquals(t, true, item.Checked.Value, "item.Checked.Value")
+}
+
+func TestMergeCheckedStates(t *testing.T) {
+	list, _ := newToDoList(listTitle0)
+	id, _ := list.AddItem(itemTitle0)
+	item0, _ := list.GetItem(id)
+
+	item1 := item0
+	item1.Checked = Checked{
+		Value:     true,
+		UpdatedAt: laterTimestamp(item0.Checked.UpdatedAt),
+	}
+	item2 := item1
+	item2.Checked = Checked{
+		Value:     false,
+		UpdatedAt: laterTimestamp(item1.Checked.UpdatedAt),
+	}
+
+	merged, err := item1.Merge(&item2)
+	AssertEquals(t, nil, err, "item1.Merge error")
+	AssertEquals(t, item2.Checked, merged.(*ToDoItem).Checked, "merged.Checked")
+
+	merged, err = item2.Merge(&item1)
+	AssertEquals(t, nil, err, "item2.Merge error")
+	AssertEquals(t, item2.Checked, merged.(*ToDoItem).Checked, "merged.Checked")
+}
+
+func TestMergeConcurrentCheckedStates(t *testing.T) {
+	list, _ := newToDoList(listTitle0)
+	id, _ := list.AddItem(itemTitle0)
+	item0, _ := list.GetItem(id)
+
+	item1 := item0
+	item1.Checked.Value = true
+
+	merged0, _ := item0.Merge(&item1)
+	merged1, _ := item1.Merge(&item0)
+	AssertEquals(t, item1.Checked, merged0.(*ToDoItem).Checked, "merged0.Checked")
+	AssertEquals(t, item1.Checked, merged1.(*ToDoItem).Checked, "merged1.Checked")
+}
+
+func TestRestoreItem(t *testing.T) {
+	list, _ := newToDoList(listTitle0)
+	list.ToDoItems = NewToDoItemORSet()
+	id, _ := list.AddItem(itemTitle0)
+	item, _ := list.GetItem(id)
+	list.RemoveItem(id)
+
+	err := list.RestoreItem(item)
+	AssertEquals(t, nil, err, "list.RestoreItem error")
+
+	restoredItem, err := list.GetItem(id)
+	AssertEquals(t, nil, err, "list.GetItem error")
+	AssertEquals(t, item, restoredItem, "restoredItem")
+}
+
+func TestRestoreItemWithPSet(t *testing.T) {
+	list, _ := newToDoList(listTitle0)
+	id, _ := list.AddItem(itemTitle0)
+	item, _ := list.GetItem(id)
+	list.RemoveItem(id)
+
+	err := list.RestoreItem(item)
+	AssertEquals(t, newNotRestorableError(id), err, "list.RestoreItem error")
 }
 
 func TestUncheckItem(t *testing.T) {
@@ -85,11 +150,39 @@ func TestUncheckItem(t *testing.T) {
 
 	id2, err := list.UncheckItem(id0)
 	AssertEquals(t, nil, err, "list.UncheckItem error")
-	AssertNotEquals(t, id1, id2, "list.UncheckItem id")
+	AssertEquals(t, id1, id2, "list.UncheckItem id")
+	AssertEquals(t, 0, len(toDoItemPSet(list).TombstoneSet), "list.ToDoItems.TombstoneSet length")
 
 	item, _ := list.GetItem(id2)
-	AssertEquals(t, itemTitle0, item.Title, "item.Title")
-	AssertEquals(t, false, item.Checked, "item.Checked")
+	AssertEquals(t, itemTitle0, item.Title.Value, "item.Title.Value")
+	AssertEquals(t, false, item.Checked.Value, "item.Checked.Value")
+}
+
+func TestRenameItem(t *testing.T) {
+	list, _ := newToDoList(listTitle0)
+	id0, _ := list.AddItem(itemTitle0)
+	list.CheckItem(id0)
+	oldItem, _ := list.GetItem(id0)
+	time.Sleep(1 * time.Millisecond)
+
+	id1, err := list.RenameItem(id0, itemTitle1)
+	AssertEquals(t, nil, err, "list.RenameItem error")
+	AssertEquals(t, id0, id1, "list.RenameItem id")
+
+	item, _ := list.GetItem(id1)
+	AssertEquals(t, itemTitle1, item.Title.Value, "item.Title.Value")
+	AssertEquals(t, true, item.Title.UpdatedAt.After(oldItem.Title.UpdatedAt), "item.Title.UpdatedAt")
+	AssertEquals(t, true, item.Checked.Value, "item.Checked.Value")
+	AssertEquals(t, oldItem.OrderValue, item.OrderValue, "item.OrderValue")
+	AssertEquals(t, 0, len(toDoItemPSet(list).TombstoneSet), "list.ToDoItems.TombstoneSet length")
+}
+
+func TestRenameMissingItem(t *testing.T) {
+	list, _ := newToDoList(listTitle0)
+	id := uuid.New()
+
+	_, err := list.RenameItem(id, itemTitle0)
+	AssertEquals(t, newNotFoundError(id), err, "list.RenameItem error")
 }
 
 func TestGetItems(t *testing.T) {
@@ -97,7 +190,7 @@ func TestGetItems(t *testing.T) {
 	id0, _ := list.AddItem(itemTitle0)
 	id1, _ := list.AddItem(itemTitle1)
 
-	items := orderedItems(list)
+	items := list.GetItems()
 	item0 := items[0]
 	item1 := items[1]
 	AssertEquals(t, id0, item0.ID, "item0.ID")
@@ -110,25 +203,25 @@ func TestMoveItem(t *testing.T) {
 	id1, _ := list.AddItem(itemTitle1)
 	id2, _ := list.AddItem(itemTitle2)
 
-	ids := itemIDs(orderedItems(list))
+	ids := itemIDs(list.GetItems())
 	expected := []uuid.UUID{id0, id1, id2}
 	AssertEquals(t, expected, ids, "Initial item ordering")
 
 	err := list.MoveItem(id2, 1)
 	AssertEquals(t, nil, err, "list.MoveItem error")
-	ids = itemIDs(orderedItems(list))
+	ids = itemIDs(list.GetItems())
 	expected = []uuid.UUID{id0, id2, id1}
 	AssertEquals(t, expected, ids, "First move item ordering")
 
 	err = list.MoveItem(id2, -10)
 	AssertEquals(t, nil, err, "list.MoveItem error")
-	ids = itemIDs(orderedItems(list))
+	ids = itemIDs(list.GetItems())
 	expected = []uuid.UUID{id2, id0, id1}
 	AssertEquals(t, expected, ids, "Second move item ordering")
 
 	err = list.MoveItem(id2, 10)
 	AssertEquals(t, nil, err, "list.MoveItem error")
-	ids = itemIDs(orderedItems(list))
+	ids = itemIDs(list.GetItems())
 	expected = []uuid.UUID{id0, id1, id2}
 	AssertEquals(t, expected, ids, "Third move item ordering")
 }
@@ -143,10 +236,15 @@ func TestMergeToDoLists(t *testing.T) {
 	_, _ = list1.AddItem(itemTitle2)
 
 	item1, _ := list0.GetItem(id1)
-	item1.Checked = true
-	item1.OrderValue.Value = 5.0
-	item1.OrderValue.UpdatedAt = item1.OrderValue.UpdatedAt + 1
-	list1.ToDoItems.Add(item1)
+	item1.Checked = Checked{
+		Value:     true,
+		UpdatedAt: laterTimestamp(item1.Checked.UpdatedAt),
+	}
+	item1.Title.Value = itemTitle2
+	item1.Title.UpdatedAt = laterTimestamp(item1.Title.UpdatedAt)
+	item1.OrderValue.Value = "0V"
+	item1.OrderValue.UpdatedAt = laterTimestamp(item1.OrderValue.UpdatedAt)
+	list1.ToDoItems.Add(&item1)
 
 	merged, err := list0.Merge(list1)
 	mergedList := merged.(*ToDoList)
@@ -154,25 +252,39 @@ func TestMergeToDoLists(t *testing.T) {
 	AssertEquals(t, list1.Title, mergedList.Title, "mergedList.Title")
 
 	// TODO: Handle equal sort order assigned from item creation
-	/*ids := itemIDs(orderedItems(&mergedList))
+	/*ids := itemIDs(mergedList.GetItems())
 	expected := []uuid.UUID{id1, id0, id2}
 	AssertEquals(t, expected, ids, "Item ordering")*/
 
 	mergedItem1, _ := mergedList.GetItem(id1)
 
 	expectedOrderValue := OrderValue{
-		Value:     5.0,
+		Value:     "0V",
 		UpdatedAt: item1.OrderValue.UpdatedAt,
 	}
 	AssertEquals(t, expectedOrderValue, mergedItem1.OrderValue, "mergedItem1.OrderValue")
-	AssertEquals(t, true, mergedItem1.Checked, "mergedItem1.Checked")
+	AssertEquals(t, item1.Checked, mergedItem1.Checked, "mergedItem1.Checked")
+	AssertEquals(t, item1.Title, mergedItem1.Title, "mergedItem1.Title")
 }
 
-func orderedItems(tdl *ToDoList) []ToDoItem {
-	items := tdl.GetItems()
-	sort.Slice(items, func(i, j int) bool { return items[i].OrderValue.Value < items[j].OrderValue.Value })
+func TestMergeToDoItemTitles(t *testing.T) {
+	list, _ := newToDoList(listTitle0)
+	id, _ := list.AddItem(itemTitle0)
+	item0, _ := list.GetItem(id)
+
+	item1 := item0
+	item1.Title = Title{
+		Value:     itemTitle1,
+		UpdatedAt: laterTimestamp(item0.Title.UpdatedAt),
+	}
+
+	merged0, err := item0.Merge(&item1)
+	AssertEquals(t, nil, err, "item0.Merge error")
+	AssertEquals(t, item1.Title, merged0.(*ToDoItem).Title, "merged0.Title")
 
-	return items
+	merged1, err := item1.Merge(&item0)
+	AssertEquals(t, nil, err, "item1.Merge error")
+	AssertEquals(t, item1.Title, merged1.(*ToDoItem).Title, "merged1.Title")
 }
 
 func itemIDs(list []ToDoItem) []uuid.UUID {
@@ -188,8 +300,8 @@ func TestNewNotebook(t *testing.T) {
 	notebook, err := NewNotebook()
 
 	AssertEquals(t, nil, err, "NewNotebook error")
-	AssertEquals(t, 0, len(notebook.ToDoLists.LiveSet), "notebook.ToDoLists.LiveSet length")
-	AssertEquals(t, 0, len(notebook.ToDoLists.TombstoneSet), "notebook.ToDoLists.TombstoneSet length")
+	AssertEquals(t, 0, len(toDoListPSet(notebook).LiveSet), "notebook.ToDoLists.LiveSet length")
+	AssertEquals(t, 0, len(toDoListPSet(notebook).TombstoneSet), "notebook.ToDoLists.TombstoneSet length")
 }
 
 func TestAddList(t *testing.T) {
@@ -217,6 +329,40 @@ func TestRemoveList(t *testing.T) {
 	AssertEquals(t, expected, err, "list.GetItem error")
 }
 
+func TestRestoreListWithORSets(t *testing.T) {
+	notebook, _ := NewNotebook(WithORSets())
+	list, _ := notebook.AddList(listTitle0)
+	list.AddItem(itemTitle0)
+	notebook.RemoveList(list.ID)
+
+	err := notebook.RestoreList(list)
+	AssertEquals(t, nil, err, "notebook.RestoreList error")
+
+	restoredList, err := notebook.GetList(list.ID)
+	AssertEquals(t, nil, err, "notebook.GetList error")
+	AssertEquals(t, 1, len(restoredList.GetItems()), "len(restoredList.GetItems)")
+
+	_, ok := restoredList.ToDoItems.(*crdt.ORSet[uuid.UUID, *ToDoItem])
+	AssertEquals(t, true, ok, "restoredList.ToDoItems is ORSet")
+}
+
+func TestMergeNotebooksWithORSets(t *testing.T) {
+	notebook0, _ := NewNotebook(WithORSets())
+	list, _ := notebook0.AddList(listTitle0)
+
+	merged, _ := notebook0.Merge(notebook0)
+	notebook1 := merged.(*Notebook)
+	notebook1.RemoveList(list.ID)
+	notebook0.RemoveList(list.ID)
+	notebook0.RestoreList(list)
+
+	mergedNotebook, err := notebook0.Merge(notebook1)
+	AssertEquals(t, nil, err, "notebook0.Merge error")
+
+	_, err = mergedNotebook.(*Notebook).GetList(list.ID)
+	AssertEquals(t, nil, err, "mergedNotebook.GetList error")
+}
+
 func TestGetLists(t *testing.T) {
 	notebook, _ := NewNotebook()
 	list0, _ := notebook.AddList(listTitle0)
@@ -243,7 +389,7 @@ func TestMergeNotebooks(t *testing.T) {
 	list10 := *list00
 	title := Title{
 		Value:     listTitle2,
-		UpdatedAt: list10.Title.UpdatedAt + 1,
+		UpdatedAt: laterTimestamp(list10.Title.UpdatedAt),
 	}
 	list10.Title = title
 	notebook1.ToDoLists.Add(&list10)
@@ -259,9 +405,241 @@ func TestMergeNotebooks(t *testing.T) {
 	AssertEquals(t, list01.ID, result1.ID, "result1.ID")
 }
 
+func toDoItemPSet(tdl *ToDoList) *crdt.PSet[uuid.UUID, *ToDoItem] {
+	return tdl.ToDoItems.(*crdt.PSet[uuid.UUID, *ToDoItem])
+}
+
+func toDoListPSet(n *Notebook) *crdt.PSet[uuid.UUID, *ToDoList] {
+	return n.ToDoLists.(*crdt.PSet[uuid.UUID, *ToDoList])
+}
+
 func orderedLists(n *Notebook) []*ToDoList {
 	lists := n.GetLists()
 	sort.Slice(lists, func(i, j int) bool { return lists[i].CreatedAt < lists[j].CreatedAt })
 
 	return lists
 }
+
+type testClock struct {
+	time int64
+}
+
+func (c *testClock) Now() crdt.Timestamp {
+	c.time++
+	return testTimestamp(c.time)
+}
+
+func (c *testClock) Observe(remote crdt.Timestamp) error {
+	return nil
+}
+
+func testTimestamp(wallTime int64) crdt.Timestamp {
+	return crdt.Timestamp{WallTime: wallTime}
+}
+
+func laterTimestamp(timestamp crdt.Timestamp) crdt.Timestamp {
+	timestamp.Counter++
+	return timestamp
+}
+
+type testIDGenerator struct {
+	count byte
+}
+
+func (g *testIDGenerator) NewID() (uuid.UUID, error) {
+	g.count++
+	return uuid.UUID{15: g.count}, nil
+}
+
+func TestNotebookWithClockAndIDGenerator(t *testing.T) {
+	notebook, err := NewNotebook(WithClock(&testClock{}), WithIDGenerator(&testIDGenerator{}))
+	AssertEquals(t, nil, err, "NewNotebook error")
+	AssertEquals(t, uuid.UUID{15: 1}, notebook.ID, "notebook.ID")
+	AssertEquals(t, int64(1), notebook.CreatedAt, "notebook.CreatedAt")
+
+	list, _ := notebook.AddList(listTitle0)
+	AssertEquals(t, uuid.UUID{15: 2}, list.ID, "list.ID")
+	AssertEquals(t, int64(2), list.CreatedAt, "list.CreatedAt")
+	AssertEquals(t, Title{Value: listTitle0, UpdatedAt: testTimestamp(2)}, list.Title, "list.Title")
+
+	id, _ := list.AddItem(itemTitle0)
+	AssertEquals(t, uuid.UUID{15: 3}, id, "item.ID")
+
+	list.CheckItem(id)
+	item, _ := list.GetItem(id)
+	AssertEquals(t, Title{Value: itemTitle0, UpdatedAt: testTimestamp(3)}, item.Title, "item.Title")
+	AssertEquals(t, Checked{Value: true, UpdatedAt: testTimestamp(4)}, item.Checked, "item.Checked")
+}
+
+func TestORSetNotebookTagsWithIDGenerator(t *testing.T) {
+	notebook, _ := NewNotebook(WithORSets(), WithIDGenerator(&testIDGenerator{}))
+
+	list, _ := notebook.AddList(listTitle0)
+	AssertEquals(t, uuid.UUID{15: 2}, list.ID, "list.ID")
+	id, _ := list.AddItem(itemTitle0)
+	AssertEquals(t, uuid.UUID{15: 4}, id, "item.ID")
+
+	lists := notebook.ToDoLists.(*crdt.ORSet[uuid.UUID, *ToDoList])
+	_, ok := lists.Entries[list.ID][uuid.UUID{15: 3}]
+	AssertEquals(t, true, ok, "list tag")
+	items := list.ToDoItems.(*crdt.ORSet[uuid.UUID, *ToDoItem])
+	_, ok = items.Entries[id][uuid.UUID{15: 5}]
+	AssertEquals(t, true, ok, "item tag")
+}
+
+func TestSetClockOnExistingNotebook(t *testing.T) {
+	notebook0, _ := NewNotebook()
+	list0, _ := notebook0.AddList(listTitle0)
+
+	merged, _ := notebook0.Merge(notebook0)
+	notebook1 := merged.(*Notebook)
+	notebook1.SetClock(&testClock{time: 10})
+	notebook1.SetIDGenerator(&testIDGenerator{count: 10})
+
+	list1, _ := notebook1.GetList(list0.ID)
+	list1.Rename(listTitle1)
+	AssertEquals(t, Title{Value: listTitle1, UpdatedAt: testTimestamp(11)}, list1.Title, "list1.Title")
+
+	id, _ := list1.AddItem(itemTitle0)
+	AssertEquals(t, uuid.UUID{15: 11}, id, "item.ID")
+}
+
+func TestMergeObservesRemoteTimestamps(t *testing.T) {
+	notebook0, _ := NewNotebook(WithClock(crdt.NewHybridClock(uuid.New(), MaxClockDrift)))
+	list0, _ := notebook0.AddList(listTitle0)
+
+	// Simulate a remote replica whose wall clock is ahead of ours
+	merged, _ := notebook0.Merge(notebook0)
+	notebook1 := merged.(*Notebook)
+	list1, _ := notebook1.GetList(list0.ID)
+	list1.Title = Title{
+		Value:     listTitle1,
+		UpdatedAt: crdt.Timestamp{WallTime: list0.Title.UpdatedAt.WallTime + (10 * time.Second).Nanoseconds()},
+	}
+
+	merged, _ = notebook0.Merge(notebook1)
+	notebook0 = merged.(*Notebook)
+	list0, _ = notebook0.GetList(list0.ID)
+	AssertEquals(t, listTitle1, list0.Title.Value, "list0.Title")
+
+	list0.Rename(listTitle2)
+	AssertEquals(t, true, list0.Title.UpdatedAt.After(list1.Title.UpdatedAt), "list0.Title.UpdatedAt")
+}
+
+func TestCopyNotebook(t *testing.T) {
+	notebook, _ := NewNotebook()
+	list, _ := notebook.AddList(listTitle0)
+	itemID, _ := list.AddItem(itemTitle0)
+	notebook.ResetDelta()
+
+	copied, err := notebook.Copy()
+	AssertEquals(t, nil, err, "notebook.Copy error")
+
+	copiedList, _ := copied.GetList(list.ID)
+	copiedList.CheckItem(itemID)
+	copied.AddList(listTitle1)
+
+	item, _ := list.GetItem(itemID)
+	AssertEquals(t, false, item.Checked.Value, "item.Checked")
+	AssertEquals(t, 1, len(notebook.GetLists()), "len(notebook.GetLists)")
+	AssertEquals(t, 0, len(notebook.Delta().GetLists()), "len(notebook.Delta.GetLists)")
+	AssertEquals(t, 2, len(copied.Delta().GetLists()), "len(copied.Delta.GetLists)")
+}
+
+func TestCompactNotebook(t *testing.T) {
+	notebook, _ := NewNotebook()
+	list0, _ := notebook.AddList(listTitle0)
+	list1, _ := notebook.AddList(listTitle1)
+	itemID0, _ := list0.AddItem(itemTitle0)
+	itemID1, _ := list0.AddItem(itemTitle1)
+	list0.RemoveItem(itemID0)
+	list0.RemoveItem(itemID1)
+	notebook.RemoveList(list1.ID)
+
+	AssertEquals(t, 3, len(notebook.Tombstones()), "len(notebook.Tombstones)")
+
+	pruned := notebook.Compact(func(id uuid.UUID) bool { return id != itemID1 })
+	AssertEquals(t, 2, len(pruned), "len(pruned)")
+	AssertEquals(t, []uuid.UUID{itemID1}, notebook.Tombstones(), "notebook.Tombstones")
+	AssertEquals(t, 1, len(toDoItemPSet(list0).LiveSet), "len(list0.LiveSet)")
+	AssertEquals(t, 1, len(toDoListPSet(notebook).LiveSet), "len(notebook.LiveSet)")
+}
+
+func TestMoveItemRepeatedlyIntoSameGap(t *testing.T) {
+	list, _ := newToDoList(listTitle0)
+	id0, _ := list.AddItem(itemTitle0)
+	id1, _ := list.AddItem(itemTitle1)
+	id2, _ := list.AddItem(itemTitle2)
+
+	expected := []uuid.UUID{id0, id1, id2}
+	for i := 0; i < 200; i++ {
+		moved := expected[2]
+		err := list.MoveItem(moved, 1)
+		AssertEquals(t, nil, err, "list.MoveItem error")
+
+		expected = []uuid.UUID{expected[0], moved, expected[1]}
+		AssertEquals(t, expected, itemIDs(list.GetItems()), "item ordering")
+	}
+}
+
+func TestConcurrentMovesToSamePosition(t *testing.T) {
+	list0, _ := newToDoList(listTitle0)
+	id0, _ := list0.AddItem(itemTitle0)
+	id1, _ := list0.AddItem(itemTitle1)
+	id2, _ := list0.AddItem(itemTitle2)
+
+	merged, _ := list0.Merge(list0)
+	list1 := merged.(*ToDoList)
+
+	list0.MoveItem(id2, 0)
+	list1.MoveItem(id1, 0)
+
+	merged0, _ := list0.Merge(list1)
+	merged1, _ := list1.Merge(list0)
+	ids0 := itemIDs(merged0.(*ToDoList).GetItems())
+	ids1 := itemIDs(merged1.(*ToDoList).GetItems())
+
+	AssertEquals(t, ids0, ids1, "item ordering")
+	AssertEquals(t, id0, ids0[2], "last item")
+}
+
+func TestMoveList(t *testing.T) {
+	notebook, _ := NewNotebook()
+	list0, _ := notebook.AddList(listTitle0)
+	list1, _ := notebook.AddList(listTitle1)
+	list2, _ := notebook.AddList(listTitle2)
+
+	AssertEquals(t, []uuid.UUID{list2.ID, list1.ID, list0.ID}, listIDs(notebook.GetLists()), "Initial list ordering")
+
+	err := notebook.MoveList(list2.ID, 10)
+	AssertEquals(t, nil, err, "notebook.MoveList error")
+	AssertEquals(t, []uuid.UUID{list1.ID, list0.ID, list2.ID}, listIDs(notebook.GetLists()), "First move list ordering")
+
+	err = notebook.MoveList(list0.ID, 0)
+	AssertEquals(t, nil, err, "notebook.MoveList error")
+	AssertEquals(t, []uuid.UUID{list0.ID, list1.ID, list2.ID}, listIDs(notebook.GetLists()), "Second move list ordering")
+
+	err = notebook.MoveList(uuid.New(), 0)
+	AssertNotEquals(t, nil, err, "notebook.MoveList error")
+}
+
+func TestMoveListWithSeveralTags(t *testing.T) {
+	notebook0, _ := NewNotebook(WithORSets())
+	list0, _ := notebook0.AddList(listTitle0)
+	list1, _ := notebook0.AddList(listTitle1)
+
+	// Restoring the list on both replicas tags it twice, so GetList
+	// returns a merged copy
+	merged, _ := notebook0.Merge(notebook0)
+	notebook1 := merged.(*Notebook)
+	for _, notebook := range []*Notebook{notebook0, notebook1} {
+		notebook.RemoveList(list0.ID)
+		notebook.RestoreList(list0)
+	}
+	merged, _ = notebook0.Merge(notebook1)
+	notebook := merged.(*Notebook)
+
+	err := notebook.MoveList(list0.ID, 0)
+	AssertEquals(t, nil, err, "notebook.MoveList error")
+	AssertEquals(t, []uuid.UUID{list0.ID, list1.ID}, listIDs(notebook.GetLists()), "list ordering")
+}
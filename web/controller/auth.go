@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eldelto/solvent/service"
+	"github.com/eldelto/solvent/service/errcode"
+	"github.com/eldelto/solvent/web/dto"
+)
+
+// SessionCookieName is the name of the cookie that holds the session
+// token of browser clients. Other clients send the token as bearer
+// token in the Authorization header instead
+const SessionCookieName = "solvent_session"
+
+type userContextKey struct{}
+
+// authenticate only passes on requests of logged in users
+func (c *MainController) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := sessionToken(r)
+		if token == "" {
+			handleError(w, errcode.NewUnauthorizedError("missing session token"))
+			return
+		}
+
+		user, err := c.accounts.Authenticate(token)
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), userContextKey{}, user))
+
+		next(w, r)
+	}
+}
+
+// requestUser returns the User that has been authenticated for the
+// given request
+func requestUser(r *http.Request) *service.User {
+	return r.Context().Value(userContextKey{}).(*service.User)
+}
+
+// serviceFor returns the Service acting on behalf of the user of the
+// given request
+func (c *MainController) serviceFor(r *http.Request) *service.Service {
+	return c.service.As(requestUser(r))
+}
+
+func sessionToken(r *http.Request) string {
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		return strings.TrimPrefix(authorization, "Bearer ")
+	}
+
+	if cookie, err := r.Cookie(SessionCookieName); err == nil {
+		return cookie.Value
+	}
+
+	return ""
+}
+
+func (c *MainController) register(w http.ResponseWriter, r *http.Request) {
+	var request dto.CredentialsDto
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	user, err := c.accounts.Register(request.Name, request.Password)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.UserToDto(user))
+}
+
+func (c *MainController) login(w http.ResponseWriter, r *http.Request) {
+	var request dto.CredentialsDto
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	token, session, err := c.accounts.Login(request.Name, request.Password)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.SessionDto{
+		Token:     token,
+		ExpiresAt: session.ExpiresAt,
+	})
+}
+
+func (c *MainController) logout(w http.ResponseWriter, r *http.Request) {
+	if err := c.accounts.Logout(sessionToken(r)); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *MainController) fetchUser(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.UserToDto(requestUser(r)))
+}
+
+func (c *MainController) fetchNotebooks(w http.ResponseWriter, r *http.Request) {
+	notebooks, err := c.accounts.Notebooks(requestUser(r))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	dtos := make([]dto.NotebookAccessDto, 0, len(notebooks))
+	for _, notebook := range notebooks {
+		dtos = append(dtos, dto.NotebookAccessToDto(notebook))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dtos)
+}
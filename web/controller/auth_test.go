@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"testing"
+
+	"github.com/eldelto/solvent"
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/eldelto/solvent/service"
+	"github.com/eldelto/solvent/web/dto"
+	"github.com/eldelto/solvent/web/persistence"
+	"github.com/gorilla/mux"
+)
+
+type testController struct {
+	service  *service.Service
+	accounts *service.Accounts
+	router   *mux.Router
+}
+
+// newTestController wires a MainController with an in-memory
+// repository
+func newTestController() testController {
+	repository := persistence.NewInMemoryRepository()
+	accounts := service.NewAccounts(repository)
+	s := service.NewService(repository, service.WithAccounts(accounts))
+	controller := NewMainController(&s, accounts)
+	r := mux.NewRouter()
+	controller.RegisterRoutes(r)
+
+	return testController{
+		service:  &s,
+		accounts: accounts,
+		router:   r,
+	}
+}
+
+// newTestUser registers a new user and returns it together with the
+// token of a new session
+func newTestUser(t *testing.T, accounts *service.Accounts, name string) (*service.User, string) {
+	user, err := accounts.Register(name, "password")
+	if err != nil {
+		t.Fatalf("accounts.Register error: %v", err)
+	}
+
+	token, _, err := accounts.Login(name, "password")
+	if err != nil {
+		t.Fatalf("accounts.Login error: %v", err)
+	}
+
+	return user, token
+}
+
+// createTestNotebook creates a new notebook owned by the given user
+func createTestNotebook(t *testing.T, c testController, user *service.User) *solvent.Notebook {
+	notebook, err := c.service.As(user).Create()
+	if err != nil {
+		t.Fatalf("service.Create error: %v", err)
+	}
+
+	return notebook
+}
+
+func authorizationHeaders(token string) map[string]string {
+	return map[string]string{"Authorization": "Bearer " + token}
+}
+
+func authorizationHeader(token string) http.Header {
+	return http.Header{"Authorization": []string{"Bearer " + token}}
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	c := newTestController()
+	ts := NewTestServer(t, c.router)
+	defer ts.Close()
+	jar, _ := cookiejar.New(nil)
+	ts.Client = &http.Client{Jar: jar}
+
+	response := ts.POST("/api/notebook", "")
+	AssertEquals(t, 401, response.StatusCode, "unauthenticated StatusCode")
+
+	response = ts.POST("/api/users", `{"name": "user", "password": "password"}`)
+	AssertEquals(t, 201, response.StatusCode, "register StatusCode")
+	var user dto.UserDto
+	response.Decode(&user)
+	AssertEquals(t, "user", user.Name, "user.Name")
+
+	response = ts.POST("/api/users", `{"name": "user", "password": "password"}`)
+	AssertEquals(t, 409, response.StatusCode, "duplicate register StatusCode")
+
+	response = ts.POST("/api/session", `{"name": "user", "password": "wrong password"}`)
+	AssertEquals(t, 401, response.StatusCode, "wrong password StatusCode")
+
+	// Browsers are authenticated by the session cookie
+	response = ts.POST("/api/session", `{"name": "user", "password": "password"}`)
+	AssertEquals(t, 200, response.StatusCode, "login StatusCode")
+
+	response = ts.POST("/api/notebook", "")
+	AssertEquals(t, 200, response.StatusCode, "create StatusCode")
+	var notebook dto.NotebookDto
+	response.Decode(&notebook)
+
+	response = ts.GET("/api/notebooks")
+	var notebooks []dto.NotebookAccessDto
+	response.Decode(&notebooks)
+	AssertEquals(t, []dto.NotebookAccessDto{{ID: notebook.ID, Role: "owner"}}, notebooks, "notebooks")
+
+	response = ts.DELETE("/api/session")
+	AssertEquals(t, 204, response.StatusCode, "logout StatusCode")
+
+	response = ts.GET("/api/user")
+	AssertEquals(t, 401, response.StatusCode, "logged out StatusCode")
+}
+
+func TestNotebooksOfOtherUsers(t *testing.T) {
+	c := newTestController()
+	owner, _ := newTestUser(t, c.accounts, "owner")
+	_, token := newTestUser(t, c.accounts, "other")
+	ts := NewTestServer(t, c.router)
+	ts.Headers = authorizationHeaders(token)
+	defer ts.Close()
+
+	notebook := createTestNotebook(t, c, owner)
+	path := "/api/notebook/" + notebook.ID.String()
+
+	response := ts.GET(path)
+	AssertEquals(t, 403, response.StatusCode, "fetch StatusCode")
+
+	body := `{"id": "` + notebook.ID.String() + `", "toDoLists": {"liveSet": [], "tombstoneSet": []}}`
+	response = ts.PUT("/api/notebook", body)
+	AssertEquals(t, 403, response.StatusCode, "update StatusCode")
+
+	response = ts.DELETE(path)
+	AssertEquals(t, 403, response.StatusCode, "remove StatusCode")
+
+	_, err := c.service.Fetch(notebook.ID)
+	AssertEquals(t, nil, err, "service.Fetch error")
+}
+
+func TestClaimNotebook(t *testing.T) {
+	c := newTestController()
+	_, token := newTestUser(t, c.accounts, "user")
+	_, otherToken := newTestUser(t, c.accounts, "other")
+	ts := NewTestServer(t, c.router)
+	defer ts.Close()
+
+	// Notebooks stored before there were accounts have no owner
+	notebook, _ := c.service.Create()
+	path := "/api/notebook/" + notebook.ID.String()
+
+	ts.Headers = authorizationHeaders(token)
+	response := ts.GET(path)
+	AssertEquals(t, 404, response.StatusCode, "fetch StatusCode")
+
+	response = ts.POST(path+"/claim", "")
+	AssertEquals(t, 200, response.StatusCode, "claim StatusCode")
+	response = ts.GET(path)
+	AssertEquals(t, 200, response.StatusCode, "fetch claimed StatusCode")
+
+	ts.Headers = authorizationHeaders(otherToken)
+	response = ts.POST(path+"/claim", "")
+	AssertEquals(t, 403, response.StatusCode, "claim owned StatusCode")
+}
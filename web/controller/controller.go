@@ -1,11 +1,15 @@
 package controller
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"os"
+	"strings"
 
+	"github.com/eldelto/solvent"
 	"github.com/eldelto/solvent/service"
 	"github.com/eldelto/solvent/service/errcode"
 	"github.com/eldelto/solvent/web/dto"
@@ -14,22 +18,54 @@ import (
 	"github.com/gorilla/mux"
 )
 
+// ReplicaIDHeader is the request header a client identifies itself with
+// to acknowledge the tombstones of the notebook it sends
+const ReplicaIDHeader = "X-Replica-ID"
+
+// MaxRequestBodySize is the maximum size in bytes of request bodies and
+// of the messages clients send over WebSockets
+const MaxRequestBodySize = 4 << 20
+
 type MainController struct {
-	service *service.Service
+	service  *service.Service
+	accounts *service.Accounts
 }
 
-func NewMainController(service *service.Service) MainController {
+func NewMainController(service *service.Service, accounts *service.Accounts) MainController {
 	return MainController{
-		service: service,
+		service:  service,
+		accounts: accounts,
 	}
 }
 
 func (c *MainController) RegisterRoutes(r *mux.Router) {
-	r.Handle("/api/health", baseMiddleWare(c.fetchHealth)).Methods("GET")
-	r.Handle("/api/notebook/{id}", baseMiddleWare(c.fetchNotebook)).Methods("GET")
-	r.Handle("/api/notebook", baseMiddleWare(c.createNotebook)).Methods("POST")
-	r.Handle("/api/notebook", baseMiddleWare(c.updateNotebook)).Methods("PUT")
-	r.Handle("/api/notebook/{id}", baseMiddleWare(c.removeNotebook)).Methods("DELETE")
+	r.Handle("/api/health", publicMiddleWare(c.fetchHealth)).Methods("GET")
+	r.Handle("/api/users", publicMiddleWare(c.register)).Methods("POST")
+	r.Handle("/api/session", publicMiddleWare(c.login)).Methods("POST")
+	r.Handle("/api/session", c.baseMiddleWare(c.logout)).Methods("DELETE")
+	r.Handle("/api/user", c.baseMiddleWare(c.fetchUser)).Methods("GET")
+	r.Handle("/api/notebooks", c.baseMiddleWare(c.fetchNotebooks)).Methods("GET")
+	r.Handle("/api/notebook/{id}", c.baseMiddleWare(c.fetchNotebook)).Methods("GET")
+	r.Handle("/api/notebook", c.baseMiddleWare(c.createNotebook)).Methods("POST")
+	r.Handle("/api/notebook", c.baseMiddleWare(c.updateNotebook)).Methods("PUT")
+	r.Handle("/api/notebook/{id}/sync", c.baseMiddleWare(c.syncNotebook)).Methods("POST")
+	r.Handle("/api/notebook/{id}/live", c.liveMiddleWare(c.liveSyncNotebook)).Methods("GET")
+	r.Handle("/api/notebook/{id}/events", c.liveMiddleWare(c.notebookEvents)).Methods("GET")
+	r.Handle("/api/notebook/{id}", c.baseMiddleWare(c.removeNotebook)).Methods("DELETE")
+	r.Handle("/api/notebook/{id}/claim", c.baseMiddleWare(c.claimNotebook)).Methods("POST")
+	r.Handle("/api/notebook/{id}/members", c.baseMiddleWare(c.fetchMembers)).Methods("GET")
+	r.Handle("/api/notebook/{id}/members", c.baseMiddleWare(c.shareNotebook)).Methods("PUT")
+	r.Handle("/api/notebook/{id}/members/{userId}", c.baseMiddleWare(c.unshareNotebook)).Methods("DELETE")
+	r.Handle("/api/notebook/{id}/links", c.baseMiddleWare(c.fetchShareLinks)).Methods("GET")
+	r.Handle("/api/notebook/{id}/links", c.baseMiddleWare(c.createShareLink)).Methods("POST")
+	r.Handle("/api/notebook/{id}/links/{linkId}", c.baseMiddleWare(c.revokeShareLink)).Methods("DELETE")
+	r.Handle("/api/notebook/{id}/history", c.baseMiddleWare(c.fetchHistory)).Methods("GET")
+	r.Handle("/api/notebook/{id}/revisions/{revision}", c.baseMiddleWare(c.fetchRevision)).Methods("GET")
+	r.Handle("/api/notebook/{id}/revisions/{revision}/restore", c.baseMiddleWare(c.restoreRevision)).Methods("POST")
+	r.Handle("/api/notebook/{id}/undo", c.baseMiddleWare(c.undo)).Methods("POST")
+	r.Handle("/api/notebook/{id}/redo", c.baseMiddleWare(c.redo)).Methods("POST")
+	r.Handle("/api/shared/{token}", publicMiddleWare(c.fetchSharedNotebook)).Methods("GET")
+	c.registerResourceRoutes(r)
 }
 
 func (c *MainController) fetchHealth(w http.ResponseWriter, r *http.Request) {
@@ -38,7 +74,7 @@ func (c *MainController) fetchHealth(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *MainController) createNotebook(w http.ResponseWriter, r *http.Request) {
-	notebook, err := c.service.Create()
+	notebook, err := c.serviceFor(r).Create()
 	if err != nil {
 		handleError(w, err)
 		return
@@ -58,15 +94,29 @@ func (c *MainController) fetchNotebook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	notebook, err := c.service.Fetch(uuid)
+	notebook, err := c.serviceFor(r).Fetch(uuid)
 	if err != nil {
 		handleError(w, err)
 		return
 	}
-	dto := dto.NotebookToDto(notebook)
+	body, err := json.Marshal(dto.NotebookToDto(notebook))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	// Clients have to revalidate their cached notebook on every request
+	// but only receive it again if it has changed
+	etag := contentETag(body)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(dto)
+	w.Write(body)
 }
 
 func (c *MainController) updateNotebook(w http.ResponseWriter, r *http.Request) {
@@ -81,7 +131,19 @@ func (c *MainController) updateNotebook(w http.ResponseWriter, r *http.Request)
 	}
 	newNotebook := dto.NotebookFromDto(&request)
 
-	mergedNotebook, err := c.service.Update(newNotebook)
+	if replicaID := r.Header.Get(ReplicaIDHeader); replicaID != "" {
+		replica, err := uuid.Parse(replicaID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := c.serviceFor(r).Acknowledge(replica, newNotebook); err != nil {
+			handleError(w, err)
+			return
+		}
+	}
+
+	mergedNotebook, err := c.serviceFor(r).Update(newNotebook)
 	if err != nil {
 		handleError(w, err)
 		return
@@ -92,6 +154,39 @@ func (c *MainController) updateNotebook(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(dto)
 }
 
+func (c *MainController) syncNotebook(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	uuid, err := uuid.Parse(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	var request dto.SyncRequestDto
+	err = decoder.Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	delta := dto.NotebookFromDto(&request.Delta)
+
+	responseDelta, version, err := c.serviceFor(r).Sync(uuid, dto.VersionFromDto(request.Since), delta)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	response := dto.SyncResponseDto{
+		Version: dto.VersionToDto(version),
+		Delta:   dto.NotebookToDto(responseDelta),
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
 func (c *MainController) removeNotebook(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 	uuid, err := uuid.Parse(id)
@@ -100,7 +195,7 @@ func (c *MainController) removeNotebook(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = c.service.Remove(uuid)
+	err = c.serviceFor(r).Remove(uuid)
 	if err != nil {
 		handleError(w, err)
 		return
@@ -109,16 +204,78 @@ func (c *MainController) removeNotebook(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func baseMiddleWare(nextFunc http.HandlerFunc) http.Handler {
+// claimNotebook makes the user the owner of a notebook that has been
+// stored before there were any accounts
+func (c *MainController) claimNotebook(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id")
+	if !ok {
+		return
+	}
+
+	notebook, err := c.serviceFor(r).Claim(ids[0])
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NotebookToDto(notebook))
+}
+
+// contentETag returns a weak ETag derived from the hash of the given
+// response body. The ETag is weak as the body may get compressed
+func contentETag(body []byte) string {
+	hash := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+// etagMatches reports whether the If-None-Match header contains the
+// given ETag, using the weak comparison required for If-None-Match
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// baseMiddleWare only passes on the requests of logged in users
+func (c *MainController) baseMiddleWare(nextFunc http.HandlerFunc) http.Handler {
+	return publicMiddleWare(c.authenticate(nextFunc))
+}
+
+// publicMiddleWare passes on the requests of everyone, including users
+// that are not logged in
+func publicMiddleWare(nextFunc http.HandlerFunc) http.Handler {
 	next := http.Handler(nextFunc)
 	next = handlers.CombinedLoggingHandler(os.Stdout, next)
 	next = handlers.CompressHandler(next)
 	next = handlers.ContentTypeHandler(next, "application/json")
 	next = responseContentTypeHandler(next, "application/json")
+	next = bodyLimitHandler(next, MaxRequestBodySize)
 
 	return next
 }
 
+// liveMiddleWare only authenticates and logs the requests of long-lived
+// connections as the other handlers of the baseMiddleWare either do not
+// support hijacking the connection or buffer streamed responses
+func (c *MainController) liveMiddleWare(nextFunc http.HandlerFunc) http.Handler {
+	return handlers.CombinedLoggingHandler(os.Stdout, http.Handler(c.authenticate(nextFunc)))
+}
+
+// bodyLimitHandler fails reading request bodies that are larger than
+// the given number of bytes
+func bodyLimitHandler(next http.Handler, limit int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
 func responseContentTypeHandler(next http.Handler, contentType string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", contentType)
@@ -133,6 +290,36 @@ func handleError(w http.ResponseWriter, err error) {
 		return
 	}
 
+	var entityNotFoundError *solvent.NotFoundError
+	if errors.As(err, &entityNotFoundError) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var unauthorizedError *errcode.UnauthorizedError
+	if errors.As(err, &unauthorizedError) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var forbiddenError *errcode.ForbiddenError
+	if errors.As(err, &forbiddenError) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var conflictError *errcode.ConflictError
+	if errors.As(err, &conflictError) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	var validationError *errcode.ValidationError
+	if errors.As(err, &validationError) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	var notebookError *errcode.NotebookError
 	if errors.As(err, &notebookError) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
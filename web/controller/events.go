@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/eldelto/solvent"
+	"github.com/eldelto/solvent/service"
+	"github.com/eldelto/solvent/web/dto"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// LastEventIDHeader is the header a reconnecting EventSource sends with
+// the ID of the last event it has received
+const LastEventIDHeader = "Last-Event-ID"
+
+// eventsKeepAliveInterval is the interval of the comments that keep
+// idle event streams from being closed by proxies
+const eventsKeepAliveInterval = 30 * time.Second
+
+// notebookEvents streams the changes of a notebook as Server-Sent
+// Events. The first event contains the whole notebook or, if the client
+// reconnects with a Last-Event-ID, the changes it has missed since.
+// Every following event contains the delta of a change as NotebookDto
+// and the new Version of the notebook as event ID
+func (c *MainController) notebookEvents(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before fetching the first changes so no change in
+	// between gets lost
+	subscription := c.service.Subscribe(id)
+	defer c.service.Unsubscribe(subscription)
+
+	since := dto.VersionFromEventID(r.Header.Get(LastEventIDHeader))
+	delta, version, err := c.serviceFor(r).Changes(id, since)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if since == nil || *since != version {
+		if err := writeNotebookEvent(w, delta, version); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-subscription.C:
+			if c.service.Version(id) == version {
+				continue
+			}
+
+			delta, version, err = c.serviceFor(r).Changes(id, &version)
+			if err != nil {
+				// The notebook has been removed
+				return
+			}
+			if err := writeNotebookEvent(w, delta, version); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func writeNotebookEvent(w http.ResponseWriter, delta *solvent.Notebook, version service.Version) error {
+	data, err := json.Marshal(dto.NotebookToDto(delta))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: notebook\ndata: %s\n\n", dto.VersionToEventID(version), data)
+	return err
+}
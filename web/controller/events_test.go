@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/eldelto/solvent/web/dto"
+	"github.com/google/uuid"
+)
+
+type notebookEvent struct {
+	id       string
+	notebook dto.NotebookDto
+}
+
+func TestNotebookEvents(t *testing.T) {
+	c := newTestController()
+	user, token := newTestUser(t, c.accounts, "user")
+	server := httptest.NewServer(c.router)
+	defer server.Close()
+
+	notebook := createTestNotebook(t, c, user)
+	url := server.URL + "/api/notebook/" + notebook.ID.String() + "/events"
+
+	response, events := openEvents(t, url, token, "")
+	AssertEquals(t, "text/event-stream", response.Header.Get("Content-Type"), "Content-Type")
+	first := readEvent(t, events)
+	AssertEquals(t, notebook.ID, first.notebook.ID, "first.notebook.ID")
+
+	list0, _ := notebook.AddList("list0")
+	c.service.Update(notebook)
+	second := readEvent(t, events)
+	AssertEquals(t, []uuid.UUID{list0.ID}, eventListIDs(second), "second lists")
+	response.Body.Close()
+
+	list1, _ := notebook.AddList("list1")
+	c.service.Update(notebook)
+
+	// A reconnecting client only receives the changes it has missed
+	response, events = openEvents(t, url, token, second.id)
+	defer response.Body.Close()
+	third := readEvent(t, events)
+	AssertEquals(t, []uuid.UUID{list1.ID}, eventListIDs(third), "third lists")
+}
+
+func TestNotebookEventsOfUnknownNotebook(t *testing.T) {
+	c := newTestController()
+	_, token := newTestUser(t, c.accounts, "user")
+	server := httptest.NewServer(c.router)
+	defer server.Close()
+
+	request, _ := http.NewRequest("GET", server.URL+"/api/notebook/"+uuid.NewString()+"/events", nil)
+	request.Header = authorizationHeader(token)
+	response, err := http.DefaultClient.Do(request)
+	AssertEquals(t, nil, err, "http.Get error")
+	AssertEquals(t, 404, response.StatusCode, "response.StatusCode")
+}
+
+func openEvents(t *testing.T, url, token, lastEventID string) (*http.Response, *bufio.Reader) {
+	request, _ := http.NewRequest("GET", url, nil)
+	request.Header = authorizationHeader(token)
+	if lastEventID != "" {
+		request.Header.Set(LastEventIDHeader, lastEventID)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("http.Get error: %v", err)
+	}
+
+	return response, bufio.NewReader(response.Body)
+}
+
+func readEvent(t *testing.T, events *bufio.Reader) notebookEvent {
+	var event notebookEvent
+	for {
+		line, err := events.ReadString('\n')
+		if err != nil {
+			t.Fatalf("events.ReadString error: %v", err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return event
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.notebook); err != nil {
+				t.Fatalf("json.Unmarshal error: %v", err)
+			}
+		}
+	}
+}
+
+func eventListIDs(event notebookEvent) []uuid.UUID {
+	ids := []uuid.UUID{}
+	for _, list := range dto.NotebookFromDto(&event.notebook).GetLists() {
+		ids = append(ids, list.ID)
+	}
+
+	return ids
+}
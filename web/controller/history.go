@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/eldelto/solvent/web/dto"
+	"github.com/gorilla/mux"
+)
+
+func (c *MainController) fetchHistory(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id")
+	if !ok {
+		return
+	}
+
+	revisions, err := c.serviceFor(r).History(ids[0])
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	dtos := make([]dto.RevisionDto, 0, len(revisions))
+	for _, revision := range revisions {
+		dtos = append(dtos, dto.RevisionToDto(revision))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dtos)
+}
+
+func (c *MainController) fetchRevision(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id")
+	if !ok {
+		return
+	}
+	number, ok := pathRevision(w, r)
+	if !ok {
+		return
+	}
+
+	revision, err := c.serviceFor(r).Revision(ids[0], number)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.RevisionToDto(*revision))
+}
+
+// restoreRevision re-creates the lists and items of the revision that
+// have been removed since and responds with the restored notebook
+func (c *MainController) restoreRevision(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id")
+	if !ok {
+		return
+	}
+	number, ok := pathRevision(w, r)
+	if !ok {
+		return
+	}
+
+	notebook, err := c.serviceFor(r).Restore(ids[0], number)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NotebookToDto(notebook))
+}
+
+// pathRevision parses the revision number of the request path or
+// responds with an error if it is invalid
+func pathRevision(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	number, err := strconv.ParseInt(mux.Vars(r)["revision"], 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return 0, false
+	}
+
+	return number, true
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"strconv"
+	"testing"
+
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/eldelto/solvent/service"
+	"github.com/eldelto/solvent/web/dto"
+)
+
+func TestHistory(t *testing.T) {
+	c := newTestController()
+	owner, ownerToken := newTestUser(t, c.accounts, "owner")
+	_, viewerToken := newTestUser(t, c.accounts, "viewer")
+	ts := NewTestServer(t, c.router)
+	defer ts.Close()
+
+	notebook := createTestNotebook(t, c, owner)
+	list, _ := notebook.AddList("list0")
+	list.AddItem("item0")
+	c.service.Update(notebook)
+	notebook.RemoveList(list.ID)
+	c.service.Update(notebook)
+	path := "/api/notebook/" + notebook.ID.String()
+
+	ts.Headers = authorizationHeaders(ownerToken)
+	response := ts.GET(path + "/history")
+	AssertEquals(t, 200, response.StatusCode, "history StatusCode")
+	var revisions []dto.RevisionDto
+	response.Decode(&revisions)
+	AssertEquals(t, 3, len(revisions), "len(revisions)")
+	AssertEquals(t, (*dto.NotebookDto)(nil), revisions[1].Notebook, "listed revision notebook")
+	revisionPath := path + "/revisions/" + strconv.FormatInt(revisions[1].Number, 10)
+
+	response = ts.GET(revisionPath)
+	AssertEquals(t, 200, response.StatusCode, "revision StatusCode")
+	var revision dto.RevisionDto
+	response.Decode(&revision)
+	AssertEquals(t, 1, len(revision.Notebook.ToDoLists.LiveSet), "len(revision lists)")
+
+	response = ts.GET(path + "/revisions/invalid")
+	AssertEquals(t, 400, response.StatusCode, "invalid revision StatusCode")
+	response = ts.GET(path + "/revisions/42")
+	AssertEquals(t, 404, response.StatusCode, "unknown revision StatusCode")
+
+	// Only editors can restore a revision
+	c.accounts.Share(owner, notebook.ID, "viewer", service.RoleViewer)
+	ts.Headers = authorizationHeaders(viewerToken)
+	response = ts.GET(path + "/history")
+	AssertEquals(t, 200, response.StatusCode, "viewer history StatusCode")
+	response = ts.POST(revisionPath+"/restore", "")
+	AssertEquals(t, 403, response.StatusCode, "viewer restore StatusCode")
+
+	ts.Headers = authorizationHeaders(ownerToken)
+	response = ts.POST(revisionPath+"/restore", "")
+	AssertEquals(t, 200, response.StatusCode, "restore StatusCode")
+
+	restored, _ := c.service.Fetch(notebook.ID)
+	lists := restored.GetLists()
+	AssertEquals(t, 1, len(lists), "len(restored.GetLists)")
+	AssertEquals(t, "list0", lists[0].Title.Value, "restored list title")
+	AssertEquals(t, 1, len(lists[0].GetItems()), "len(restored items)")
+}
@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/eldelto/solvent"
+	"github.com/eldelto/solvent/web/dto"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func (c *MainController) fetchShareLinks(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id")
+	if !ok {
+		return
+	}
+
+	links, err := c.accounts.ShareLinks(requestUser(r), ids[0])
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	dtos := make([]dto.ShareLinkDto, 0, len(links))
+	for _, link := range links {
+		dtos = append(dtos, dto.ShareLinkToDto(link))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dtos)
+}
+
+func (c *MainController) createShareLink(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var request dto.ShareLinkRequestDto
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	listID := uuid.Nil
+	if request.ListID != nil {
+		listID = *request.ListID
+	}
+
+	expiresAt := time.Time{}
+	if request.ExpiresAt != nil {
+		expiresAt = *request.ExpiresAt
+	}
+
+	token, link, err := c.accounts.CreateShareLink(requestUser(r), ids[0], listID, expiresAt)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	linkDto := dto.ShareLinkToDto(*link)
+	linkDto.Token = token
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(linkDto)
+}
+
+func (c *MainController) revokeShareLink(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id", "linkId")
+	if !ok {
+		return
+	}
+
+	if err := c.accounts.RevokeShareLink(requestUser(r), ids[0], ids[1]); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// fetchSharedNotebook serves the read-only view of the notebook or list
+// a share link grants access to. It does not require a session
+func (c *MainController) fetchSharedNotebook(w http.ResponseWriter, r *http.Request) {
+	link, err := c.accounts.ResolveShareLink(mux.Vars(r)["token"])
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	notebook, err := c.service.Fetch(link.NotebookID)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	var lists []*solvent.ToDoList
+	if link.ListID == uuid.Nil {
+		lists = notebook.GetLists()
+	} else {
+		list, err := notebook.GetList(link.ListID)
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+		lists = []*solvent.ToDoList{list}
+	}
+
+	shared := dto.SharedNotebookDto{
+		ToDoLists: make([]dto.SharedToDoListDto, 0, len(lists)),
+	}
+	for _, list := range lists {
+		shared.ToDoLists = append(shared.ToDoLists, dto.SharedToDoListToDto(list))
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(shared)
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"testing"
+
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/eldelto/solvent/web/dto"
+)
+
+func TestShareLinks(t *testing.T) {
+	c := newTestController()
+	owner, ownerToken := newTestUser(t, c.accounts, "owner")
+	ts := NewTestServer(t, c.router)
+	defer ts.Close()
+
+	notebook := createTestNotebook(t, c, owner)
+	list0, _ := notebook.AddList("list0")
+	list0.AddItem("item0")
+	itemID, _ := list0.AddItem("item1")
+	list0.CheckItem(itemID)
+	list1, _ := notebook.AddList("list1")
+	removedID, _ := list1.AddItem("removed")
+	list1.RemoveItem(removedID)
+	c.service.Update(notebook)
+	path := "/api/notebook/" + notebook.ID.String()
+
+	ts.Headers = authorizationHeaders(ownerToken)
+	response := ts.POST(path+"/links", `{}`)
+	AssertEquals(t, 201, response.StatusCode, "create StatusCode")
+	var notebookLink dto.ShareLinkDto
+	response.Decode(&notebookLink)
+
+	response = ts.POST(path+"/links", `{"listId": "`+list1.ID.String()+`"}`)
+	AssertEquals(t, 201, response.StatusCode, "create StatusCode")
+	var listLink dto.ShareLinkDto
+	response.Decode(&listLink)
+
+	response = ts.POST(path+"/links", `{"listId": "`+removedID.String()+`"}`)
+	AssertEquals(t, 400, response.StatusCode, "unknown list StatusCode")
+
+	response = ts.GET(path + "/links")
+	var links []dto.ShareLinkDto
+	response.Decode(&links)
+	AssertEquals(t, 2, len(links), "len(links)")
+	AssertEquals(t, "", links[0].Token, "listed links hide their token")
+
+	// Share links do not need a session
+	ts.Headers = nil
+	response = ts.GET("/api/shared/" + notebookLink.Token)
+	AssertEquals(t, 200, response.StatusCode, "shared notebook StatusCode")
+	var shared dto.SharedNotebookDto
+	response.Decode(&shared)
+	AssertEquals(t, dto.SharedNotebookDto{ToDoLists: []dto.SharedToDoListDto{
+		{ID: list1.ID, Title: "list1", Items: []dto.SharedToDoItemDto{}},
+		{ID: list0.ID, Title: "list0", Items: []dto.SharedToDoItemDto{
+			{ID: list0.GetItems()[0].ID, Title: "item0", Checked: false},
+			{ID: itemID, Title: "item1", Checked: true},
+		}},
+	}}, shared, "shared notebook")
+
+	response = ts.GET("/api/shared/" + listLink.Token)
+	response.Decode(&shared)
+	AssertEquals(t, 1, len(shared.ToDoLists), "len(shared.ToDoLists)")
+	AssertEquals(t, list1.ID, shared.ToDoLists[0].ID, "shared list ID")
+
+	response = ts.GET("/api/shared/unknown")
+	AssertEquals(t, 404, response.StatusCode, "unknown token StatusCode")
+
+	ts.Headers = authorizationHeaders(ownerToken)
+	response = ts.DELETE(path + "/links/" + notebookLink.ID.String())
+	AssertEquals(t, 204, response.StatusCode, "revoke StatusCode")
+
+	ts.Headers = nil
+	response = ts.GET("/api/shared/" + notebookLink.Token)
+	AssertEquals(t, 404, response.StatusCode, "revoked link StatusCode")
+}
@@ -0,0 +1,225 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eldelto/solvent"
+	"github.com/eldelto/solvent/service"
+	"github.com/eldelto/solvent/web/dto"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+// ReplicaIDParameter is the query parameter a WebSocket client
+// identifies itself with, as browsers cannot set custom headers on
+// WebSocket requests
+const ReplicaIDParameter = "replicaId"
+
+const (
+	liveWriteTimeout = 10 * time.Second
+	livePongTimeout  = 60 * time.Second
+	livePingInterval = livePongTimeout * 9 / 10
+)
+
+// upgrader only accepts WebSocket requests from pages of the same
+// origin. Browsers send the session cookie along with WebSocket requests
+// of any other page, which could otherwise act on behalf of the user.
+// Requests without an Origin header do not come from browsers and are
+// authenticated by their token
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     isSameOrigin,
+}
+
+// isSameOrigin reports whether the request has no Origin header or one
+// that matches the requested host
+func isSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(parsed.Host, r.Host)
+}
+
+// liveSyncNotebook keeps a notebook in sync with a client over a
+// WebSocket connection. The client sends its local state or deltas of
+// it as NotebookDto messages and receives SyncResponseDto messages with
+// the changes it is missing: once after connecting with the whole
+// notebook, as response to every message it sends and whenever any
+// other client has changed the notebook
+func (c *MainController) liveSyncNotebook(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var replica *uuid.UUID
+	if replicaID := r.URL.Query().Get(ReplicaIDParameter); replicaID != "" {
+		parsed, err := uuid.Parse(replicaID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		replica = &parsed
+	}
+
+	// Fail before upgrading the connection if the notebook is unknown
+	if _, err := c.serviceFor(r).Fetch(id); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already responded with an error
+		return
+	}
+	defer conn.Close()
+
+	subscription := c.service.Subscribe(id)
+	defer c.service.Unsubscribe(subscription)
+
+	session := liveSession{
+		conn:     conn,
+		service:  c.serviceFor(r),
+		id:       id,
+		replica:  replica,
+		messages: make(chan *solvent.Notebook),
+		closed:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	defer close(session.done)
+	go session.readMessages()
+
+	if err := session.run(subscription); err != nil {
+		log.Printf("live sync of notebook %v failed: %v", id, err)
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseInternalServerErr, err.Error()),
+			time.Now().Add(liveWriteTimeout))
+	}
+}
+
+// liveSession is a single WebSocket connection of a client that syncs a
+// notebook. All writes happen in run while readMessages is the only
+// reader of the connection
+type liveSession struct {
+	conn     *websocket.Conn
+	service  *service.Service
+	id       uuid.UUID
+	replica  *uuid.UUID
+	version  *service.Version
+	messages chan *solvent.Notebook
+	// closed is closed by readMessages once the client has gone
+	closed chan struct{}
+	// done is closed once the session stops writing to the client
+	done chan struct{}
+}
+
+func (s *liveSession) readMessages() {
+	defer close(s.closed)
+
+	// Larger messages close the connection instead of being decoded
+	s.conn.SetReadLimit(MaxRequestBodySize)
+	s.conn.SetReadDeadline(time.Now().Add(livePongTimeout))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(livePongTimeout))
+	})
+
+	for {
+		var request dto.NotebookDto
+		if err := s.conn.ReadJSON(&request); err != nil {
+			return
+		}
+
+		select {
+		case s.messages <- dto.NotebookFromDto(&request):
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *liveSession) run(subscription *service.Subscription) error {
+	pings := time.NewTicker(livePingInterval)
+	defer pings.Stop()
+
+	if err := s.sendChanges(); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case notebook := <-s.messages:
+			if err := s.sync(notebook); err != nil {
+				return err
+			}
+		case <-subscription.C:
+			if err := s.sendChanges(); err != nil {
+				return err
+			}
+		case <-pings.C:
+			deadline := time.Now().Add(liveWriteTimeout)
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				return nil
+			}
+		case <-s.closed:
+			return nil
+		}
+	}
+}
+
+// sync merges the notebook sent by the client and responds with the
+// changes of other clients it is missing
+func (s *liveSession) sync(notebook *solvent.Notebook) error {
+	if s.replica != nil {
+		if err := s.service.Acknowledge(*s.replica, notebook); err != nil {
+			return err
+		}
+	}
+
+	delta, version, err := s.service.Sync(s.id, s.version, notebook)
+	if err != nil {
+		return err
+	}
+
+	return s.send(delta, version)
+}
+
+// sendChanges sends the changes since the last Version the client has
+// received if there are any
+func (s *liveSession) sendChanges() error {
+	if s.version != nil && s.service.Version(s.id) == *s.version {
+		return nil
+	}
+
+	delta, version, err := s.service.Changes(s.id, s.version)
+	if err != nil {
+		return err
+	}
+
+	return s.send(delta, version)
+}
+
+func (s *liveSession) send(delta *solvent.Notebook, version service.Version) error {
+	s.version = &version
+	response := dto.SyncResponseDto{
+		Version: dto.VersionToDto(version),
+		Delta:   dto.NotebookToDto(delta),
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+	return s.conn.WriteJSON(response)
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/eldelto/solvent/web/dto"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+func TestLiveSyncNotebook(t *testing.T) {
+	c := newTestController()
+	user, token := newTestUser(t, c.accounts, "user")
+	server := httptest.NewServer(c.router)
+	defer server.Close()
+
+	notebook := createTestNotebook(t, c, user)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/notebook/" + notebook.ID.String() + "/live"
+
+	conn0 := dialLive(t, url, token)
+	defer conn0.Close()
+	conn1 := dialLive(t, url, token)
+	defer conn1.Close()
+
+	// Clients receive the whole notebook after connecting
+	response0 := readLive(t, conn0)
+	AssertEquals(t, notebook.ID, response0.Delta.ID, "response0.Delta.ID")
+	readLive(t, conn1)
+
+	client0 := dto.NotebookFromDto(&response0.Delta)
+	list, _ := client0.AddList("list0")
+	err := conn0.WriteJSON(dto.NotebookToDto(client0))
+	AssertEquals(t, nil, err, "conn0.WriteJSON error")
+
+	// The sender does not receive its own changes again
+	response0 = readLive(t, conn0)
+	AssertEquals(t, []uuid.UUID{}, liveListIDs(response0), "response0 lists")
+
+	// Other clients receive the changes as soon as they happen
+	response1 := readLive(t, conn1)
+	AssertEquals(t, []uuid.UUID{list.ID}, liveListIDs(response1), "response1 lists")
+	AssertEquals(t, response0.Version, response1.Version, "response1.Version")
+}
+
+func TestLiveSyncUnknownNotebook(t *testing.T) {
+	c := newTestController()
+	_, token := newTestUser(t, c.accounts, "user")
+	server := httptest.NewServer(c.router)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/notebook/" + uuid.NewString() + "/live"
+	_, response, err := websocket.DefaultDialer.Dial(url, authorizationHeader(token))
+	AssertNotEquals(t, nil, err, "websocket.Dial error")
+	AssertEquals(t, 404, response.StatusCode, "response.StatusCode")
+}
+
+func TestLiveSyncRejectsOtherOrigins(t *testing.T) {
+	c := newTestController()
+	user, token := newTestUser(t, c.accounts, "user")
+	server := httptest.NewServer(c.router)
+	defer server.Close()
+
+	notebook := createTestNotebook(t, c, user)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/notebook/" + notebook.ID.String() + "/live"
+
+	header := authorizationHeader(token)
+	header.Set("Origin", "https://example.com")
+	_, response, err := websocket.DefaultDialer.Dial(url, header)
+	AssertNotEquals(t, nil, err, "websocket.Dial error")
+	AssertEquals(t, 403, response.StatusCode, "response.StatusCode")
+
+	header.Set("Origin", server.URL)
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	AssertEquals(t, nil, err, "same origin websocket.Dial error")
+	conn.Close()
+}
+
+func TestLiveSyncClosesOnOversizedMessages(t *testing.T) {
+	c := newTestController()
+	user, token := newTestUser(t, c.accounts, "user")
+	server := httptest.NewServer(c.router)
+	defer server.Close()
+
+	notebook := createTestNotebook(t, c, user)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/notebook/" + notebook.ID.String() + "/live"
+
+	conn := dialLive(t, url, token)
+	defer conn.Close()
+	readLive(t, conn)
+
+	conn.WriteMessage(websocket.TextMessage, make([]byte, MaxRequestBodySize+1))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := conn.ReadMessage()
+	// The connection is closed instead of waiting for the next message
+	_, closed := err.(*websocket.CloseError)
+	AssertEquals(t, true, closed, "connection closed")
+}
+
+func dialLive(t *testing.T, url, token string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(url, authorizationHeader(token))
+	if err != nil {
+		t.Fatalf("websocket.Dial error: %v", err)
+	}
+
+	return conn
+}
+
+func readLive(t *testing.T, conn *websocket.Conn) dto.SyncResponseDto {
+	var response dto.SyncResponseDto
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("conn.ReadJSON error: %v", err)
+	}
+
+	return response
+}
+
+func liveListIDs(response dto.SyncResponseDto) []uuid.UUID {
+	ids := []uuid.UUID{}
+	for _, list := range dto.NotebookFromDto(&response.Delta).GetLists() {
+		ids = append(ids, list.ID)
+	}
+
+	return ids
+}
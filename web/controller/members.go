@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/eldelto/solvent/service"
+	"github.com/eldelto/solvent/web/dto"
+)
+
+func (c *MainController) fetchMembers(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id")
+	if !ok {
+		return
+	}
+
+	members, err := c.accounts.Members(requestUser(r), ids[0])
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	dtos := make([]dto.MemberDto, 0, len(members))
+	for _, member := range members {
+		dtos = append(dtos, dto.MemberToDto(member))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dtos)
+}
+
+func (c *MainController) shareNotebook(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var request dto.ShareRequestDto
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	role, err := service.ParseRole(request.Role)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	member, err := c.accounts.Share(requestUser(r), ids[0], request.Name, role)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.MemberToDto(*member))
+}
+
+func (c *MainController) unshareNotebook(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id", "userId")
+	if !ok {
+		return
+	}
+
+	if err := c.accounts.Unshare(requestUser(r), ids[0], ids[1]); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
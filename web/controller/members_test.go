@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"testing"
+
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/eldelto/solvent/web/dto"
+)
+
+func TestShareNotebook(t *testing.T) {
+	c := newTestController()
+	owner, ownerToken := newTestUser(t, c.accounts, "owner")
+	viewer, viewerToken := newTestUser(t, c.accounts, "viewer")
+	ts := NewTestServer(t, c.router)
+	defer ts.Close()
+
+	notebook := createTestNotebook(t, c, owner)
+	path := "/api/notebook/" + notebook.ID.String()
+
+	ts.Headers = authorizationHeaders(ownerToken)
+	response := ts.PUT(path+"/members", `{"name": "viewer", "role": "viewer"}`)
+	AssertEquals(t, 200, response.StatusCode, "share StatusCode")
+
+	response = ts.PUT(path+"/members", `{"name": "viewer", "role": "admin"}`)
+	AssertEquals(t, 400, response.StatusCode, "unknown role StatusCode")
+
+	ts.Headers = authorizationHeaders(viewerToken)
+	response = ts.GET(path + "/members")
+	AssertEquals(t, 200, response.StatusCode, "members StatusCode")
+	var members []dto.MemberDto
+	response.Decode(&members)
+	AssertEquals(t, []dto.MemberDto{
+		{UserID: owner.ID, Name: "owner", Role: "owner"},
+		{UserID: viewer.ID, Name: "viewer", Role: "viewer"},
+	}, members, "members")
+
+	// Viewers can fetch the notebook but cannot change it
+	response = ts.GET(path)
+	AssertEquals(t, 200, response.StatusCode, "fetch StatusCode")
+
+	response = ts.POST(path+"/lists", `{"title": "list"}`)
+	AssertEquals(t, 403, response.StatusCode, "add list StatusCode")
+
+	response = ts.PUT(path+"/members", `{"name": "viewer", "role": "editor"}`)
+	AssertEquals(t, 403, response.StatusCode, "share StatusCode")
+
+	ts.Headers = authorizationHeaders(ownerToken)
+	ts.PUT(path+"/members", `{"name": "viewer", "role": "editor"}`)
+
+	ts.Headers = authorizationHeaders(viewerToken)
+	response = ts.POST(path+"/lists", `{"title": "list"}`)
+	AssertEquals(t, 201, response.StatusCode, "add list StatusCode")
+
+	ts.Headers = authorizationHeaders(ownerToken)
+	response = ts.DELETE(path + "/members/" + viewer.ID.String())
+	AssertEquals(t, 204, response.StatusCode, "unshare StatusCode")
+
+	ts.Headers = authorizationHeaders(viewerToken)
+	response = ts.GET(path)
+	AssertEquals(t, 403, response.StatusCode, "fetch StatusCode")
+}
@@ -0,0 +1,276 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/eldelto/solvent"
+	"github.com/eldelto/solvent/web/dto"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// registerResourceRoutes registers the routes that change single lists
+// and items of a notebook, so simple clients do not have to deal with
+// the CRDTs of the whole notebook
+func (c *MainController) registerResourceRoutes(r *mux.Router) {
+	lists := "/api/notebook/{id}/lists"
+	list := lists + "/{listId}"
+	items := list + "/items"
+	item := items + "/{itemId}"
+
+	r.Handle(lists, c.baseMiddleWare(c.addList)).Methods("POST")
+	r.Handle(list, c.baseMiddleWare(c.renameList)).Methods("PATCH")
+	r.Handle(list+"/move", c.baseMiddleWare(c.moveList)).Methods("POST")
+	r.Handle(list, c.baseMiddleWare(c.removeList)).Methods("DELETE")
+	r.Handle(items, c.baseMiddleWare(c.addItem)).Methods("POST")
+	r.Handle(item, c.baseMiddleWare(c.renameItem)).Methods("PATCH")
+	r.Handle(item+"/check", c.baseMiddleWare(c.checkItem)).Methods("POST")
+	r.Handle(item+"/uncheck", c.baseMiddleWare(c.uncheckItem)).Methods("POST")
+	r.Handle(item+"/move", c.baseMiddleWare(c.moveItem)).Methods("POST")
+	r.Handle(item, c.baseMiddleWare(c.removeItem)).Methods("DELETE")
+}
+
+func (c *MainController) addList(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var request dto.TitleRequestDto
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	c.doOnList(w, r, ids[0], http.StatusCreated, solvent.OperationRecord{
+		Kind:  solvent.AddListOperation,
+		Title: request.Title,
+	})
+}
+
+func (c *MainController) renameList(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id", "listId")
+	if !ok {
+		return
+	}
+
+	var request dto.TitleRequestDto
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	c.doOnList(w, r, ids[0], http.StatusOK, solvent.OperationRecord{
+		Kind:   solvent.RenameListOperation,
+		ListID: ids[1],
+		Title:  request.Title,
+	})
+}
+
+func (c *MainController) moveList(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id", "listId")
+	if !ok {
+		return
+	}
+
+	var request dto.MoveRequestDto
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	c.doOnList(w, r, ids[0], http.StatusOK, solvent.OperationRecord{
+		Kind:   solvent.MoveListOperation,
+		ListID: ids[1],
+		Index:  request.TargetIndex,
+	})
+}
+
+func (c *MainController) removeList(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id", "listId")
+	if !ok {
+		return
+	}
+
+	// Unknown lists are reported instead of being ignored
+	_, _, err := c.serviceFor(r).Do(ids[0], solvent.OperationRecord{
+		Kind:   solvent.RemoveListOperation,
+		ListID: ids[1],
+	})
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *MainController) addItem(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id", "listId")
+	if !ok {
+		return
+	}
+
+	var request dto.TitleRequestDto
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	c.doOnItem(w, r, ids[0], http.StatusCreated, solvent.OperationRecord{
+		Kind:   solvent.AddItemOperation,
+		ListID: ids[1],
+		Title:  request.Title,
+	})
+}
+
+func (c *MainController) renameItem(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id", "listId", "itemId")
+	if !ok {
+		return
+	}
+
+	var request dto.TitleRequestDto
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	c.doOnItem(w, r, ids[0], http.StatusOK, solvent.OperationRecord{
+		Kind:   solvent.RenameItemOperation,
+		ListID: ids[1],
+		ItemID: ids[2],
+		Title:  request.Title,
+	})
+}
+
+func (c *MainController) checkItem(w http.ResponseWriter, r *http.Request) {
+	c.setChecked(w, r, true)
+}
+
+func (c *MainController) uncheckItem(w http.ResponseWriter, r *http.Request) {
+	c.setChecked(w, r, false)
+}
+
+func (c *MainController) setChecked(w http.ResponseWriter, r *http.Request, checked bool) {
+	ids, ok := pathIDs(w, r, "id", "listId", "itemId")
+	if !ok {
+		return
+	}
+
+	c.doOnItem(w, r, ids[0], http.StatusOK, solvent.OperationRecord{
+		Kind:    solvent.CheckItemOperation,
+		ListID:  ids[1],
+		ItemID:  ids[2],
+		Checked: checked,
+	})
+}
+
+func (c *MainController) moveItem(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id", "listId", "itemId")
+	if !ok {
+		return
+	}
+
+	var request dto.MoveRequestDto
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	c.doOnItem(w, r, ids[0], http.StatusOK, solvent.OperationRecord{
+		Kind:   solvent.MoveItemOperation,
+		ListID: ids[1],
+		ItemID: ids[2],
+		Index:  request.TargetIndex,
+	})
+}
+
+func (c *MainController) removeItem(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id", "listId", "itemId")
+	if !ok {
+		return
+	}
+
+	// Unknown items are reported instead of being ignored
+	_, _, err := c.serviceFor(r).Do(ids[0], solvent.OperationRecord{
+		Kind:   solvent.RemoveItemOperation,
+		ListID: ids[1],
+		ItemID: ids[2],
+	})
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// doOnList applies the given operation to the stored notebook, so it
+// can be undone, and responds with the changed list
+func (c *MainController) doOnList(w http.ResponseWriter, r *http.Request, id uuid.UUID, status int, operation solvent.OperationRecord) {
+	notebook, applied, err := c.serviceFor(r).Do(id, operation)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	list, err := notebook.GetList(applied.ListID)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(dto.ToDoListToDto(list))
+}
+
+// doOnItem applies the given operation to the stored notebook, so it
+// can be undone, and responds with the changed item
+func (c *MainController) doOnItem(w http.ResponseWriter, r *http.Request, id uuid.UUID, status int, operation solvent.OperationRecord) {
+	notebook, applied, err := c.serviceFor(r).Do(id, operation)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	list, err := notebook.GetList(applied.ListID)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	item, err := list.GetItem(applied.ItemID)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(dto.ToDoItemToDto(item))
+}
+
+// pathIDs parses the path variables with the given names as UUIDs or
+// responds with an error if any of them is invalid
+func pathIDs(w http.ResponseWriter, r *http.Request, names ...string) ([]uuid.UUID, bool) {
+	vars := mux.Vars(r)
+	ids := make([]uuid.UUID, 0, len(names))
+	for _, name := range names {
+		id, err := uuid.Parse(vars[name])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, true
+}
+
+// decodeRequest decodes the JSON request body into the given value or
+// responds with an error if the body is invalid
+func decodeRequest(w http.ResponseWriter, r *http.Request, value interface{}) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(value); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"testing"
+
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/eldelto/solvent/web/dto"
+	"github.com/google/uuid"
+)
+
+func TestListAndItemResources(t *testing.T) {
+	c := newTestController()
+	user, token := newTestUser(t, c.accounts, "user")
+	ts := NewTestServer(t, c.router)
+	ts.Headers = authorizationHeaders(token)
+	defer ts.Close()
+
+	notebook := createTestNotebook(t, c, user)
+	lists := "/api/notebook/" + notebook.ID.String() + "/lists"
+
+	response := ts.POST(lists, `{"title": "list0"}`)
+	AssertEquals(t, 201, response.StatusCode, "add list StatusCode")
+	var list0 dto.ToDoListDto
+	response.Decode(&list0)
+	AssertEquals(t, "list0", list0.Title.Value, "list0.Title")
+
+	response = ts.POST(lists, `{"title": "list1"}`)
+	var list1 dto.ToDoListDto
+	response.Decode(&list1)
+
+	response = ts.PATCH(lists+"/"+list0.ID.String(), `{"title": "renamed"}`)
+	AssertEquals(t, 200, response.StatusCode, "rename list StatusCode")
+
+	response = ts.POST(lists+"/"+list0.ID.String()+"/move", `{"targetIndex": 0}`)
+	AssertEquals(t, 200, response.StatusCode, "move list StatusCode")
+
+	items := lists + "/" + list0.ID.String() + "/items"
+	response = ts.POST(items, `{"title": "item0"}`)
+	AssertEquals(t, 201, response.StatusCode, "add item StatusCode")
+	var item0 dto.ToDoItemDto
+	response.Decode(&item0)
+	AssertEquals(t, "item0", item0.Title.Value, "item0.Title")
+
+	response = ts.POST(items, `{"title": "item1"}`)
+	var item1 dto.ToDoItemDto
+	response.Decode(&item1)
+
+	response = ts.POST(items+"/"+item0.ID.String()+"/check", "")
+	AssertEquals(t, 200, response.StatusCode, "check item StatusCode")
+	var checked dto.ToDoItemDto
+	response.Decode(&checked)
+	AssertEquals(t, true, checked.Checked.Value, "checked.Checked")
+
+	response = ts.PATCH(items+"/"+item1.ID.String(), `{"title": "renamed"}`)
+	AssertEquals(t, 200, response.StatusCode, "rename item StatusCode")
+
+	response = ts.POST(items+"/"+item1.ID.String()+"/move", `{"targetIndex": 0}`)
+	AssertEquals(t, 200, response.StatusCode, "move item StatusCode")
+
+	response = ts.DELETE(lists + "/" + list1.ID.String())
+	AssertEquals(t, 204, response.StatusCode, "remove list StatusCode")
+
+	stored, _ := c.service.Fetch(notebook.ID)
+	storedLists := stored.GetLists()
+	AssertEquals(t, 1, len(storedLists), "len(storedLists)")
+	AssertEquals(t, "renamed", storedLists[0].Title.Value, "storedLists[0].Title")
+
+	storedItems := storedLists[0].GetItems()
+	AssertEquals(t, 2, len(storedItems), "len(storedItems)")
+	AssertEquals(t, item1.ID, storedItems[0].ID, "storedItems[0].ID")
+	AssertEquals(t, "renamed", storedItems[0].Title.Value, "storedItems[0].Title")
+	AssertEquals(t, true, storedItems[1].Checked.Value, "storedItems[1].Checked")
+
+	response = ts.POST(items+"/"+item0.ID.String()+"/uncheck", "")
+	AssertEquals(t, 200, response.StatusCode, "uncheck item StatusCode")
+	response = ts.DELETE(items + "/" + item0.ID.String())
+	AssertEquals(t, 204, response.StatusCode, "remove item StatusCode")
+
+	stored, _ = c.service.Fetch(notebook.ID)
+	list, _ := stored.GetList(list0.ID)
+	AssertEquals(t, 1, len(list.GetItems()), "len(list.GetItems)")
+}
+
+func TestUnknownListAndItemResources(t *testing.T) {
+	c := newTestController()
+	user, token := newTestUser(t, c.accounts, "user")
+	ts := NewTestServer(t, c.router)
+	ts.Headers = authorizationHeaders(token)
+	defer ts.Close()
+
+	notebook := createTestNotebook(t, c, user)
+	list, _ := notebook.AddList("list")
+	c.service.Update(notebook)
+	lists := "/api/notebook/" + notebook.ID.String() + "/lists"
+
+	response := ts.POST("/api/notebook/"+uuid.NewString()+"/lists", `{"title": "list"}`)
+	AssertEquals(t, 404, response.StatusCode, "unknown notebook StatusCode")
+
+	response = ts.DELETE(lists + "/" + uuid.NewString())
+	AssertEquals(t, 404, response.StatusCode, "unknown list StatusCode")
+
+	response = ts.POST(lists+"/"+list.ID.String()+"/items/"+uuid.NewString()+"/check", "")
+	AssertEquals(t, 404, response.StatusCode, "unknown item StatusCode")
+
+	response = ts.POST(lists, `{"name": "list"}`)
+	AssertEquals(t, 400, response.StatusCode, "invalid request StatusCode")
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/eldelto/solvent"
+	"github.com/eldelto/solvent/web/dto"
+	"github.com/google/uuid"
+)
+
+// undo reverts the most recent change the user has made to the list and
+// item resources of the notebook and responds with the notebook
+func (c *MainController) undo(w http.ResponseWriter, r *http.Request) {
+	c.undoOrRedo(w, r, c.serviceFor(r).Undo)
+}
+
+// redo re-applies the most recent change the user has undone and
+// responds with the notebook
+func (c *MainController) redo(w http.ResponseWriter, r *http.Request) {
+	c.undoOrRedo(w, r, c.serviceFor(r).Redo)
+}
+
+func (c *MainController) undoOrRedo(w http.ResponseWriter, r *http.Request, apply func(id uuid.UUID) (*solvent.Notebook, error)) {
+	ids, ok := pathIDs(w, r, "id")
+	if !ok {
+		return
+	}
+
+	notebook, err := apply(ids[0])
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NotebookToDto(notebook))
+}
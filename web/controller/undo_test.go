@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"testing"
+
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/eldelto/solvent/service"
+	"github.com/eldelto/solvent/web/dto"
+)
+
+func TestUndoAndRedo(t *testing.T) {
+	c := newTestController()
+	owner, ownerToken := newTestUser(t, c.accounts, "owner")
+	_, viewerToken := newTestUser(t, c.accounts, "viewer")
+	ts := NewTestServer(t, c.router)
+	ts.Headers = authorizationHeaders(ownerToken)
+	defer ts.Close()
+
+	notebook := createTestNotebook(t, c, owner)
+	path := "/api/notebook/" + notebook.ID.String()
+
+	response := ts.POST(path+"/undo", "")
+	AssertEquals(t, 400, response.StatusCode, "nothing to undo StatusCode")
+
+	response = ts.POST(path+"/lists", `{"title": "list0"}`)
+	var list dto.ToDoListDto
+	response.Decode(&list)
+	response = ts.PATCH(path+"/lists/"+list.ID.String(), `{"title": "renamed"}`)
+	AssertEquals(t, 200, response.StatusCode, "rename list StatusCode")
+
+	response = ts.POST(path+"/undo", "")
+	AssertEquals(t, 200, response.StatusCode, "undo StatusCode")
+	var undone dto.NotebookDto
+	response.Decode(&undone)
+	AssertEquals(t, "list0", undone.ToDoLists.LiveSet[0].Title.Value, "undone title")
+
+	// Only editors can undo and redo
+	c.accounts.Share(owner, notebook.ID, "viewer", service.RoleViewer)
+	ts.Headers = authorizationHeaders(viewerToken)
+	response = ts.POST(path+"/redo", "")
+	AssertEquals(t, 403, response.StatusCode, "viewer redo StatusCode")
+
+	ts.Headers = authorizationHeaders(ownerToken)
+	response = ts.POST(path+"/redo", "")
+	AssertEquals(t, 200, response.StatusCode, "redo StatusCode")
+
+	redone, _ := c.service.Fetch(notebook.ID)
+	AssertEquals(t, "renamed", redone.GetLists()[0].Title.Value, "redone title")
+
+	response = ts.POST(path+"/redo", "")
+	AssertEquals(t, 400, response.StatusCode, "nothing to redo StatusCode")
+}
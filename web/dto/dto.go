@@ -1,65 +1,242 @@
 package dto
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/eldelto/solvent"
 	"github.com/eldelto/solvent/crdt"
+	"github.com/eldelto/solvent/service"
 	"github.com/google/uuid"
 )
 
 // TODO: Write custom Unmarshal functions to check for required fields
 
+// orSetKind marks a set DTO that is backed by an OR-Set. Set DTOs
+// without a kind are backed by a 2P-Set
+const orSetKind = "orSet"
+
+// The slices of the set DTOs are sorted, so the JSON representation of
+// a notebook is stable and can be used to derive its ETag
+
+// LessID orders UUIDs by their bytes
+func LessID(a, b uuid.UUID) bool {
+	return bytes.Compare(a[:], b[:]) < 0
+}
+
+func tagSetToDto(tags crdt.TagSet) []uuid.UUID {
+	dtos := make([]uuid.UUID, 0, len(tags))
+	for tag := range tags {
+		dtos = append(dtos, tag)
+	}
+	sort.Slice(dtos, func(i, j int) bool { return LessID(dtos[i], dtos[j]) })
+
+	return dtos
+}
+
+func tagSetFromDto(dtos []uuid.UUID) crdt.TagSet {
+	tags := make(crdt.TagSet, len(dtos))
+	for _, tag := range dtos {
+		tags[tag] = struct{}{}
+	}
+
+	return tags
+}
+
+// TimestampDto is a DTO representing a crdt.Timestamp. It is embedded
+// into the DTOs of the LWW registers and only adds the counter and
+// replica next to the updatedAt field so registers that were stored
+// with a plain wall clock timestamp can still be read
+type TimestampDto struct {
+	UpdatedAt int64     `json:"updatedAt"`
+	Counter   uint32    `json:"counter"`
+	Replica   uuid.UUID `json:"replica"`
+}
+
+func timestampToDto(timestamp crdt.Timestamp) TimestampDto {
+	return TimestampDto{
+		UpdatedAt: timestamp.WallTime,
+		Counter:   timestamp.Counter,
+		Replica:   timestamp.Replica,
+	}
+}
+
+func timestampFromDto(timestamp TimestampDto) crdt.Timestamp {
+	return crdt.Timestamp{
+		WallTime: timestamp.UpdatedAt,
+		Counter:  timestamp.Counter,
+		Replica:  timestamp.Replica,
+	}
+}
+
 type OrderValueDto struct {
-	Value     float64 `json:"value"`
-	UpdatedAt int64   `json:"updatedAt"`
+	Value string `json:"value"`
+	TimestampDto
+}
+
+// UnmarshalJSON additionally accepts a number as value to stay
+// compatible with OrderValues that were stored before they became
+// positions
+func (o *OrderValueDto) UnmarshalJSON(data []byte) error {
+	var dto struct {
+		Value json.RawMessage `json:"value"`
+		TimestampDto
+	}
+	if err := json.Unmarshal(data, &dto); err != nil {
+		return err
+	}
+
+	var legacyValue float64
+	if err := json.Unmarshal(dto.Value, &legacyValue); err == nil {
+		*o = OrderValueDto{
+			Value:        legacyPosition(legacyValue),
+			TimestampDto: dto.TimestampDto,
+		}
+		return nil
+	}
+
+	var value string
+	if len(dto.Value) > 0 {
+		if err := json.Unmarshal(dto.Value, &value); err != nil {
+			return err
+		}
+	}
+	*o = OrderValueDto{
+		Value:        value,
+		TimestampDto: dto.TimestampDto,
+	}
+
+	return nil
+}
+
+// legacyPosition converts a non-negative float order value into a
+// position with the same order by encoding its bits as fixed-width hex
+// digits followed by a non-zero digit
+func legacyPosition(value float64) string {
+	return fmt.Sprintf("%016xV", math.Float64bits(value))
+}
+
+// legacyListPosition creates the position of a ToDoList that was stored
+// before lists got an OrderValue, so they keep their previous order of
+// the newest list coming first
+func legacyListPosition(createdAt int64) string {
+	return fmt.Sprintf("%016xV", uint64(math.MaxInt64-createdAt))
 }
 
 func orderValueToDto(orderValue solvent.OrderValue) OrderValueDto {
 	return OrderValueDto{
-		Value:     orderValue.Value,
-		UpdatedAt: orderValue.UpdatedAt,
+		Value:        orderValue.Value,
+		TimestampDto: timestampToDto(orderValue.UpdatedAt),
 	}
 }
 
 func orderValueFromDto(orderValue OrderValueDto) solvent.OrderValue {
 	return solvent.OrderValue{
 		Value:     orderValue.Value,
-		UpdatedAt: orderValue.UpdatedAt,
+		UpdatedAt: timestampFromDto(orderValue.TimestampDto),
 	}
 }
 
 type TitleDto struct {
-	Value     string `json:"value"`
-	UpdatedAt int64  `json:"updatedAt"`
+	Value string `json:"value"`
+	TimestampDto
+}
+
+// UnmarshalJSON additionally accepts a plain string as title to stay
+// compatible with ToDoItems that were stored before their titles got an
+// update timestamp
+func (t *TitleDto) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*t = TitleDto{Value: value}
+		return nil
+	}
+
+	// Alias type without the UnmarshalJSON method to prevent recursion
+	type titleDto TitleDto
+	var dto titleDto
+	if err := json.Unmarshal(data, &dto); err != nil {
+		return err
+	}
+	*t = TitleDto(dto)
+
+	return nil
 }
 
 func titleToDto(title solvent.Title) TitleDto {
 	return TitleDto{
-		Value:     title.Value,
-		UpdatedAt: title.UpdatedAt,
+		Value:        title.Value,
+		TimestampDto: timestampToDto(title.UpdatedAt),
 	}
 }
 
 func titleFromDto(title TitleDto) solvent.Title {
 	return solvent.Title{
 		Value:     title.Value,
-		UpdatedAt: title.UpdatedAt,
+		UpdatedAt: timestampFromDto(title.TimestampDto),
+	}
+}
+
+type CheckedDto struct {
+	Value bool `json:"value"`
+	TimestampDto
+}
+
+// UnmarshalJSON additionally accepts a plain boolean as checked state
+// to migrate ToDoItems that were stored before their checked state got
+// an update timestamp
+func (c *CheckedDto) UnmarshalJSON(data []byte) error {
+	var value bool
+	if err := json.Unmarshal(data, &value); err == nil {
+		*c = CheckedDto{Value: value}
+		return nil
+	}
+
+	// Alias type without the UnmarshalJSON method to prevent recursion
+	type checkedDto CheckedDto
+	var dto checkedDto
+	if err := json.Unmarshal(data, &dto); err != nil {
+		return err
+	}
+	*c = CheckedDto(dto)
+
+	return nil
+}
+
+func checkedToDto(checked solvent.Checked) CheckedDto {
+	return CheckedDto{
+		Value:        checked.Value,
+		TimestampDto: timestampToDto(checked.UpdatedAt),
+	}
+}
+
+func checkedFromDto(checked CheckedDto) solvent.Checked {
+	return solvent.Checked{
+		Value:     checked.Value,
+		UpdatedAt: timestampFromDto(checked.TimestampDto),
 	}
 }
 
 // ToDoItemDto is a DTO representing a ToDoItem as JSON"
 type ToDoItemDto struct {
 	ID         uuid.UUID     `json:"id"`
-	Title      string        `json:"title"`
-	Checked    bool          `json:"checked"`
+	Title      TitleDto      `json:"title"`
+	Checked    CheckedDto    `json:"checked"`
 	OrderValue OrderValueDto `json:"orderValue"`
 }
 
 // ToDoItemToDto converts a ToDoItem to its DTO representation
-func toDoItemToDto(item solvent.ToDoItem) ToDoItemDto {
+func ToDoItemToDto(item solvent.ToDoItem) ToDoItemDto {
 	return ToDoItemDto{
 		ID:         item.ID,
-		Title:      item.Title,
-		Checked:    item.Checked,
+		Title:      titleToDto(item.Title),
+		Checked:    checkedToDto(item.Checked),
 		OrderValue: orderValueToDto(item.OrderValue),
 	}
 }
@@ -68,48 +245,95 @@ func toDoItemToDto(item solvent.ToDoItem) ToDoItemDto {
 func toDoItemFromDto(item ToDoItemDto) solvent.ToDoItem {
 	return solvent.ToDoItem{
 		ID:         item.ID,
-		Title:      item.Title,
-		Checked:    item.Checked,
+		Title:      titleFromDto(item.Title),
+		Checked:    checkedFromDto(item.Checked),
 		OrderValue: orderValueFromDto(item.OrderValue),
 	}
 }
 
 type ToDoItemPSetDto struct {
-	LiveSet      []ToDoItemDto `json:"liveSet"`
-	TombstoneSet []ToDoItemDto `json:"tombstoneSet"`
+	Kind         string              `json:"kind,omitempty"`
+	LiveSet      []ToDoItemDto       `json:"liveSet"`
+	TombstoneSet []ToDoItemDto       `json:"tombstoneSet"`
+	Entries      []TaggedToDoItemDto `json:"entries,omitempty"`
+	RemovedTags  []uuid.UUID         `json:"removedTags,omitempty"`
+}
+
+// TaggedToDoItemDto is a DTO representing a single entry of an OR-Set
+// backed ToDoItemPSet
+type TaggedToDoItemDto struct {
+	Tag  uuid.UUID   `json:"tag"`
+	Item ToDoItemDto `json:"item"`
 }
 
 func toDoItemPSetToDto(set solvent.ToDoItemPSet) ToDoItemPSetDto {
-	return ToDoItemPSetDto{
-		LiveSet:      itemMapToToDoItemDtos(set.LiveSet),
-		TombstoneSet: itemMapToToDoItemDtos(set.TombstoneSet),
+	switch set := set.(type) {
+	case *crdt.PSet[uuid.UUID, *solvent.ToDoItem]:
+		return ToDoItemPSetDto{
+			LiveSet:      itemMapToToDoItemDtos(set.LiveSet),
+			TombstoneSet: itemMapToToDoItemDtos(set.TombstoneSet),
+		}
+	case *crdt.ORSet[uuid.UUID, *solvent.ToDoItem]:
+		entries := []TaggedToDoItemDto{}
+		for _, taggedItems := range set.Entries {
+			for tag, item := range taggedItems {
+				entries = append(entries, TaggedToDoItemDto{Tag: tag, Item: ToDoItemToDto(*item)})
+			}
+		}
+		sort.Slice(entries, func(i, j int) bool { return LessID(entries[i].Tag, entries[j].Tag) })
+
+		return ToDoItemPSetDto{
+			Kind:         orSetKind,
+			LiveSet:      []ToDoItemDto{},
+			TombstoneSet: []ToDoItemDto{},
+			Entries:      entries,
+			RemovedTags:  tagSetToDto(set.RemovedTags),
+		}
+	default:
+		return ToDoItemPSetDto{
+			LiveSet:      itemMapToToDoItemDtos(set.LiveView()),
+			TombstoneSet: []ToDoItemDto{},
+		}
 	}
 }
 
 func toDoItemPSetFromDto(set ToDoItemPSetDto) solvent.ToDoItemPSet {
-	toDoItemPSet := solvent.NewToDoItemPSet()
-	toDoItemPSet.LiveSet = itemMapFromToDoItemDtos(set.LiveSet)
-	toDoItemPSet.TombstoneSet = itemMapFromToDoItemDtos(set.TombstoneSet)
+	if set.Kind == orSetKind {
+		orset := crdt.NewORSet[uuid.UUID, *solvent.ToDoItem]("ToDoItemPSet")
+		for _, entry := range set.Entries {
+			toDoItem := toDoItemFromDto(entry.Item)
+			if _, ok := orset.Entries[toDoItem.ID]; !ok {
+				orset.Entries[toDoItem.ID] = crdt.TaggedItems[*solvent.ToDoItem]{}
+			}
+			orset.Entries[toDoItem.ID][entry.Tag] = &toDoItem
+		}
+		orset.RemovedTags = tagSetFromDto(set.RemovedTags)
+
+		return &orset
+	}
+
+	pset := crdt.NewPSet[uuid.UUID, *solvent.ToDoItem]("ToDoItemPSet")
+	pset.LiveSet = itemMapFromToDoItemDtos(set.LiveSet)
+	pset.TombstoneSet = itemMapFromToDoItemDtos(set.TombstoneSet)
 
-	return toDoItemPSet
+	return &pset
 }
 
-func itemMapToToDoItemDtos(itemMap crdt.ItemMap) []ToDoItemDto {
+func itemMapToToDoItemDtos(itemMap solvent.ToDoItemMap) []ToDoItemDto {
 	dtos := make([]ToDoItemDto, 0, len(itemMap))
 	for _, value := range itemMap {
-		toDoItem := *value.(*solvent.ToDoItem)
-		dtos = append(dtos, toDoItemToDto(toDoItem))
+		dtos = append(dtos, ToDoItemToDto(*value))
 	}
+	sort.Slice(dtos, func(i, j int) bool { return LessID(dtos[i].ID, dtos[j].ID) })
 
 	return dtos
 }
 
-func itemMapFromToDoItemDtos(dtos []ToDoItemDto) crdt.ItemMap {
-	itemMap := make(crdt.ItemMap, len(dtos))
+func itemMapFromToDoItemDtos(dtos []ToDoItemDto) solvent.ToDoItemMap {
+	itemMap := make(solvent.ToDoItemMap, len(dtos))
 	for _, dto := range dtos {
 		toDoItem := toDoItemFromDto(dto)
-		key := toDoItem.Identifier()
-		itemMap[key] = &toDoItem
+		itemMap[toDoItem.Key()] = &toDoItem
 	}
 
 	return itemMap
@@ -117,68 +341,123 @@ func itemMapFromToDoItemDtos(dtos []ToDoItemDto) crdt.ItemMap {
 
 // ToDoListDto is a DTO representing a ToDoList as JSON"
 type ToDoListDto struct {
-	ID        uuid.UUID       `json:"id"`
-	Title     TitleDto        `json:"title"`
-	ToDoItems ToDoItemPSetDto `json:"toDoItems"`
-	CreatedAt int64           `json:"createdAt"`
+	ID         uuid.UUID       `json:"id"`
+	Title      TitleDto        `json:"title"`
+	ToDoItems  ToDoItemPSetDto `json:"toDoItems"`
+	OrderValue OrderValueDto   `json:"orderValue"`
+	CreatedAt  int64           `json:"createdAt"`
 }
 
 // ToDoListToDto converts a ToDoList to its DTO representation
-func toDoListToDto(list *solvent.ToDoList) ToDoListDto {
+func ToDoListToDto(list *solvent.ToDoList) ToDoListDto {
 	return ToDoListDto{
-		ID:        list.ID,
-		Title:     titleToDto(list.Title),
-		ToDoItems: toDoItemPSetToDto(list.ToDoItems),
-		CreatedAt: list.CreatedAt,
+		ID:         list.ID,
+		Title:      titleToDto(list.Title),
+		ToDoItems:  toDoItemPSetToDto(list.ToDoItems),
+		OrderValue: orderValueToDto(list.OrderValue),
+		CreatedAt:  list.CreatedAt,
 	}
 }
 
 // ToDoListFromDto converts a DTO representation to an actual ToDoList
 func toDoListFromDto(list *ToDoListDto) solvent.ToDoList {
+	orderValue := orderValueFromDto(list.OrderValue)
+	if orderValue.Value == "" {
+		orderValue.Value = legacyListPosition(list.CreatedAt)
+	}
+
 	return solvent.ToDoList{
-		ID:        list.ID,
-		Title:     titleFromDto(list.Title),
-		ToDoItems: toDoItemPSetFromDto(list.ToDoItems),
-		CreatedAt: list.CreatedAt,
+		ID:         list.ID,
+		Title:      titleFromDto(list.Title),
+		ToDoItems:  toDoItemPSetFromDto(list.ToDoItems),
+		OrderValue: orderValue,
+		CreatedAt:  list.CreatedAt,
 	}
 }
 
 type ToDoListPSetDto struct {
-	LiveSet      []ToDoListDto `json:"liveSet"`
-	TombstoneSet []ToDoListDto `json:"tombstoneSet"`
+	Kind         string              `json:"kind,omitempty"`
+	LiveSet      []ToDoListDto       `json:"liveSet"`
+	TombstoneSet []ToDoListDto       `json:"tombstoneSet"`
+	Entries      []TaggedToDoListDto `json:"entries,omitempty"`
+	RemovedTags  []uuid.UUID         `json:"removedTags,omitempty"`
+}
+
+// TaggedToDoListDto is a DTO representing a single entry of an OR-Set
+// backed ToDoListPSet
+type TaggedToDoListDto struct {
+	Tag  uuid.UUID   `json:"tag"`
+	List ToDoListDto `json:"list"`
 }
 
 func toDoListPSetToDto(set solvent.ToDoListPSet) ToDoListPSetDto {
-	return ToDoListPSetDto{
-		LiveSet:      itemMapToToDoListDtos(set.LiveSet),
-		TombstoneSet: itemMapToToDoListDtos(set.TombstoneSet),
+	switch set := set.(type) {
+	case *crdt.PSet[uuid.UUID, *solvent.ToDoList]:
+		return ToDoListPSetDto{
+			LiveSet:      itemMapToToDoListDtos(set.LiveSet),
+			TombstoneSet: itemMapToToDoListDtos(set.TombstoneSet),
+		}
+	case *crdt.ORSet[uuid.UUID, *solvent.ToDoList]:
+		entries := []TaggedToDoListDto{}
+		for _, taggedLists := range set.Entries {
+			for tag, list := range taggedLists {
+				entries = append(entries, TaggedToDoListDto{Tag: tag, List: ToDoListToDto(list)})
+			}
+		}
+		sort.Slice(entries, func(i, j int) bool { return LessID(entries[i].Tag, entries[j].Tag) })
+
+		return ToDoListPSetDto{
+			Kind:         orSetKind,
+			LiveSet:      []ToDoListDto{},
+			TombstoneSet: []ToDoListDto{},
+			Entries:      entries,
+			RemovedTags:  tagSetToDto(set.RemovedTags),
+		}
+	default:
+		return ToDoListPSetDto{
+			LiveSet:      itemMapToToDoListDtos(set.LiveView()),
+			TombstoneSet: []ToDoListDto{},
+		}
 	}
 }
 
 func toDoListPSetFromDto(set ToDoListPSetDto) solvent.ToDoListPSet {
-	toDoListPSet := solvent.NewToDoListPSet()
-	toDoListPSet.LiveSet = itemMapFromToDoListDtos(set.LiveSet)
-	toDoListPSet.TombstoneSet = itemMapFromToDoListDtos(set.TombstoneSet)
+	if set.Kind == orSetKind {
+		orset := crdt.NewORSet[uuid.UUID, *solvent.ToDoList]("ToDoListPSet")
+		for _, entry := range set.Entries {
+			toDoList := toDoListFromDto(&entry.List)
+			if _, ok := orset.Entries[toDoList.ID]; !ok {
+				orset.Entries[toDoList.ID] = crdt.TaggedItems[*solvent.ToDoList]{}
+			}
+			orset.Entries[toDoList.ID][entry.Tag] = &toDoList
+		}
+		orset.RemovedTags = tagSetFromDto(set.RemovedTags)
+
+		return &orset
+	}
 
-	return toDoListPSet
+	pset := crdt.NewPSet[uuid.UUID, *solvent.ToDoList]("ToDoListPSet")
+	pset.LiveSet = itemMapFromToDoListDtos(set.LiveSet)
+	pset.TombstoneSet = itemMapFromToDoListDtos(set.TombstoneSet)
+
+	return &pset
 }
 
-func itemMapToToDoListDtos(listMap crdt.ItemMap) []ToDoListDto {
+func itemMapToToDoListDtos(listMap solvent.ToDoListMap) []ToDoListDto {
 	dtos := make([]ToDoListDto, 0, len(listMap))
 	for _, value := range listMap {
-		toDoList := value.(*solvent.ToDoList)
-		dtos = append(dtos, toDoListToDto(toDoList))
+		dtos = append(dtos, ToDoListToDto(value))
 	}
+	sort.Slice(dtos, func(i, j int) bool { return LessID(dtos[i].ID, dtos[j].ID) })
 
 	return dtos
 }
 
-func itemMapFromToDoListDtos(dtos []ToDoListDto) crdt.ItemMap {
-	listMap := make(crdt.ItemMap, len(dtos))
+func itemMapFromToDoListDtos(dtos []ToDoListDto) solvent.ToDoListMap {
+	listMap := make(solvent.ToDoListMap, len(dtos))
 	for _, dto := range dtos {
 		toDoList := toDoListFromDto(&dto)
-		key := toDoList.Identifier()
-		listMap[key] = &toDoList
+		listMap[toDoList.Key()] = &toDoList
 	}
 
 	return listMap
@@ -205,3 +484,235 @@ func NotebookFromDto(notebook *NotebookDto) *solvent.Notebook {
 		CreatedAt: notebook.CreatedAt,
 	}
 }
+
+// VersionDto is a DTO representing the service.Version of a notebook a
+// client has last synced with
+type VersionDto struct {
+	Epoch   uuid.UUID `json:"epoch"`
+	Counter uint64    `json:"counter"`
+}
+
+func VersionToDto(version service.Version) VersionDto {
+	return VersionDto{
+		Epoch:   version.Epoch,
+		Counter: version.Counter,
+	}
+}
+
+func VersionFromDto(version *VersionDto) *service.Version {
+	if version == nil {
+		return nil
+	}
+
+	return &service.Version{
+		Epoch:   version.Epoch,
+		Counter: version.Counter,
+	}
+}
+
+// VersionToEventID encodes the given Version as the ID of a
+// Server-Sent Event
+func VersionToEventID(version service.Version) string {
+	return fmt.Sprintf("%s.%d", version.Epoch, version.Counter)
+}
+
+// VersionFromEventID decodes the ID of a Server-Sent Event into a
+// Version or returns nil if the ID is empty or invalid
+func VersionFromEventID(id string) *service.Version {
+	epoch, counter, ok := strings.Cut(id, ".")
+	if !ok {
+		return nil
+	}
+
+	var version service.Version
+	var err error
+	if version.Epoch, err = uuid.Parse(epoch); err != nil {
+		return nil
+	}
+	if version.Counter, err = strconv.ParseUint(counter, 10, 64); err != nil {
+		return nil
+	}
+
+	return &version
+}
+
+// SyncRequestDto is a DTO holding the local changes of a client and the
+// version it has last synced with or no version for its first sync
+type SyncRequestDto struct {
+	Since *VersionDto `json:"since"`
+	Delta NotebookDto `json:"delta"`
+}
+
+// SyncResponseDto is a DTO holding the changes a client is missing and
+// the version it is synced with afterwards
+type SyncResponseDto struct {
+	Version VersionDto  `json:"version"`
+	Delta   NotebookDto `json:"delta"`
+}
+
+// TitleRequestDto is a DTO holding the title of a list or item a client
+// wants to add or rename
+type TitleRequestDto struct {
+	Title string `json:"title"`
+}
+
+// MoveRequestDto is a DTO holding the index a client wants to move a
+// list or item to
+type MoveRequestDto struct {
+	TargetIndex int `json:"targetIndex"`
+}
+
+// CredentialsDto is a DTO holding the name and password a user
+// registers or logs in with
+type CredentialsDto struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// UserDto is a DTO representing a service.User without its password
+type UserDto struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+func UserToDto(user *service.User) UserDto {
+	return UserDto{
+		ID:   user.ID,
+		Name: user.Name,
+	}
+}
+
+// SessionDto is a DTO holding the token of a new session
+type SessionDto struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// NotebookAccessDto is a DTO holding the role a user has for a notebook
+type NotebookAccessDto struct {
+	ID   uuid.UUID `json:"id"`
+	Role string    `json:"role"`
+}
+
+func NotebookAccessToDto(access service.NotebookAccess) NotebookAccessDto {
+	return NotebookAccessDto{
+		ID:   access.NotebookID,
+		Role: string(access.Role),
+	}
+}
+
+// MemberDto is a DTO representing a user with access to a notebook
+type MemberDto struct {
+	UserID uuid.UUID `json:"userId"`
+	Name   string    `json:"name"`
+	Role   string    `json:"role"`
+}
+
+func MemberToDto(member service.Member) MemberDto {
+	return MemberDto{
+		UserID: member.UserID,
+		Name:   member.Name,
+		Role:   string(member.Role),
+	}
+}
+
+// ShareRequestDto is a DTO holding the name of the user a notebook
+// should be shared with and the role it should have
+type ShareRequestDto struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// ShareLinkRequestDto is a DTO holding the optional list and expiry
+// time of a new share link
+type ShareLinkRequestDto struct {
+	ListID    *uuid.UUID `json:"listId,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// ShareLinkDto is a DTO representing a service.ShareLink. The token is
+// only set in the response to the creation of the link
+type ShareLinkDto struct {
+	ID        uuid.UUID  `json:"id"`
+	Token     string     `json:"token,omitempty"`
+	ListID    *uuid.UUID `json:"listId,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+func ShareLinkToDto(link service.ShareLink) ShareLinkDto {
+	dto := ShareLinkDto{
+		ID:        link.ID,
+		CreatedAt: link.CreatedAt,
+	}
+	if link.ListID != uuid.Nil {
+		listID := link.ListID
+		dto.ListID = &listID
+	}
+	if !link.ExpiresAt.IsZero() {
+		expiresAt := link.ExpiresAt
+		dto.ExpiresAt = &expiresAt
+	}
+
+	return dto
+}
+
+// SharedNotebookDto is the read-only view of a notebook served by share
+// links. It only holds the live lists and items without any of the CRDT
+// metadata
+type SharedNotebookDto struct {
+	ToDoLists []SharedToDoListDto `json:"toDoLists"`
+}
+
+type SharedToDoListDto struct {
+	ID    uuid.UUID           `json:"id"`
+	Title string              `json:"title"`
+	Items []SharedToDoItemDto `json:"items"`
+}
+
+type SharedToDoItemDto struct {
+	ID      uuid.UUID `json:"id"`
+	Title   string    `json:"title"`
+	Checked bool      `json:"checked"`
+}
+
+// SharedToDoListToDto converts a ToDoList to its read-only DTO
+// representation
+func SharedToDoListToDto(list *solvent.ToDoList) SharedToDoListDto {
+	items := list.GetItems()
+	dtos := make([]SharedToDoItemDto, 0, len(items))
+	for _, item := range items {
+		dtos = append(dtos, SharedToDoItemDto{
+			ID:      item.ID,
+			Title:   item.Title.Value,
+			Checked: item.Checked.Value,
+		})
+	}
+
+	return SharedToDoListDto{
+		ID:    list.ID,
+		Title: list.Title.Value,
+		Items: dtos,
+	}
+}
+
+// RevisionDto is a DTO representing a service.Revision. The notebook is
+// only set if a single revision has been fetched
+type RevisionDto struct {
+	Number    int64        `json:"number"`
+	Notebook  *NotebookDto `json:"notebook,omitempty"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+func RevisionToDto(revision service.Revision) RevisionDto {
+	dto := RevisionDto{
+		Number:    revision.Number,
+		CreatedAt: revision.CreatedAt,
+	}
+	if revision.Notebook != nil {
+		notebook := NotebookToDto(revision.Notebook)
+		dto.Notebook = &notebook
+	}
+
+	return dto
+}
@@ -1,10 +1,14 @@
 package dto
 
 import (
+	"encoding/json"
 	"testing"
 
+	"github.com/eldelto/solvent"
 	"github.com/eldelto/solvent/crdt"
 	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/eldelto/solvent/service"
+	"github.com/google/uuid"
 )
 
 func TestToDoListPSetFromDto(t *testing.T) {
@@ -13,9 +17,122 @@ func TestToDoListPSetFromDto(t *testing.T) {
 		TombstoneSet: []ToDoListDto{},
 	}
 
-	pset := toDoListPSetFromDto(dto)
+	pset := toDoListPSetFromDto(dto).(*crdt.PSet[uuid.UUID, *solvent.ToDoList])
 
-	AssertEquals(t, crdt.ItemMap{}, pset.LiveSet, "pset.LiveSet")
-	AssertEquals(t, crdt.ItemMap{}, pset.TombstoneSet, "pset.TombstoneSet")
+	AssertEquals(t, solvent.ToDoListMap{}, pset.LiveSet, "pset.LiveSet")
+	AssertEquals(t, solvent.ToDoListMap{}, pset.TombstoneSet, "pset.TombstoneSet")
 	AssertEquals(t, "ToDoListPSet", pset.Identifier(), "pset.Identifier")
 }
+
+func TestToDoItemDtoTitle(t *testing.T) {
+	var dto ToDoItemDto
+	err := json.Unmarshal([]byte(`{"title": {"value": "item0", "updatedAt": 10}}`), &dto)
+	AssertEquals(t, nil, err, "json.Unmarshal error")
+	AssertEquals(t, TitleDto{Value: "item0", TimestampDto: TimestampDto{UpdatedAt: 10}}, dto.Title, "dto.Title")
+}
+
+func TestLegacyToDoItemDtoTitle(t *testing.T) {
+	var dto ToDoItemDto
+	err := json.Unmarshal([]byte(`{"title": "item0"}`), &dto)
+	AssertEquals(t, nil, err, "json.Unmarshal error")
+	AssertEquals(t, TitleDto{Value: "item0"}, dto.Title, "dto.Title")
+}
+
+func TestToDoItemDtoChecked(t *testing.T) {
+	var dto ToDoItemDto
+	err := json.Unmarshal([]byte(`{"checked": {"value": true, "updatedAt": 10}}`), &dto)
+	AssertEquals(t, nil, err, "json.Unmarshal error")
+	AssertEquals(t, CheckedDto{Value: true, TimestampDto: TimestampDto{UpdatedAt: 10}}, dto.Checked, "dto.Checked")
+}
+
+func TestLegacyToDoItemDtoChecked(t *testing.T) {
+	var dto ToDoItemDto
+	err := json.Unmarshal([]byte(`{"checked": true}`), &dto)
+	AssertEquals(t, nil, err, "json.Unmarshal error")
+	AssertEquals(t, CheckedDto{Value: true}, dto.Checked, "dto.Checked")
+}
+
+func TestORSetNotebookRoundTrip(t *testing.T) {
+	notebook, _ := solvent.NewNotebook(solvent.WithORSets())
+	list, _ := notebook.AddList("list0")
+	id, _ := list.AddItem("item0")
+	list.RemoveItem(id)
+
+	data, err := json.Marshal(NotebookToDto(notebook))
+	AssertEquals(t, nil, err, "json.Marshal error")
+
+	var dto NotebookDto
+	err = json.Unmarshal(data, &dto)
+	AssertEquals(t, nil, err, "json.Unmarshal error")
+
+	// The local change tracking of the notebook is not serialized so the
+	// round trip is compared against the directly converted notebook
+	expected := NotebookToDto(notebook)
+	AssertEquals(t, NotebookFromDto(&expected), NotebookFromDto(&dto), "NotebookFromDto")
+
+	restoredList, err := NotebookFromDto(&dto).GetList(list.ID)
+	AssertEquals(t, nil, err, "GetList error")
+	AssertEquals(t, []solvent.ToDoItem{}, restoredList.GetItems(), "restoredList.GetItems")
+}
+
+func TestToDoItemDtoTimestamp(t *testing.T) {
+	replica := uuid.UUID{15: 1}
+	title := solvent.Title{
+		Value:     "item0",
+		UpdatedAt: crdt.Timestamp{WallTime: 10, Counter: 2, Replica: replica},
+	}
+
+	data, _ := json.Marshal(titleToDto(title))
+	var dto TitleDto
+	err := json.Unmarshal(data, &dto)
+	AssertEquals(t, nil, err, "json.Unmarshal error")
+	AssertEquals(t, title, titleFromDto(dto), "titleFromDto")
+}
+
+func TestLegacyToDoItemDtoOrderValue(t *testing.T) {
+	var dto0, dto1 ToDoItemDto
+	err := json.Unmarshal([]byte(`{"orderValue": {"value": 10, "updatedAt": 10}}`), &dto0)
+	AssertEquals(t, nil, err, "json.Unmarshal error")
+	json.Unmarshal([]byte(`{"orderValue": {"value": 15.5, "updatedAt": 10}}`), &dto1)
+
+	AssertEquals(t, int64(10), dto0.OrderValue.UpdatedAt, "dto0.OrderValue.UpdatedAt")
+	AssertEquals(t, true, dto0.OrderValue.Value < dto1.OrderValue.Value, "dto0.OrderValue < dto1.OrderValue")
+
+	_, err = crdt.PositionBetween(dto0.OrderValue.Value, dto1.OrderValue.Value)
+	AssertEquals(t, nil, err, "crdt.PositionBetween error")
+}
+
+func TestLegacyToDoListDtoOrderValue(t *testing.T) {
+	list0 := toDoListFromDto(&ToDoListDto{CreatedAt: 10})
+	list1 := toDoListFromDto(&ToDoListDto{CreatedAt: 20})
+
+	AssertEquals(t, true, list1.OrderValue.Value < list0.OrderValue.Value, "list1.OrderValue < list0.OrderValue")
+}
+
+func TestNotebookDtoIsStable(t *testing.T) {
+	notebook, _ := solvent.NewNotebook()
+	for i := 0; i < 5; i++ {
+		list, _ := notebook.AddList("list")
+		list.AddItem("item0")
+		itemID, _ := list.AddItem("item1")
+		list.RemoveItem(itemID)
+	}
+
+	expected, err := json.Marshal(NotebookToDto(notebook))
+	AssertEquals(t, nil, err, "json.Marshal error")
+
+	for i := 0; i < 10; i++ {
+		copied, _ := notebook.Copy()
+		actual, _ := json.Marshal(NotebookToDto(copied))
+		AssertEquals(t, string(expected), string(actual), "json.Marshal")
+	}
+}
+
+func TestVersionEventID(t *testing.T) {
+	version := service.Version{Epoch: uuid.New(), Counter: 42}
+	AssertEquals(t, &version, VersionFromEventID(VersionToEventID(version)), "VersionFromEventID")
+
+	for _, id := range []string{"", "42", "epoch.42", uuid.NewString() + ".x"} {
+		AssertEquals(t, (*service.Version)(nil), VersionFromEventID(id), "VersionFromEventID("+id+")")
+	}
+}
@@ -4,13 +4,13 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
-	"github.com/eldelto/solvent"
 	"github.com/eldelto/solvent/internal/conf"
 	serv "github.com/eldelto/solvent/service"
 	"github.com/eldelto/solvent/web/controller"
 	"github.com/eldelto/solvent/web/persistence"
-	"github.com/google/uuid"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 )
@@ -25,34 +25,36 @@ var prodCp = conf.NewFileConfigProvider("conf/prod.properties")
 var secretsCp = conf.NewFileConfigProvider("secrets/prod.properties")
 var config = conf.NewChainConfigProvider([]conf.ConfigProvider{simCp, prodCp, secretsCp})
 
-// var repository = persistence.NewInMemoryRepository()
-var repository, postgresRepositoryErr = persistence.NewPostgresRepository(
-	config.GetString("postgres.host"),
-	config.GetString("postgres.port"),
-	config.GetString("postgres.user"),
-	config.GetString("postgres.password"),
-)
+// Repository persists the notebooks as well as the accounts
+type Repository interface {
+	serv.Repository
+	serv.AccountRepository
+}
+
+var repository, repositoryErr = newRepository(config.GetString("repository.type"))
 
-var service = serv.NewService(repository)
-var mainController = controller.NewMainController(&service)
+var gcHorizon = time.Duration(config.GetFloat("gc.horizonHours") * float64(time.Hour))
+var gcInterval = time.Duration(config.GetFloat("gc.intervalMinutes") * float64(time.Minute))
+var sessionLifetime = time.Duration(config.GetFloat("auth.sessionLifetimeHours") * float64(time.Hour))
+
+var accounts = serv.NewAccounts(repository, serv.WithSessionLifetime(sessionLifetime))
+var service = serv.NewService(repository, serv.WithTombstoneGC(gcHorizon), serv.WithAccounts(accounts))
+var mainController = controller.NewMainController(&service, accounts)
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrate()
+		return
+	}
+
 	// TODO: Where to handle re-connection?
-	if postgresRepositoryErr != nil {
-		panic(postgresRepositoryErr)
+	if repositoryErr != nil {
+		panic(repositoryErr)
 	}
 
 	port := 8080
 
-	// TODO: Remove afterwards
-	notebook, _ := solvent.NewNotebook()
-	notebook.ID = uuid.Nil
-
-	list, _ := notebook.AddList("My Server Side List")
-	list.AddItem("Item0")
-	list.AddItem("Item1")
-
-	repository.Store(notebook)
+	go collectGarbage(gcInterval)
 
 	r := mux.NewRouter()
 	mainController.RegisterRoutes(r)
@@ -66,6 +68,83 @@ func main() {
 	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), nil))
 }
 
+// newRepository creates the Repository of the given type, which is
+// either "postgres", "postgres-normalized", "postgres-events", "sqlite",
+// "bolt" or "memory"
+func newRepository(repositoryType string) (Repository, error) {
+	switch repositoryType {
+	case "postgres":
+		return persistence.NewPostgresRepository(
+			config.GetString("postgres.host"),
+			config.GetString("postgres.port"),
+			config.GetString("postgres.user"),
+			config.GetString("postgres.password"),
+			postgresOptions()...,
+		)
+	case "postgres-normalized":
+		return persistence.NewNormalizedPostgresRepository(
+			config.GetString("postgres.host"),
+			config.GetString("postgres.port"),
+			config.GetString("postgres.user"),
+			config.GetString("postgres.password"),
+			postgresOptions()...,
+		)
+	case "postgres-events":
+		return persistence.NewEventSourcedPostgresRepository(
+			config.GetString("postgres.host"),
+			config.GetString("postgres.port"),
+			config.GetString("postgres.user"),
+			config.GetString("postgres.password"),
+			int(config.GetFloat("events.snapshotInterval")),
+			postgresOptions()...,
+		)
+	case "sqlite":
+		return persistence.NewSQLiteRepository(config.GetString("sqlite.path"))
+	case "bolt":
+		return persistence.NewBoltRepository(config.GetString("bolt.path"))
+	case "memory":
+		return persistence.NewInMemoryRepository(), nil
+	default:
+		return nil, fmt.Errorf("unknown repository type %q", repositoryType)
+	}
+}
+
+func postgresOptions() []persistence.PostgresOption {
+	if config.GetBool("postgres.migrateOnStartup") {
+		return nil
+	}
+
+	return []persistence.PostgresOption{persistence.WithoutMigrations()}
+}
+
+// migrate applies the pending migrations to the PostgreSQL DB
+func migrate() {
+	db, err := persistence.OpenPostgres(
+		config.GetString("postgres.host"),
+		config.GetString("postgres.port"),
+		config.GetString("postgres.user"),
+		config.GetString("postgres.password"),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	version, err := persistence.MigratePostgres(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Migrated PostgreSQL DB to schema version %d\n", version)
+}
+
+func collectGarbage(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := service.CollectGarbage(); err != nil {
+			log.Printf("tombstone garbage collection failed: %v", err)
+		}
+	}
+}
+
 func responseCacheHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Cache-Control", "public, max-age=604800, immutable")
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/eldelto/solvent/internal/conf"
 	. "github.com/eldelto/solvent/internal/testutils"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -21,9 +22,25 @@ type responseDto struct {
 }
 
 func wireTestServer(t *testing.T) *TestServer {
+	if repositoryErr != nil {
+		t.Fatalf("repository is not available: %v", repositoryErr)
+	}
+
 	r := mux.NewRouter()
 	mainController.RegisterRoutes(r)
-	return NewTestServer(t, r)
+	ts := NewTestServer(t, r)
+
+	name := uuid.NewString()
+	if _, err := accounts.Register(name, "password"); err != nil {
+		t.Fatalf("accounts.Register error: %v", err)
+	}
+	token, _, err := accounts.Login(name, "password")
+	if err != nil {
+		t.Fatalf("accounts.Login error: %v", err)
+	}
+	ts.Headers = map[string]string{"Authorization": "Bearer " + token}
+
+	return ts
 }
 
 func TestCreateNotebook(t *testing.T) {
@@ -65,6 +82,28 @@ func TestFetchNotebook(t *testing.T) {
 	AssertNotEquals(t, 0, responseBody.CreatedAt, "responseBody.CreatedAt")
 }
 
+func TestFetchNotebookNotModified(t *testing.T) {
+	ts := wireTestServer(t)
+	defer ts.Close()
+
+	response := ts.POST("/api/notebook", "")
+	var responseBody responseDto
+	err := response.Decode(&responseBody)
+	AssertEquals(t, nil, err, "POST response.Decode error")
+
+	path := "/api/notebook/" + responseBody.ID.String()
+	response = ts.GET(path)
+	etag := response.Header.Get("ETag")
+	AssertNotEquals(t, "", etag, "ETag")
+
+	response = ts.GETWithHeaders(path, map[string]string{"If-None-Match": etag})
+	AssertEquals(t, 304, response.StatusCode, "response.StatusCode")
+
+	response = ts.GETWithHeaders(path, map[string]string{"If-None-Match": `W/"outdated"`})
+	AssertEquals(t, 200, response.StatusCode, "response.StatusCode")
+	AssertEquals(t, etag, response.Header.Get("ETag"), "ETag")
+}
+
 func TestUpdateNotebook(t *testing.T) {
 	ts := wireTestServer(t)
 	defer ts.Close()
@@ -127,3 +166,21 @@ func TestRemoveNotebook(t *testing.T) {
 	response = ts.GET("/api/notebook/" + responseBody.ID.String())
 	AssertEquals(t, 404, response.StatusCode, "GET response.StatusCode")
 }
+
+// TestProdConfig checks that the configuration the server is deployed
+// with has all the keys read on startup. The deployed conf directory
+// replaces the one with sim.properties, so none of its keys are found
+func TestProdConfig(t *testing.T) {
+	prod := conf.NewFileConfigProvider("../deploy/conf/prod.properties")
+
+	for _, key := range []string{"repository.type", "sqlite.path"} {
+		_, err := prod.GetString(key)
+		AssertEquals(t, nil, err, key+" error")
+	}
+	for _, key := range []string{"gc.horizonHours", "gc.intervalMinutes", "auth.sessionLifetimeHours"} {
+		_, err := prod.GetFloat(key)
+		AssertEquals(t, nil, err, key+" error")
+	}
+	_, err := prod.GetBool("postgres.migrateOnStartup")
+	AssertEquals(t, nil, err, "postgres.migrateOnStartup error")
+}
@@ -0,0 +1,660 @@
+package persistence
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/eldelto/solvent"
+	"github.com/eldelto/solvent/service"
+	"github.com/eldelto/solvent/service/errcode"
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	notebooksBucket  = []byte("notebooks")
+	usersBucket      = []byte("users")
+	userNamesBucket  = []byte("user_names")
+	sessionsBucket   = []byte("sessions")
+	ownersBucket     = []byte("notebook_owners")
+	membersBucket    = []byte("notebook_members")
+	shareLinksBucket = []byte("share_links")
+	revisionsBucket  = []byte("notebook_revisions")
+)
+
+// BoltRepository stores notebooks and accounts in an embedded bbolt
+// file. Every notebook is stored as JSON blob keyed by its ID
+type BoltRepository struct {
+	db *bolt.DB
+}
+
+// NewBoltRepository opens the bbolt file at the given path and creates
+// it if it does not exist yet. Only a single process can open the file
+// at a time
+func NewBoltRepository(path string) (*BoltRepository, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, errcode.NewUnknownError(err, "could not open DB")
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{
+			notebooksBucket,
+			usersBucket,
+			userNamesBucket,
+			sessionsBucket,
+			ownersBucket,
+			membersBucket,
+			shareLinksBucket,
+			revisionsBucket,
+		} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, errcode.NewUnknownError(err, "could not initialize DB")
+	}
+
+	return &BoltRepository{db: db}, nil
+}
+
+func (r *BoltRepository) Close() {
+	r.db.Close()
+}
+
+func (r *BoltRepository) Store(notebook *solvent.Notebook) error {
+	data, err := notebookToJson(notebook)
+	if err != nil {
+		return err
+	}
+
+	err = r.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(notebooksBucket).Put(idKey(notebook.ID), data); err != nil {
+			return err
+		}
+
+		return putRevision(tx, notebook.ID, data)
+	})
+
+	return errcode.NewNotebookError(notebook.ID, err, "could not store notebook")
+}
+
+func (r *BoltRepository) Update(notebook *solvent.Notebook) error {
+	data, err := notebookToJson(notebook)
+	if err != nil {
+		return err
+	}
+
+	return r.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(notebooksBucket)
+		if bucket.Get(idKey(notebook.ID)) == nil {
+			return errcode.NewNotFoundError("notebook", notebook.ID)
+		}
+
+		err := bucket.Put(idKey(notebook.ID), data)
+		return errcode.NewNotebookError(notebook.ID, err, "could not update notebook")
+	})
+}
+
+// Modify applies the update function to the stored notebook within a
+// single read-write transaction. bbolt only allows one of them at a
+// time, so concurrent updates are serialized and a crash in between
+// leaves the previous state untouched. The result is recorded as new
+// revision within the same transaction if it has changed
+func (r *BoltRepository) Modify(id uuid.UUID, update service.UpdateFunc) (*solvent.Notebook, error) {
+	var updated *solvent.Notebook
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		notebook, err := getNotebook(tx, id)
+		if err != nil {
+			return err
+		}
+
+		updated, err = update(notebook)
+		if err != nil {
+			return err
+		}
+
+		data, err := notebookToJson(updated)
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(notebooksBucket).Put(idKey(id), data); err != nil {
+			return errcode.NewNotebookError(id, err, "could not store notebook")
+		}
+		if _, changed := updated.DeltaSince(notebook); !changed {
+			return nil
+		}
+
+		return putRevision(tx, id, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+func (r *BoltRepository) Fetch(id uuid.UUID) (*solvent.Notebook, error) {
+	var notebook *solvent.Notebook
+	err := r.db.View(func(tx *bolt.Tx) error {
+		var err error
+		notebook, err = getNotebook(tx, id)
+		return err
+	})
+
+	return notebook, err
+}
+
+// Compact drops the stable tombstones of the notebook with the given ID
+// within a single read-write transaction so no concurrent update gets
+// lost
+func (r *BoltRepository) Compact(id uuid.UUID, stable func(id uuid.UUID) bool) ([]uuid.UUID, error) {
+	var pruned []uuid.UUID
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		notebook, err := getNotebook(tx, id)
+		if err != nil {
+			return err
+		}
+
+		pruned = notebook.Compact(stable)
+		if len(pruned) <= 0 {
+			return nil
+		}
+
+		return putNotebook(tx, notebook)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pruned, nil
+}
+
+func (r *BoltRepository) Remove(id uuid.UUID) error {
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(notebooksBucket).Delete(idKey(id)); err != nil {
+			return err
+		}
+		if err := tx.Bucket(ownersBucket).Delete(idKey(id)); err != nil {
+			return err
+		}
+
+		if err := deletePrefix(tx.Bucket(membersBucket), notebookPrefix(id)); err != nil {
+			return err
+		}
+		if err := deletePrefix(tx.Bucket(revisionsBucket), notebookPrefix(id)); err != nil {
+			return err
+		}
+
+		stale := [][]byte{}
+		err := tx.Bucket(shareLinksBucket).ForEach(func(key, value []byte) error {
+			var link service.ShareLink
+			if err := json.Unmarshal(value, &link); err != nil {
+				return err
+			}
+			if link.NotebookID == id {
+				stale = append(stale, key)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range stale {
+			if err := tx.Bucket(shareLinksBucket).Delete(key); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return errcode.NewNotebookError(id, err, "could not remove notebook")
+}
+
+func (r *BoltRepository) StoreUser(user *service.User) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return errcode.NewUnknownError(err, "could not marshal user")
+	}
+
+	return r.db.Update(func(tx *bolt.Tx) error {
+		names := tx.Bucket(userNamesBucket)
+		if names.Get([]byte(user.Name)) != nil {
+			return errcode.NewConflictError("user", user.Name)
+		}
+
+		if err := names.Put([]byte(user.Name), idKey(user.ID)); err != nil {
+			return errcode.NewUnknownError(err, "could not store user")
+		}
+		err := tx.Bucket(usersBucket).Put(idKey(user.ID), data)
+		return errcode.NewUnknownError(err, "could not store user")
+	})
+}
+
+func (r *BoltRepository) FetchUser(id uuid.UUID) (*service.User, error) {
+	var user service.User
+	err := r.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(usersBucket).Get(idKey(id))
+		if data == nil {
+			return errcode.NewNotFoundError("user", id)
+		}
+
+		err := json.Unmarshal(data, &user)
+		return errcode.NewUnknownError(err, "could not unmarshal user")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *BoltRepository) FetchUserByName(name string) (*service.User, error) {
+	var id uuid.UUID
+	err := r.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(userNamesBucket).Get([]byte(name))
+		if data == nil {
+			return errcode.NewNotFoundError("user", uuid.Nil)
+		}
+
+		var err error
+		id, err = uuid.ParseBytes(data)
+		return errcode.NewUnknownError(err, "could not parse user ID")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.FetchUser(id)
+}
+
+func (r *BoltRepository) StoreSession(session *service.Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return errcode.NewUnknownError(err, "could not marshal session")
+	}
+
+	err = r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Put([]byte(session.TokenHash), data)
+	})
+
+	return errcode.NewUnknownError(err, "could not store session")
+}
+
+func (r *BoltRepository) FetchSession(tokenHash string) (*service.Session, error) {
+	var session service.Session
+	err := r.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(sessionsBucket).Get([]byte(tokenHash))
+		if data == nil {
+			return errcode.NewNotFoundError("session", uuid.Nil)
+		}
+
+		err := json.Unmarshal(data, &session)
+		return errcode.NewUnknownError(err, "could not unmarshal session")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (r *BoltRepository) RemoveSession(tokenHash string) error {
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Delete([]byte(tokenHash))
+	})
+
+	return errcode.NewUnknownError(err, "could not remove session")
+}
+
+func (r *BoltRepository) StoreOwner(notebookID, userID uuid.UUID) error {
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(ownersBucket).Put(idKey(notebookID), idKey(userID))
+	})
+
+	return errcode.NewNotebookError(notebookID, err, "could not store owner")
+}
+
+func (r *BoltRepository) FetchOwner(notebookID uuid.UUID) (uuid.UUID, error) {
+	owner := uuid.Nil
+	err := r.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(ownersBucket).Get(idKey(notebookID))
+		if data == nil {
+			return errcode.NewNotFoundError("notebook", notebookID)
+		}
+
+		var err error
+		owner, err = uuid.ParseBytes(data)
+		return errcode.NewNotebookError(notebookID, err, "could not parse owner")
+	})
+
+	return owner, err
+}
+
+func (r *BoltRepository) FetchOwnedNotebooks(userID uuid.UUID) ([]uuid.UUID, error) {
+	notebooks := []uuid.UUID{}
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(ownersBucket).ForEach(func(key, value []byte) error {
+			if !bytes.Equal(value, idKey(userID)) {
+				return nil
+			}
+
+			notebookID, err := uuid.ParseBytes(key)
+			if err != nil {
+				return errcode.NewUnknownError(err, "could not parse ID")
+			}
+			notebooks = append(notebooks, notebookID)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return notebooks, nil
+}
+
+func (r *BoltRepository) StoreMember(notebookID, userID uuid.UUID, role service.Role) error {
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(membersBucket).Put(memberKey(notebookID, userID), []byte(role))
+	})
+
+	return errcode.NewNotebookError(notebookID, err, "could not store member")
+}
+
+func (r *BoltRepository) RemoveMember(notebookID, userID uuid.UUID) error {
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(membersBucket).Delete(memberKey(notebookID, userID))
+	})
+
+	return errcode.NewNotebookError(notebookID, err, "could not remove member")
+}
+
+func (r *BoltRepository) FetchMembers(notebookID uuid.UUID) (map[uuid.UUID]service.Role, error) {
+	members := map[uuid.UUID]service.Role{}
+	err := r.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(membersBucket).Cursor()
+		prefix := notebookPrefix(notebookID)
+		for key, value := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, value = cursor.Next() {
+			_, userID, role, err := parseMember(key, value)
+			if err != nil {
+				return err
+			}
+			members[userID] = role
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func (r *BoltRepository) FetchSharedNotebooks(userID uuid.UUID) (map[uuid.UUID]service.Role, error) {
+	notebooks := map[uuid.UUID]service.Role{}
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(membersBucket).ForEach(func(key, value []byte) error {
+			notebookID, memberID, role, err := parseMember(key, value)
+			if err != nil {
+				return err
+			}
+			if memberID == userID {
+				notebooks[notebookID] = role
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return notebooks, nil
+}
+
+func (r *BoltRepository) StoreShareLink(link *service.ShareLink) error {
+	data, err := json.Marshal(link)
+	if err != nil {
+		return errcode.NewNotebookError(link.NotebookID, err, "could not marshal share link")
+	}
+
+	err = r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(shareLinksBucket).Put([]byte(link.TokenHash), data)
+	})
+
+	return errcode.NewNotebookError(link.NotebookID, err, "could not store share link")
+}
+
+func (r *BoltRepository) FetchShareLink(tokenHash string) (*service.ShareLink, error) {
+	var link service.ShareLink
+	err := r.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(shareLinksBucket).Get([]byte(tokenHash))
+		if data == nil {
+			return errcode.NewNotFoundError("share link", uuid.Nil)
+		}
+
+		err := json.Unmarshal(data, &link)
+		return errcode.NewUnknownError(err, "could not unmarshal share link")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &link, nil
+}
+
+func (r *BoltRepository) FetchShareLinks(notebookID uuid.UUID) ([]service.ShareLink, error) {
+	links := []service.ShareLink{}
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(shareLinksBucket).ForEach(func(key, value []byte) error {
+			var link service.ShareLink
+			if err := json.Unmarshal(value, &link); err != nil {
+				return errcode.NewUnknownError(err, "could not unmarshal share link")
+			}
+			if link.NotebookID == notebookID {
+				links = append(links, link)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+func (r *BoltRepository) RemoveShareLink(notebookID, id uuid.UUID) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(shareLinksBucket).Cursor()
+		for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+			var link service.ShareLink
+			if err := json.Unmarshal(value, &link); err != nil {
+				return errcode.NewUnknownError(err, "could not unmarshal share link")
+			}
+			if link.NotebookID == notebookID && link.ID == id {
+				err := cursor.Delete()
+				return errcode.NewNotebookError(notebookID, err, "could not remove share link")
+			}
+		}
+
+		return errcode.NewNotFoundError("share link", id)
+	})
+}
+
+func getNotebook(tx *bolt.Tx, id uuid.UUID) (*solvent.Notebook, error) {
+	data := tx.Bucket(notebooksBucket).Get(idKey(id))
+	if data == nil {
+		return nil, errcode.NewNotFoundError("notebook", id)
+	}
+
+	return notebookFromJson(id, data)
+}
+
+func putNotebook(tx *bolt.Tx, notebook *solvent.Notebook) error {
+	data, err := notebookToJson(notebook)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Bucket(notebooksBucket).Put(idKey(notebook.ID), data)
+	return errcode.NewNotebookError(notebook.ID, err, "could not store notebook")
+}
+
+// boltRevision is the value of a revision in the notebook_revisions
+// bucket. Its key consists of the notebook ID and the revision number
+type boltRevision struct {
+	Data      json.RawMessage
+	CreatedAt time.Time
+}
+
+// putRevision stores the JSON data of the notebook with the given ID as
+// its latest revision within the transaction that stores the notebook
+func putRevision(tx *bolt.Tx, notebookID uuid.UUID, data []byte) error {
+	value, err := json.Marshal(boltRevision{Data: data, CreatedAt: time.Now().UTC()})
+	if err != nil {
+		return errcode.NewNotebookError(notebookID, err, "could not marshal revision")
+	}
+
+	bucket := tx.Bucket(revisionsBucket)
+	sequence, err := bucket.NextSequence()
+	if err != nil {
+		return errcode.NewNotebookError(notebookID, err, "could not store revision")
+	}
+
+	err = bucket.Put(revisionKey(notebookID, int64(sequence)), value)
+	return errcode.NewNotebookError(notebookID, err, "could not store revision")
+}
+
+func (r *BoltRepository) FetchRevisions(notebookID uuid.UUID) ([]service.Revision, error) {
+	revisions := []service.Revision{}
+	err := r.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(revisionsBucket).Cursor()
+		prefix := notebookPrefix(notebookID)
+		for key, value := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, value = cursor.Next() {
+			var revision boltRevision
+			if err := json.Unmarshal(value, &revision); err != nil {
+				return err
+			}
+			revisions = append(revisions, service.Revision{
+				NotebookID: notebookID,
+				Number:     int64(binary.BigEndian.Uint64(key[len(prefix):])),
+				CreatedAt:  revision.CreatedAt,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errcode.NewNotebookError(notebookID, err, "could not fetch revisions")
+	}
+
+	return revisions, nil
+}
+
+func (r *BoltRepository) FetchRevision(notebookID uuid.UUID, number int64) (*service.Revision, error) {
+	var value []byte
+	err := r.db.View(func(tx *bolt.Tx) error {
+		if stored := tx.Bucket(revisionsBucket).Get(revisionKey(notebookID, number)); stored != nil {
+			value = append([]byte{}, stored...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errcode.NewNotebookError(notebookID, err, "could not fetch revision")
+	} else if value == nil {
+		return nil, errcode.NewNotFoundError("revision of notebook", notebookID)
+	}
+
+	var stored boltRevision
+	if err := json.Unmarshal(value, &stored); err != nil {
+		return nil, errcode.NewNotebookError(notebookID, err, "could not unmarshal revision")
+	}
+	notebook, err := notebookFromJson(notebookID, stored.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &service.Revision{
+		NotebookID: notebookID,
+		Number:     number,
+		Notebook:   notebook,
+		CreatedAt:  stored.CreatedAt,
+	}, nil
+}
+
+func idKey(id uuid.UUID) []byte {
+	return []byte(id.String())
+}
+
+// memberKey orders the members bucket by notebook, so the members of a
+// notebook can be found with a prefix scan
+func memberKey(notebookID, userID uuid.UUID) []byte {
+	return append(notebookPrefix(notebookID), idKey(userID)...)
+}
+
+// revisionKey orders the revisions bucket by notebook and number, so
+// the revisions of a notebook can be found with a prefix scan
+func revisionKey(notebookID uuid.UUID, number int64) []byte {
+	return binary.BigEndian.AppendUint64(notebookPrefix(notebookID), uint64(number))
+}
+
+// notebookPrefix is the prefix of the keys of all the entries of a
+// notebook in the buckets that are ordered by notebook
+func notebookPrefix(notebookID uuid.UUID) []byte {
+	return append(idKey(notebookID), '/')
+}
+
+// deletePrefix deletes all keys of the bucket with the given prefix.
+// Deleting while iterating skips keys, so the keys are collected first
+func deletePrefix(bucket *bolt.Bucket, prefix []byte) error {
+	stale := [][]byte{}
+	cursor := bucket.Cursor()
+	for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+		stale = append(stale, key)
+	}
+	for _, key := range stale {
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseMember(key, value []byte) (uuid.UUID, uuid.UUID, service.Role, error) {
+	ids := bytes.SplitN(key, []byte("/"), 2)
+	if len(ids) != 2 {
+		err := fmt.Errorf("invalid member key %q", key)
+		return uuid.Nil, uuid.Nil, "", errcode.NewUnknownError(err, "could not parse member")
+	}
+
+	notebookID, err := uuid.ParseBytes(ids[0])
+	if err != nil {
+		return uuid.Nil, uuid.Nil, "", errcode.NewUnknownError(err, "could not parse ID")
+	}
+	userID, err := uuid.ParseBytes(ids[1])
+	if err != nil {
+		return uuid.Nil, uuid.Nil, "", errcode.NewUnknownError(err, "could not parse ID")
+	}
+	role, err := service.ParseRole(string(value))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, "", errcode.NewUnknownError(err, "could not parse role")
+	}
+
+	return notebookID, userID, role, nil
+}
@@ -0,0 +1,182 @@
+package persistence
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/eldelto/solvent"
+	"github.com/eldelto/solvent/service/errcode"
+	"github.com/google/uuid"
+)
+
+// NotebookEventKind describes how a NotebookEvent changes the state of
+// a notebook
+type NotebookEventKind string
+
+const (
+	// EventCreated holds the whole initial state of the notebook
+	EventCreated NotebookEventKind = "created"
+	// EventMerged holds a delta that is merged into the notebook
+	EventMerged NotebookEventKind = "merged"
+	// EventReplaced holds the whole new state of the notebook for
+	// changes that can not be expressed as merge, e.g. dropped tombstones
+	EventReplaced NotebookEventKind = "replaced"
+	// EventCompacted holds the IDs of the tombstones that have been
+	// dropped from the notebook
+	EventCompacted NotebookEventKind = "compacted"
+)
+
+// NotebookEvent is a single entry of the append-only log of a notebook.
+// The sequences of the events of a notebook start at 1 and have no gaps
+type NotebookEvent struct {
+	NotebookID uuid.UUID
+	Sequence   int64
+	Kind       NotebookEventKind
+	Data       []byte
+	// UserID is the ID of the user that has made the change or uuid.Nil
+	// if it has not been made on behalf of any user, e.g. by the garbage
+	// collection
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+// newCreatedEvent returns the first event of the given notebook
+func newCreatedEvent(notebook *solvent.Notebook) (*NotebookEvent, error) {
+	data, err := notebookToJson(notebook)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NotebookEvent{
+		NotebookID: notebook.ID,
+		Sequence:   1,
+		Kind:       EventCreated,
+		Data:       data,
+		CreatedAt:  time.Now().UTC(),
+	}, nil
+}
+
+// newChangeEvent returns the event that turns the old into the new state
+// of a notebook and reports whether there have been any changes at all.
+// Changes are logged as delta whenever merging the delta into the old
+// state results in the new one and as the whole new state otherwise
+func newChangeEvent(sequence int64, old, new *solvent.Notebook) (*NotebookEvent, bool, error) {
+	oldData, err := notebookToJson(old)
+	if err != nil {
+		return nil, false, err
+	}
+	newData, err := notebookToJson(new)
+	if err != nil {
+		return nil, false, err
+	}
+	if bytes.Equal(oldData, newData) {
+		return nil, false, nil
+	}
+
+	event := NotebookEvent{
+		NotebookID: new.ID,
+		Sequence:   sequence,
+		Kind:       EventReplaced,
+		Data:       newData,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	delta, _ := new.DeltaSince(old)
+	merged, err := old.Merge(delta)
+	if err != nil {
+		return nil, false, errcode.NewNotebookError(new.ID, err, "could not merge delta")
+	}
+	mergedData, err := notebookToJson(merged.(*solvent.Notebook))
+	if err != nil {
+		return nil, false, err
+	}
+	if bytes.Equal(mergedData, newData) {
+		deltaData, err := notebookToJson(delta)
+		if err != nil {
+			return nil, false, err
+		}
+		event.Kind = EventMerged
+		event.Data = deltaData
+	}
+
+	return &event, true, nil
+}
+
+// newCompactedEvent returns the event that drops the given tombstones
+func newCompactedEvent(notebookID uuid.UUID, sequence int64, pruned []uuid.UUID) (*NotebookEvent, error) {
+	data, err := json.Marshal(pruned)
+	if err != nil {
+		return nil, errcode.NewNotebookError(notebookID, err, "could not marshal")
+	}
+
+	return &NotebookEvent{
+		NotebookID: notebookID,
+		Sequence:   sequence,
+		Kind:       EventCompacted,
+		Data:       data,
+		CreatedAt:  time.Now().UTC(),
+	}, nil
+}
+
+// applyEvent returns the state of the notebook after the given event.
+// The notebook is nil before the first event
+func applyEvent(notebook *solvent.Notebook, event *NotebookEvent) (*solvent.Notebook, error) {
+	id := event.NotebookID
+	switch event.Kind {
+	case EventCreated, EventReplaced:
+		return notebookFromJson(id, event.Data)
+	}
+
+	if notebook == nil {
+		err := fmt.Errorf("%s event %d precedes the creation", event.Kind, event.Sequence)
+		return nil, errcode.NewNotebookError(id, err, "invalid event log")
+	}
+
+	switch event.Kind {
+	case EventMerged:
+		delta, err := notebookFromJson(id, event.Data)
+		if err != nil {
+			return nil, err
+		}
+		merged, err := notebook.Merge(delta)
+		if err != nil {
+			return nil, errcode.NewNotebookError(id, err, "could not merge delta")
+		}
+
+		return merged.(*solvent.Notebook), nil
+	case EventCompacted:
+		var pruned []uuid.UUID
+		if err := json.Unmarshal(event.Data, &pruned); err != nil {
+			return nil, errcode.NewNotebookError(id, err, "could not unmarshal")
+		}
+		stable := make(map[uuid.UUID]struct{}, len(pruned))
+		for _, id := range pruned {
+			stable[id] = struct{}{}
+		}
+		notebook.Compact(func(id uuid.UUID) bool {
+			_, ok := stable[id]
+			return ok
+		})
+
+		return notebook, nil
+	default:
+		err := fmt.Errorf("unknown kind %q of event %d", event.Kind, event.Sequence)
+		return nil, errcode.NewNotebookError(id, err, "invalid event log")
+	}
+}
+
+// foldEvents applies the given events in order to the notebook, which
+// is nil if the events start with the creation of the notebook
+func foldEvents(notebook *solvent.Notebook, events []NotebookEvent) (*solvent.Notebook, error) {
+	for i := range events {
+		var err error
+		notebook, err = applyEvent(notebook, &events[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return notebook, nil
+}
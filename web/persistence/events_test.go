@@ -0,0 +1,72 @@
+package persistence
+
+import (
+	"testing"
+
+	"github.com/eldelto/solvent"
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/eldelto/solvent/web/dto"
+	"github.com/google/uuid"
+)
+
+func TestFoldEventsRebuildsNotebook(t *testing.T) {
+	for name, options := range map[string][]solvent.NotebookOption{
+		"2P-Sets": nil,
+		"OR-Sets": {solvent.WithORSets()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			notebook := newTestNotebook(t, options...)
+			created, err := newCreatedEvent(notebook)
+			AssertEquals(t, nil, err, "newCreatedEvent error")
+			events := []NotebookEvent{*created}
+
+			updated, _ := notebook.Copy()
+			list := updated.GetLists()[0]
+			list.CheckItem(list.GetItems()[1].ID)
+			updated.AddList("list2")
+			changed, ok, err := newChangeEvent(2, notebook, updated)
+			AssertEquals(t, nil, err, "newChangeEvent error")
+			AssertEquals(t, true, ok, "notebook changed")
+			AssertEquals(t, EventMerged, changed.Kind, "event kind")
+			events = append(events, *changed)
+
+			pruned := updated.Compact(func(id uuid.UUID) bool { return true })
+			compacted, err := newCompactedEvent(updated.ID, 3, pruned)
+			AssertEquals(t, nil, err, "newCompactedEvent error")
+			events = append(events, *compacted)
+
+			folded, err := foldEvents(nil, events)
+			AssertEquals(t, nil, err, "foldEvents error")
+			AssertEquals(t, marshal(t, dto.NotebookToDto(updated)), marshal(t, dto.NotebookToDto(folded)), "folded DTO")
+		})
+	}
+}
+
+func TestNewChangeEvent(t *testing.T) {
+	notebook := newTestNotebook(t)
+
+	_, ok, err := newChangeEvent(2, notebook, notebook)
+	AssertEquals(t, nil, err, "newChangeEvent error")
+	AssertEquals(t, false, ok, "unchanged notebook changed")
+
+	// Dropped tombstones can not be merged, so the whole state is logged
+	compacted, _ := notebook.Copy()
+	compacted.Compact(func(id uuid.UUID) bool { return true })
+	event, ok, err := newChangeEvent(2, notebook, compacted)
+	AssertEquals(t, nil, err, "newChangeEvent error")
+	AssertEquals(t, true, ok, "compacted notebook changed")
+	AssertEquals(t, EventReplaced, event.Kind, "event kind")
+
+	folded, err := foldEvents(notebook, []NotebookEvent{*event})
+	AssertEquals(t, nil, err, "foldEvents error")
+	AssertEquals(t, marshal(t, dto.NotebookToDto(compacted)), marshal(t, dto.NotebookToDto(folded)), "folded DTO")
+}
+
+func TestFoldEventsRejectsInvalidLog(t *testing.T) {
+	notebook := newTestNotebook(t)
+	event, err := newCompactedEvent(notebook.ID, 1, []uuid.UUID{})
+	AssertEquals(t, nil, err, "newCompactedEvent error")
+
+	_, err = foldEvents(nil, []NotebookEvent{*event})
+	AssertNotEquals(t, nil, err, "foldEvents error")
+}
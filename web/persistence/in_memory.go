@@ -2,8 +2,10 @@ package persistence
 
 import (
 	"sync"
+	"time"
 
 	"github.com/eldelto/solvent"
+	"github.com/eldelto/solvent/service"
 	"github.com/eldelto/solvent/service/errcode"
 	"github.com/google/uuid"
 )
@@ -11,14 +13,30 @@ import (
 type NotebookStore map[uuid.UUID]solvent.Notebook
 
 type InMemoryRepository struct {
-	store NotebookStore
-	mutex sync.Mutex
+	store     NotebookStore
+	users     map[uuid.UUID]service.User
+	userNames map[string]uuid.UUID
+	sessions  map[string]service.Session
+	owners    map[uuid.UUID]uuid.UUID
+	members   map[uuid.UUID]map[uuid.UUID]service.Role
+	links     map[string]service.ShareLink
+	revisions map[uuid.UUID][]service.Revision
+	// lastRevision is the number of the latest revision of any notebook
+	lastRevision int64
+	mutex        sync.Mutex
 }
 
 func NewInMemoryRepository() *InMemoryRepository {
 	return &InMemoryRepository{
-		store: NotebookStore{},
-		mutex: sync.Mutex{},
+		store:     NotebookStore{},
+		users:     map[uuid.UUID]service.User{},
+		userNames: map[string]uuid.UUID{},
+		sessions:  map[string]service.Session{},
+		owners:    map[uuid.UUID]uuid.UUID{},
+		members:   map[uuid.UUID]map[uuid.UUID]service.Role{},
+		links:     map[string]service.ShareLink{},
+		revisions: map[uuid.UUID][]service.Revision{},
+		mutex:     sync.Mutex{},
 	}
 }
 
@@ -26,7 +44,16 @@ func (r *InMemoryRepository) Store(notebook *solvent.Notebook) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	r.store[notebook.ID] = *notebook
+	copied, err := copyNotebook(notebook)
+	if err != nil {
+		return err
+	}
+
+	if err := r.appendRevision(notebook); err != nil {
+		return err
+	}
+	r.store[notebook.ID] = *copied
+
 	return nil
 }
 
@@ -38,11 +65,53 @@ func (r *InMemoryRepository) Update(notebook *solvent.Notebook) error {
 	if !ok {
 		return errcode.NewNotFoundError("notebook", notebook.ID)
 	}
-	r.store[notebook.ID] = *notebook
+
+	copied, err := copyNotebook(notebook)
+	if err != nil {
+		return err
+	}
+	r.store[notebook.ID] = *copied
 
 	return nil
 }
 
+// Modify applies the update function to a copy of the stored notebook
+// while holding the lock of the repository and stores a copy of the
+// result. It is recorded as new revision if its lists or items have
+// changed
+func (r *InMemoryRepository) Modify(id uuid.UUID, update service.UpdateFunc) (*solvent.Notebook, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	notebook, ok := r.store[id]
+	if !ok {
+		return nil, errcode.NewNotFoundError("notebook", id)
+	}
+
+	stored, err := copyNotebook(&notebook)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := update(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	copied, err := copyNotebook(updated)
+	if err != nil {
+		return nil, err
+	}
+	if _, changed := updated.DeltaSince(stored); changed {
+		if err := r.appendRevision(updated); err != nil {
+			return nil, err
+		}
+	}
+	r.store[id] = *copied
+
+	return updated, nil
+}
+
 func (r *InMemoryRepository) Fetch(id uuid.UUID) (*solvent.Notebook, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -52,7 +121,22 @@ func (r *InMemoryRepository) Fetch(id uuid.UUID) (*solvent.Notebook, error) {
 		return nil, errcode.NewNotFoundError("notebook", notebook.ID)
 	}
 
-	return &notebook, nil
+	return copyNotebook(&notebook)
+}
+
+func (r *InMemoryRepository) Compact(id uuid.UUID, stable func(id uuid.UUID) bool) ([]uuid.UUID, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	notebook, ok := r.store[id]
+	if !ok {
+		return nil, errcode.NewNotFoundError("notebook", id)
+	}
+
+	pruned := notebook.Compact(stable)
+	r.store[id] = notebook
+
+	return pruned, nil
 }
 
 func (r *InMemoryRepository) Remove(id uuid.UUID) error {
@@ -60,6 +144,280 @@ func (r *InMemoryRepository) Remove(id uuid.UUID) error {
 	defer r.mutex.Unlock()
 
 	delete(r.store, id)
+	delete(r.owners, id)
+	delete(r.members, id)
+	delete(r.revisions, id)
+	for tokenHash, link := range r.links {
+		if link.NotebookID == id {
+			delete(r.links, tokenHash)
+		}
+	}
+
+	return nil
+}
+
+func (r *InMemoryRepository) StoreUser(user *service.User) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.userNames[user.Name]; ok {
+		return errcode.NewConflictError("user", user.Name)
+	}
+
+	r.users[user.ID] = *user
+	r.userNames[user.Name] = user.ID
+
+	return nil
+}
+
+func (r *InMemoryRepository) FetchUser(id uuid.UUID) (*service.User, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, errcode.NewNotFoundError("user", id)
+	}
+
+	return &user, nil
+}
+
+func (r *InMemoryRepository) FetchUserByName(name string) (*service.User, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	id, ok := r.userNames[name]
+	if !ok {
+		return nil, errcode.NewNotFoundError("user", uuid.Nil)
+	}
+	user := r.users[id]
+
+	return &user, nil
+}
+
+func (r *InMemoryRepository) StoreSession(session *service.Session) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.sessions[session.TokenHash] = *session
 
 	return nil
 }
+
+func (r *InMemoryRepository) FetchSession(tokenHash string) (*service.Session, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	session, ok := r.sessions[tokenHash]
+	if !ok {
+		return nil, errcode.NewNotFoundError("session", uuid.Nil)
+	}
+
+	return &session, nil
+}
+
+func (r *InMemoryRepository) RemoveSession(tokenHash string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.sessions, tokenHash)
+
+	return nil
+}
+
+func (r *InMemoryRepository) StoreOwner(notebookID, userID uuid.UUID) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.owners[notebookID] = userID
+
+	return nil
+}
+
+func (r *InMemoryRepository) FetchOwner(notebookID uuid.UUID) (uuid.UUID, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	owner, ok := r.owners[notebookID]
+	if !ok {
+		return uuid.Nil, errcode.NewNotFoundError("notebook", notebookID)
+	}
+
+	return owner, nil
+}
+
+func (r *InMemoryRepository) FetchOwnedNotebooks(userID uuid.UUID) ([]uuid.UUID, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	notebooks := []uuid.UUID{}
+	for notebookID, owner := range r.owners {
+		if owner == userID {
+			notebooks = append(notebooks, notebookID)
+		}
+	}
+
+	return notebooks, nil
+}
+
+// copyNotebook returns a deep copy of the given notebook, so callers
+// never share any mutable state with the stored notebooks
+func copyNotebook(notebook *solvent.Notebook) (*solvent.Notebook, error) {
+	copied, err := notebook.Copy()
+	if err != nil {
+		return nil, errcode.NewNotebookError(notebook.ID, err, "could not copy")
+	}
+
+	return copied, nil
+}
+
+func (r *InMemoryRepository) StoreMember(notebookID, userID uuid.UUID, role service.Role) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	members, ok := r.members[notebookID]
+	if !ok {
+		members = map[uuid.UUID]service.Role{}
+		r.members[notebookID] = members
+	}
+	members[userID] = role
+
+	return nil
+}
+
+func (r *InMemoryRepository) RemoveMember(notebookID, userID uuid.UUID) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.members[notebookID], userID)
+
+	return nil
+}
+
+func (r *InMemoryRepository) FetchMembers(notebookID uuid.UUID) (map[uuid.UUID]service.Role, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	members := map[uuid.UUID]service.Role{}
+	for userID, role := range r.members[notebookID] {
+		members[userID] = role
+	}
+
+	return members, nil
+}
+
+func (r *InMemoryRepository) FetchSharedNotebooks(userID uuid.UUID) (map[uuid.UUID]service.Role, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	notebooks := map[uuid.UUID]service.Role{}
+	for notebookID, members := range r.members {
+		if role, ok := members[userID]; ok {
+			notebooks[notebookID] = role
+		}
+	}
+
+	return notebooks, nil
+}
+
+func (r *InMemoryRepository) StoreShareLink(link *service.ShareLink) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.links[link.TokenHash] = *link
+
+	return nil
+}
+
+func (r *InMemoryRepository) FetchShareLink(tokenHash string) (*service.ShareLink, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	link, ok := r.links[tokenHash]
+	if !ok {
+		return nil, errcode.NewNotFoundError("share link", uuid.Nil)
+	}
+
+	return &link, nil
+}
+
+func (r *InMemoryRepository) FetchShareLinks(notebookID uuid.UUID) ([]service.ShareLink, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	links := []service.ShareLink{}
+	for _, link := range r.links {
+		if link.NotebookID == notebookID {
+			links = append(links, link)
+		}
+	}
+
+	return links, nil
+}
+
+func (r *InMemoryRepository) RemoveShareLink(notebookID, id uuid.UUID) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for tokenHash, link := range r.links {
+		if link.NotebookID == notebookID && link.ID == id {
+			delete(r.links, tokenHash)
+			return nil
+		}
+	}
+
+	return errcode.NewNotFoundError("share link", id)
+}
+
+// appendRevision records a copy of the notebook as its latest revision.
+// The caller has to hold the lock of the repository
+func (r *InMemoryRepository) appendRevision(notebook *solvent.Notebook) error {
+	copied, err := copyNotebook(notebook)
+	if err != nil {
+		return err
+	}
+
+	r.lastRevision++
+	r.revisions[copied.ID] = append(r.revisions[copied.ID], service.Revision{
+		NotebookID: copied.ID,
+		Number:     r.lastRevision,
+		Notebook:   copied,
+		CreatedAt:  time.Now().UTC(),
+	})
+
+	return nil
+}
+
+func (r *InMemoryRepository) FetchRevisions(notebookID uuid.UUID) ([]service.Revision, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	revisions := make([]service.Revision, 0, len(r.revisions[notebookID]))
+	for _, revision := range r.revisions[notebookID] {
+		revision.Notebook = nil
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, nil
+}
+
+func (r *InMemoryRepository) FetchRevision(notebookID uuid.UUID, number int64) (*service.Revision, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, revision := range r.revisions[notebookID] {
+		if revision.Number != number {
+			continue
+		}
+
+		copied, err := copyNotebook(revision.Notebook)
+		if err != nil {
+			return nil, err
+		}
+		revision.Notebook = copied
+
+		return &revision, nil
+	}
+
+	return nil, errcode.NewNotFoundError("revision of notebook", notebookID)
+}
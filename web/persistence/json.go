@@ -0,0 +1,30 @@
+package persistence
+
+import (
+	"encoding/json"
+
+	"github.com/eldelto/solvent"
+	"github.com/eldelto/solvent/service/errcode"
+	"github.com/eldelto/solvent/web/dto"
+	"github.com/google/uuid"
+)
+
+func notebookToJson(notebook *solvent.Notebook) ([]byte, error) {
+	dto := dto.NotebookToDto(notebook)
+	data, err := json.Marshal(dto)
+	if err != nil {
+		return nil, errcode.NewNotebookError(notebook.ID, err, "could not marshal")
+	}
+
+	return data, nil
+}
+
+func notebookFromJson(id uuid.UUID, data []byte) (*solvent.Notebook, error) {
+	var notebookDto dto.NotebookDto
+	err := json.Unmarshal(data, &notebookDto)
+	if err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not unmarshal")
+	}
+
+	return dto.NotebookFromDto(&notebookDto), nil
+}
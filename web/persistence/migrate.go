@@ -0,0 +1,192 @@
+package persistence
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eldelto/solvent/service/errcode"
+)
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrationFiles embed.FS
+
+// migrationLockID identifies the advisory lock that keeps concurrently
+// starting instances from applying the same migration twice
+const migrationLockID = 6512_7063_4563
+
+// Migration is a single step of the evolution of a schema. Migrations
+// are applied in the order of their versions and every version is only
+// applied once
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// loadMigrations reads the migrations of the given directory. Their
+// file names consist of the version and a name, e.g.
+// 0001_initial_schema.sql, and the versions have to start at 1 without
+// any gaps
+func loadMigrations(files fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, errcode.NewUnknownError(err, "could not read migrations")
+	}
+
+	migrations := []Migration{}
+	for _, entry := range entries {
+		version, name, err := parseMigrationName(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := fs.ReadFile(files, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, errcode.NewUnknownError(err, "could not read migration "+entry.Name())
+		}
+
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    name,
+			SQL:     string(data),
+		})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			err := fmt.Errorf("expected version %d but got %d", i+1, migration.Version)
+			return nil, errcode.NewUnknownError(err, "invalid migration "+migration.Name)
+		}
+	}
+
+	return migrations, nil
+}
+
+func parseMigrationName(fileName string) (int, string, error) {
+	prefix, name, ok := strings.Cut(strings.TrimSuffix(fileName, ".sql"), "_")
+	if !ok || !strings.HasSuffix(fileName, ".sql") {
+		err := fmt.Errorf("%q does not match <version>_<name>.sql", fileName)
+		return 0, "", errcode.NewUnknownError(err, "invalid migration file name")
+	}
+
+	version, err := strconv.Atoi(prefix)
+	if err != nil || version <= 0 {
+		err := fmt.Errorf("%q does not start with a positive version", fileName)
+		return 0, "", errcode.NewUnknownError(err, "invalid migration file name")
+	}
+
+	return version, name, nil
+}
+
+func postgresMigrations() ([]Migration, error) {
+	return loadMigrations(postgresMigrationFiles, "migrations/postgres")
+}
+
+// MigratePostgres applies all pending migrations to the Postgres
+// database the given DB is connected to and returns its schema version
+// afterwards. Every migration is applied in its own transaction
+func MigratePostgres(db *sql.DB) (int, error) {
+	migrations, err := postgresMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS schema_version(
+		version INTEGER PRIMARY KEY NOT NULL,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return 0, errcode.NewUnknownError(err, "could not create schema_version table")
+	}
+
+	for _, migration := range migrations {
+		if err := applyPostgresMigration(db, migration); err != nil {
+			return 0, err
+		}
+	}
+
+	return postgresSchemaVersion(db)
+}
+
+func applyPostgresMigration(db *sql.DB, migration Migration) error {
+	message := fmt.Sprintf("could not apply migration %d_%s", migration.Version, migration.Name)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return errcode.NewUnknownError(err, message)
+	}
+	defer tx.Rollback()
+
+	// Another instance may have applied the migration while waiting for
+	// the lock
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+		return errcode.NewUnknownError(err, message)
+	}
+	var applied bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM schema_version WHERE version = $1)", migration.Version).
+		Scan(&applied)
+	if err != nil {
+		return errcode.NewUnknownError(err, message)
+	} else if applied {
+		return nil
+	}
+
+	if _, err := tx.Exec(migration.SQL); err != nil {
+		return errcode.NewUnknownError(err, message)
+	}
+	_, err = tx.Exec("INSERT INTO schema_version(version, name) VALUES($1, $2)", migration.Version, migration.Name)
+	if err != nil {
+		return errcode.NewUnknownError(err, message)
+	}
+
+	return errcode.NewUnknownError(tx.Commit(), message)
+}
+
+// checkPostgresSchema returns an error if the Postgres database the
+// given DB is connected to has pending migrations
+func checkPostgresSchema(db *sql.DB) error {
+	migrations, err := postgresMigrations()
+	if err != nil {
+		return err
+	}
+
+	version, err := postgresSchemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	if latest := len(migrations); version < latest {
+		err := fmt.Errorf("schema version %d is behind %d", version, latest)
+		return errcode.NewUnknownError(err, "pending migrations, run the migrate command first")
+	}
+
+	return nil
+}
+
+// postgresSchemaVersion returns the version of the last applied
+// migration or 0 if none has been applied yet
+func postgresSchemaVersion(db *sql.DB) (int, error) {
+	var exists bool
+	err := db.QueryRow("SELECT to_regclass('schema_version') IS NOT NULL").Scan(&exists)
+	if err != nil {
+		return 0, errcode.NewUnknownError(err, "could not select schema version")
+	} else if !exists {
+		return 0, nil
+	}
+
+	var version int
+	err = db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	if err != nil {
+		return 0, errcode.NewUnknownError(err, "could not select schema version")
+	}
+
+	return version, nil
+}
@@ -0,0 +1,68 @@
+package persistence
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/eldelto/solvent/internal/conf"
+	. "github.com/eldelto/solvent/internal/testutils"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	files := fstest.MapFS{
+		"migrations/0002_second.sql": {Data: []byte("SELECT 2;")},
+		"migrations/0001_first.sql":  {Data: []byte("SELECT 1;")},
+	}
+
+	migrations, err := loadMigrations(files, "migrations")
+	AssertEquals(t, nil, err, "loadMigrations error")
+	AssertEquals(t, []Migration{
+		{Version: 1, Name: "first", SQL: "SELECT 1;"},
+		{Version: 2, Name: "second", SQL: "SELECT 2;"},
+	}, migrations, "migrations")
+
+	files["migrations/0004_gap.sql"] = &fstest.MapFile{Data: []byte("SELECT 4;")}
+	_, err = loadMigrations(files, "migrations")
+	AssertNotEquals(t, nil, err, "missing version error")
+
+	delete(files, "migrations/0004_gap.sql")
+	files["migrations/third.sql"] = &fstest.MapFile{Data: []byte("SELECT 3;")}
+	_, err = loadMigrations(files, "migrations")
+	AssertNotEquals(t, nil, err, "invalid name error")
+}
+
+func TestPostgresMigrationsAreValid(t *testing.T) {
+	migrations, err := postgresMigrations()
+	AssertEquals(t, nil, err, "postgresMigrations error")
+	AssertNotEquals(t, 0, len(migrations), "len(migrations)")
+}
+
+func TestMigratePostgres(t *testing.T) {
+	config := conf.NewChainConfigProvider([]conf.ConfigProvider{
+		conf.NewFileConfigProvider("../conf/sim.properties"),
+	})
+	db, err := OpenPostgres(
+		config.GetString("postgres.host"),
+		config.GetString("postgres.port"),
+		config.GetString("postgres.user"),
+		config.GetString("postgres.password"),
+	)
+	if err == nil {
+		err = db.Ping()
+	}
+	if err != nil {
+		t.Skipf("PostgreSQL is not available: %v", err)
+	}
+	defer db.Close()
+
+	migrations, _ := postgresMigrations()
+	version, err := MigratePostgres(db)
+	AssertEquals(t, nil, err, "MigratePostgres error")
+	AssertEquals(t, len(migrations), version, "schema version")
+
+	// Applied migrations are skipped
+	version, err = MigratePostgres(db)
+	AssertEquals(t, nil, err, "repeated MigratePostgres error")
+	AssertEquals(t, len(migrations), version, "schema version")
+	AssertEquals(t, nil, checkPostgresSchema(db), "checkPostgresSchema error")
+}
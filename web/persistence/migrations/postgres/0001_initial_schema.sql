@@ -0,0 +1,47 @@
+-- The schema as it was created before migrations existed. Everything is
+-- created only if it does not exist yet, so databases created back then
+-- can be migrated as well
+
+CREATE TABLE IF NOT EXISTS notebooks(
+	id VARCHAR(36) PRIMARY KEY NOT NULL,
+	data JSONB NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS users(
+	id VARCHAR(36) PRIMARY KEY NOT NULL,
+	name VARCHAR(64) UNIQUE NOT NULL,
+	password_hash BYTEA NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS sessions(
+	token_hash VARCHAR(64) PRIMARY KEY NOT NULL,
+	user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS notebook_owners(
+	notebook_id VARCHAR(36) PRIMARY KEY NOT NULL,
+	user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS notebook_owners_user_id ON notebook_owners(user_id);
+
+CREATE TABLE IF NOT EXISTS notebook_members(
+	notebook_id VARCHAR(36) NOT NULL,
+	user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	role VARCHAR(16) NOT NULL,
+	PRIMARY KEY (notebook_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS notebook_members_user_id ON notebook_members(user_id);
+
+CREATE TABLE IF NOT EXISTS share_links(
+	id VARCHAR(36) PRIMARY KEY NOT NULL,
+	token_hash VARCHAR(64) UNIQUE NOT NULL,
+	notebook_id VARCHAR(36) NOT NULL,
+	list_id VARCHAR(36),
+	expires_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS share_links_notebook_id ON share_links(notebook_id);
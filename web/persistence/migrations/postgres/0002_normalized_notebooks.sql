@@ -0,0 +1,59 @@
+-- The tables of the NormalizedPostgresRepository
+
+CREATE TABLE IF NOT EXISTS normalized_notebooks(
+	id VARCHAR(36) PRIMARY KEY NOT NULL,
+	created_at BIGINT NOT NULL,
+	lists_kind VARCHAR(16) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS normalized_lists(
+	notebook_id VARCHAR(36) NOT NULL REFERENCES normalized_notebooks(id) ON DELETE CASCADE,
+	id VARCHAR(36) NOT NULL,
+	tag VARCHAR(36) NOT NULL,
+	removed BOOLEAN NOT NULL,
+	title TEXT NOT NULL,
+	title_updated_at BIGINT NOT NULL,
+	title_counter BIGINT NOT NULL,
+	title_replica VARCHAR(36) NOT NULL,
+	order_value TEXT NOT NULL,
+	order_updated_at BIGINT NOT NULL,
+	order_counter BIGINT NOT NULL,
+	order_replica VARCHAR(36) NOT NULL,
+	created_at BIGINT NOT NULL,
+	items_kind VARCHAR(16) NOT NULL,
+	PRIMARY KEY (notebook_id, id, tag, removed)
+);
+
+CREATE TABLE IF NOT EXISTS normalized_items(
+	notebook_id VARCHAR(36) NOT NULL REFERENCES normalized_notebooks(id) ON DELETE CASCADE,
+	list_id VARCHAR(36) NOT NULL,
+	list_tag VARCHAR(36) NOT NULL,
+	list_removed BOOLEAN NOT NULL,
+	id VARCHAR(36) NOT NULL,
+	tag VARCHAR(36) NOT NULL,
+	removed BOOLEAN NOT NULL,
+	title TEXT NOT NULL,
+	title_updated_at BIGINT NOT NULL,
+	title_counter BIGINT NOT NULL,
+	title_replica VARCHAR(36) NOT NULL,
+	checked BOOLEAN NOT NULL,
+	checked_updated_at BIGINT NOT NULL,
+	checked_counter BIGINT NOT NULL,
+	checked_replica VARCHAR(36) NOT NULL,
+	order_value TEXT NOT NULL,
+	order_updated_at BIGINT NOT NULL,
+	order_counter BIGINT NOT NULL,
+	order_replica VARCHAR(36) NOT NULL,
+	PRIMARY KEY (notebook_id, list_id, list_tag, list_removed, id, tag, removed)
+);
+
+CREATE INDEX IF NOT EXISTS normalized_items_id ON normalized_items(id);
+
+CREATE TABLE IF NOT EXISTS normalized_tombstones(
+	notebook_id VARCHAR(36) NOT NULL REFERENCES normalized_notebooks(id) ON DELETE CASCADE,
+	list_id VARCHAR(36) NOT NULL,
+	list_tag VARCHAR(36) NOT NULL,
+	list_removed BOOLEAN NOT NULL,
+	tag VARCHAR(36) NOT NULL,
+	PRIMARY KEY (notebook_id, list_id, list_tag, list_removed, tag)
+);
@@ -0,0 +1,23 @@
+-- The tables of the EventSourcedPostgresRepository. The head of a
+-- notebook holds the sequence of its latest event and is locked while
+-- appending to the log
+
+CREATE TABLE IF NOT EXISTS notebook_event_heads(
+	notebook_id VARCHAR(36) PRIMARY KEY NOT NULL,
+	sequence BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS notebook_events(
+	notebook_id VARCHAR(36) NOT NULL REFERENCES notebook_event_heads(notebook_id) ON DELETE CASCADE,
+	sequence BIGINT NOT NULL,
+	kind VARCHAR(16) NOT NULL,
+	data JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY(notebook_id, sequence)
+);
+
+CREATE TABLE IF NOT EXISTS notebook_snapshots(
+	notebook_id VARCHAR(36) PRIMARY KEY NOT NULL REFERENCES notebook_event_heads(notebook_id) ON DELETE CASCADE,
+	sequence BIGINT NOT NULL,
+	data JSONB NOT NULL
+);
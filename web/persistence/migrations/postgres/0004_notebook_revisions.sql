@@ -0,0 +1,11 @@
+-- The history of the notebooks. Revision numbers are shared by all
+-- notebooks, so they increase but are not consecutive per notebook
+
+CREATE TABLE IF NOT EXISTS notebook_revisions(
+	number BIGSERIAL PRIMARY KEY NOT NULL,
+	notebook_id VARCHAR(36) NOT NULL,
+	data JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS notebook_revisions_notebook_id ON notebook_revisions(notebook_id);
@@ -0,0 +1,4 @@
+-- Records the user that has made the change of an event. Events that
+-- have been appended before, or not on behalf of any user, have none
+
+ALTER TABLE notebook_events ADD COLUMN IF NOT EXISTS user_id VARCHAR(36);
@@ -0,0 +1,268 @@
+package persistence
+
+import (
+	"sort"
+
+	"github.com/eldelto/solvent/web/dto"
+	"github.com/google/uuid"
+)
+
+// The normalized repositories store a notebook as rows of its lists,
+// items and tombstones instead of a single document. The rows are
+// derived from the DTO representation of the notebook, so a notebook
+// round-trips to exactly the same DTO
+
+// listKey identifies a single copy of a ToDoList. Lists of an OR-Set
+// are identified by their tag while lists of a 2P-Set have a uuid.Nil
+// tag and can have a live as well as a removed copy
+type listKey struct {
+	ID      uuid.UUID
+	Tag     uuid.UUID
+	Removed bool
+}
+
+type listRow struct {
+	Title      dto.TitleDto
+	OrderValue dto.OrderValueDto
+	CreatedAt  int64
+	ItemsKind  string
+}
+
+// itemKey identifies a single copy of a ToDoItem within a copy of its
+// ToDoList
+type itemKey struct {
+	List    listKey
+	ID      uuid.UUID
+	Tag     uuid.UUID
+	Removed bool
+}
+
+type itemRow struct {
+	Title      dto.TitleDto
+	Checked    dto.CheckedDto
+	OrderValue dto.OrderValueDto
+}
+
+// tombstoneKey identifies a removed tag of an OR-Set. The list key is
+// empty for the removed tags of the lists of the notebook itself
+type tombstoneKey struct {
+	List listKey
+	Tag  uuid.UUID
+}
+
+type notebookRows struct {
+	ID         uuid.UUID
+	CreatedAt  int64
+	ListsKind  string
+	Lists      map[listKey]listRow
+	Items      map[itemKey]itemRow
+	Tombstones map[tombstoneKey]struct{}
+}
+
+func newNotebookRows(id uuid.UUID, createdAt int64, listsKind string) *notebookRows {
+	return &notebookRows{
+		ID:         id,
+		CreatedAt:  createdAt,
+		ListsKind:  listsKind,
+		Lists:      map[listKey]listRow{},
+		Items:      map[itemKey]itemRow{},
+		Tombstones: map[tombstoneKey]struct{}{},
+	}
+}
+
+// notebookToRows splits the DTO of a notebook into its rows
+func notebookToRows(notebook dto.NotebookDto) *notebookRows {
+	lists := notebook.ToDoLists
+	rows := newNotebookRows(notebook.ID, notebook.CreatedAt, lists.Kind)
+
+	for _, list := range lists.LiveSet {
+		rows.addList(listKey{ID: list.ID}, list)
+	}
+	for _, list := range lists.TombstoneSet {
+		rows.addList(listKey{ID: list.ID, Removed: true}, list)
+	}
+	for _, entry := range lists.Entries {
+		rows.addList(listKey{ID: entry.List.ID, Tag: entry.Tag}, entry.List)
+	}
+	for _, tag := range lists.RemovedTags {
+		rows.Tombstones[tombstoneKey{Tag: tag}] = struct{}{}
+	}
+
+	return rows
+}
+
+func (r *notebookRows) addList(key listKey, list dto.ToDoListDto) {
+	items := list.ToDoItems
+	r.Lists[key] = listRow{
+		Title:      list.Title,
+		OrderValue: list.OrderValue,
+		CreatedAt:  list.CreatedAt,
+		ItemsKind:  items.Kind,
+	}
+
+	for _, item := range items.LiveSet {
+		r.Items[itemKey{List: key, ID: item.ID}] = itemToRow(item)
+	}
+	for _, item := range items.TombstoneSet {
+		r.Items[itemKey{List: key, ID: item.ID, Removed: true}] = itemToRow(item)
+	}
+	for _, entry := range items.Entries {
+		r.Items[itemKey{List: key, ID: entry.Item.ID, Tag: entry.Tag}] = itemToRow(entry.Item)
+	}
+	for _, tag := range items.RemovedTags {
+		r.Tombstones[tombstoneKey{List: key, Tag: tag}] = struct{}{}
+	}
+}
+
+func itemToRow(item dto.ToDoItemDto) itemRow {
+	return itemRow{
+		Title:      item.Title,
+		Checked:    item.Checked,
+		OrderValue: item.OrderValue,
+	}
+}
+
+// toDto joins the rows to the DTO of the notebook again. Rows without
+// a matching list are ignored
+func (r *notebookRows) toDto() dto.NotebookDto {
+	lists := map[listKey]*dto.ToDoListDto{}
+	for key, row := range r.Lists {
+		lists[key] = &dto.ToDoListDto{
+			ID:    key.ID,
+			Title: row.Title,
+			ToDoItems: dto.ToDoItemPSetDto{
+				Kind:         row.ItemsKind,
+				LiveSet:      []dto.ToDoItemDto{},
+				TombstoneSet: []dto.ToDoItemDto{},
+			},
+			OrderValue: row.OrderValue,
+			CreatedAt:  row.CreatedAt,
+		}
+	}
+
+	for key, row := range r.Items {
+		list, ok := lists[key.List]
+		if !ok {
+			continue
+		}
+
+		items := &list.ToDoItems
+		item := dto.ToDoItemDto{
+			ID:         key.ID,
+			Title:      row.Title,
+			Checked:    row.Checked,
+			OrderValue: row.OrderValue,
+		}
+		switch {
+		case key.Tag != uuid.Nil:
+			items.Entries = append(items.Entries, dto.TaggedToDoItemDto{Tag: key.Tag, Item: item})
+		case key.Removed:
+			items.TombstoneSet = append(items.TombstoneSet, item)
+		default:
+			items.LiveSet = append(items.LiveSet, item)
+		}
+	}
+
+	notebook := dto.NotebookDto{
+		ID: r.ID,
+		ToDoLists: dto.ToDoListPSetDto{
+			Kind:         r.ListsKind,
+			LiveSet:      []dto.ToDoListDto{},
+			TombstoneSet: []dto.ToDoListDto{},
+		},
+		CreatedAt: r.CreatedAt,
+	}
+	for key := range r.Tombstones {
+		if key.List == (listKey{}) {
+			notebook.ToDoLists.RemovedTags = append(notebook.ToDoLists.RemovedTags, key.Tag)
+		} else if list, ok := lists[key.List]; ok {
+			list.ToDoItems.RemovedTags = append(list.ToDoItems.RemovedTags, key.Tag)
+		}
+	}
+
+	for key, list := range lists {
+		sortItemSetDto(&list.ToDoItems)
+		switch {
+		case key.Tag != uuid.Nil:
+			notebook.ToDoLists.Entries = append(notebook.ToDoLists.Entries, dto.TaggedToDoListDto{Tag: key.Tag, List: *list})
+		case key.Removed:
+			notebook.ToDoLists.TombstoneSet = append(notebook.ToDoLists.TombstoneSet, *list)
+		default:
+			notebook.ToDoLists.LiveSet = append(notebook.ToDoLists.LiveSet, *list)
+		}
+	}
+	sortListSetDto(&notebook.ToDoLists)
+
+	return notebook
+}
+
+// The set DTOs are sorted the same way the dto package sorts them
+
+func sortItemSetDto(set *dto.ToDoItemPSetDto) {
+	sort.Slice(set.LiveSet, func(i, j int) bool { return dto.LessID(set.LiveSet[i].ID, set.LiveSet[j].ID) })
+	sort.Slice(set.TombstoneSet, func(i, j int) bool { return dto.LessID(set.TombstoneSet[i].ID, set.TombstoneSet[j].ID) })
+	sort.Slice(set.Entries, func(i, j int) bool { return dto.LessID(set.Entries[i].Tag, set.Entries[j].Tag) })
+	sort.Slice(set.RemovedTags, func(i, j int) bool { return dto.LessID(set.RemovedTags[i], set.RemovedTags[j]) })
+}
+
+func sortListSetDto(set *dto.ToDoListPSetDto) {
+	sort.Slice(set.LiveSet, func(i, j int) bool { return dto.LessID(set.LiveSet[i].ID, set.LiveSet[j].ID) })
+	sort.Slice(set.TombstoneSet, func(i, j int) bool { return dto.LessID(set.TombstoneSet[i].ID, set.TombstoneSet[j].ID) })
+	sort.Slice(set.Entries, func(i, j int) bool { return dto.LessID(set.Entries[i].Tag, set.Entries[j].Tag) })
+	sort.Slice(set.RemovedTags, func(i, j int) bool { return dto.LessID(set.RemovedTags[i], set.RemovedTags[j]) })
+}
+
+// rowChanges holds the rows that have to be written or deleted to turn
+// one state of a notebook into another
+type rowChanges struct {
+	Lists             map[listKey]listRow
+	RemovedLists      []listKey
+	Items             map[itemKey]itemRow
+	RemovedItems      []itemKey
+	Tombstones        []tombstoneKey
+	RemovedTombstones []tombstoneKey
+}
+
+// diffRows returns the changes from the old to the new rows. Rows that
+// did not change are left out, so merges only touch the changed rows
+func diffRows(old, new *notebookRows) rowChanges {
+	changes := rowChanges{
+		Lists: map[listKey]listRow{},
+		Items: map[itemKey]itemRow{},
+	}
+
+	for key, row := range new.Lists {
+		if oldRow, ok := old.Lists[key]; !ok || oldRow != row {
+			changes.Lists[key] = row
+		}
+	}
+	for key := range old.Lists {
+		if _, ok := new.Lists[key]; !ok {
+			changes.RemovedLists = append(changes.RemovedLists, key)
+		}
+	}
+
+	for key, row := range new.Items {
+		if oldRow, ok := old.Items[key]; !ok || oldRow != row {
+			changes.Items[key] = row
+		}
+	}
+	for key := range old.Items {
+		if _, ok := new.Items[key]; !ok {
+			changes.RemovedItems = append(changes.RemovedItems, key)
+		}
+	}
+
+	for key := range new.Tombstones {
+		if _, ok := old.Tombstones[key]; !ok {
+			changes.Tombstones = append(changes.Tombstones, key)
+		}
+	}
+	for key := range old.Tombstones {
+		if _, ok := new.Tombstones[key]; !ok {
+			changes.RemovedTombstones = append(changes.RemovedTombstones, key)
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,74 @@
+package persistence
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/eldelto/solvent"
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/eldelto/solvent/web/dto"
+)
+
+func TestNotebookRowsRoundTrip(t *testing.T) {
+	for name, options := range map[string][]solvent.NotebookOption{
+		"2P-Sets": nil,
+		"OR-Sets": {solvent.WithORSets()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			notebook := newTestNotebook(t, options...)
+			expected := dto.NotebookToDto(notebook)
+
+			actual := notebookToRows(expected).toDto()
+			AssertEquals(t, marshal(t, expected), marshal(t, actual), "round-tripped DTO")
+		})
+	}
+}
+
+func TestDiffRowsOnlyContainsChanges(t *testing.T) {
+	notebook := newTestNotebook(t, solvent.WithORSets())
+	old := notebookToRows(dto.NotebookToDto(notebook))
+
+	changes := diffRows(old, notebookToRows(dto.NotebookToDto(notebook)))
+	unchanged := rowChanges{Lists: map[listKey]listRow{}, Items: map[itemKey]itemRow{}}
+	AssertEquals(t, unchanged, changes, "unchanged notebook has no changes")
+
+	list := notebook.GetLists()[0]
+	itemID := list.GetItems()[0].ID
+	list.CheckItem(itemID)
+	changes = diffRows(old, notebookToRows(dto.NotebookToDto(notebook)))
+	AssertEquals(t, 0, len(changes.Lists), "len(changes.Lists)")
+	AssertEquals(t, 1, len(changes.Items), "len(changes.Items)")
+	AssertEquals(t, 0, len(changes.RemovedItems), "len(changes.RemovedItems)")
+
+	list.RemoveItem(itemID)
+	changes = diffRows(old, notebookToRows(dto.NotebookToDto(notebook)))
+	AssertEquals(t, 1, len(changes.RemovedItems), "len(changes.RemovedItems)")
+	AssertEquals(t, 1, len(changes.Tombstones), "len(changes.Tombstones)")
+}
+
+// newTestNotebook creates a notebook with live as well as removed lists
+// and items
+func newTestNotebook(t *testing.T, options ...solvent.NotebookOption) *solvent.Notebook {
+	notebook, err := solvent.NewNotebook(options...)
+	AssertEquals(t, nil, err, "solvent.NewNotebook error")
+
+	list0, _ := notebook.AddList("list0")
+	item0, _ := list0.AddItem("item0")
+	list0.AddItem("item1")
+	list0.CheckItem(item0)
+	removedItem, _ := list0.AddItem("removed")
+	list0.RemoveItem(removedItem)
+
+	list1, _ := notebook.AddList("list1")
+	list1.AddItem("item2")
+	notebook.RemoveList(list1.ID)
+
+	return notebook
+}
+
+func marshal(t *testing.T, value interface{}) string {
+	data, err := json.Marshal(value)
+	AssertEquals(t, nil, err, "json.Marshal error")
+
+	return string(data)
+}
@@ -2,108 +2,65 @@ package persistence
 
 import (
 	"database/sql"
-	"encoding/json"
 	"fmt"
 
-	"github.com/eldelto/solvent"
 	"github.com/eldelto/solvent/service/errcode"
-	"github.com/eldelto/solvent/web/dto"
-	"github.com/google/uuid"
 
 	// Import Postgres driver
 	_ "github.com/jackc/pgx/v4/stdlib"
 )
 
+// PostgresRepository stores notebooks as JSONB documents in a
+// PostgreSQL database, whose schema is versioned by migrations
 type PostgresRepository struct {
-	db *sql.DB
+	*sqlRepository
+	skipMigrations bool
 }
 
-func NewPostgresRepository(host, port, user, password string) (*PostgresRepository, error) {
-	connectURL := fmt.Sprintf("postgres://%s:%s@%s:%s/solvent", user, password, host, port)
-	db, err := sql.Open("pgx", connectURL)
-	if err != nil {
-		return nil, errcode.NewUnknownError(err, "could not connect to DB")
-	}
-
-	repo := PostgresRepository{db: db}
+// PostgresOption configures optional behaviour of a PostgresRepository
+type PostgresOption func(r *PostgresRepository)
 
-	_, err = repo.db.Exec(`CREATE TABLE IF NOT EXISTS notebooks(
-			id VARCHAR(36) PRIMARY KEY NOT NULL, 
-			data JSONB NOT NULL
-		)`)
-	if err != nil {
-		db.Close()
-		return nil, errcode.NewUnknownError(err, "could not initialize DB")
+// WithoutMigrations only checks that the schema is up to date instead
+// of applying the pending migrations, so they can be applied with the
+// migrate command before the new version is rolled out
+func WithoutMigrations() PostgresOption {
+	return func(r *PostgresRepository) {
+		r.skipMigrations = true
 	}
-
-	return &repo, nil
-}
-
-func (r *PostgresRepository) Close() {
-	r.db.Close()
 }
 
-func (r *PostgresRepository) Store(notebook *solvent.Notebook) error {
-	id := notebook.ID.String()
-	data, err := notebookToJson(notebook)
+func NewPostgresRepository(host, port, user, password string, options ...PostgresOption) (*PostgresRepository, error) {
+	db, err := OpenPostgres(host, port, user, password)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = r.db.Exec("INSERT INTO notebooks VALUES($1, $2)", id, data)
-
-	return errcode.NewNotebookError(notebook.ID, err, "could not execute insert")
-}
-
-func (r *PostgresRepository) Update(notebook *solvent.Notebook) error {
-	id := notebook.ID.String()
-	data, err := notebookToJson(notebook)
-	if err != nil {
-		return err
+	repo := PostgresRepository{sqlRepository: &sqlRepository{db: db, dialect: postgresDialect}}
+	for _, option := range options {
+		option(&repo)
 	}
 
-	result, err := r.db.Exec("UPDATE notebooks SET data = $2 WHERE id = $1", id, data)
-	if err != nil {
-		return errcode.NewNotebookError(notebook.ID, err, "could not execute update")
+	if repo.skipMigrations {
+		err = checkPostgresSchema(db)
+	} else {
+		_, err = MigratePostgres(db)
 	}
-
-	count, err := result.RowsAffected()
 	if err != nil {
-		return err
-	} else if count <= 0 {
-		return errcode.NewNotFoundError("notebook", notebook.ID)
-	}
-
-	return nil
-}
-
-func (r *PostgresRepository) Fetch(id uuid.UUID) (*solvent.Notebook, error) {
-	var data []byte
-	err := r.db.QueryRow("SELECT data FROM notebooks WHERE id = $1", id.String()).Scan(&data)
-	if err == sql.ErrNoRows {
-		return nil, errcode.NewNotFoundError("notebook", id)
-	} else if err != nil {
-		return nil, errcode.NewNotebookError(id, err, "could not execute select")
+		db.Close()
+		return nil, err
 	}
 
-	var notebookDto dto.NotebookDto
-	json.Unmarshal(data, &notebookDto)
-	notebook := dto.NotebookFromDto(&notebookDto)
-
-	return notebook, nil
-}
-
-func (r *PostgresRepository) Remove(id uuid.UUID) error {
-	_, err := r.db.Exec("DELETE FROM notebooks WHERE id = $1", id.String())
-	return errcode.NewNotebookError(id, err, "could not execute delete")
+	return &repo, nil
 }
 
-func notebookToJson(notebook *solvent.Notebook) ([]byte, error) {
-	dto := dto.NotebookToDto(notebook)
-	data, err := json.Marshal(dto)
+// OpenPostgres connects to the solvent database of the given Postgres
+// server
+func OpenPostgres(host, port, user, password string) (*sql.DB, error) {
+	connectURL := fmt.Sprintf("postgres://%s:%s@%s:%s/solvent", user, password, host, port)
+	db, err := sql.Open("pgx", connectURL)
 	if err != nil {
-		return nil, errcode.NewNotebookError(notebook.ID, err, "could not marshal")
+		return nil, errcode.NewUnknownError(err, "could not connect to DB")
 	}
 
-	return data, nil
+	return db, nil
 }
@@ -0,0 +1,353 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/eldelto/solvent"
+	"github.com/eldelto/solvent/service"
+	"github.com/eldelto/solvent/service/errcode"
+	"github.com/google/uuid"
+)
+
+// DefaultSnapshotInterval is the number of events after which a new
+// snapshot of a notebook is written
+const DefaultSnapshotInterval = 100
+
+// EventSourcedPostgresRepository stores every change of a notebook as
+// event in an append-only log instead of overwriting its state. The
+// current state is the fold of all events, starting from the latest
+// snapshot. Accounts are stored in the same tables as by the
+// PostgresRepository
+type EventSourcedPostgresRepository struct {
+	*PostgresRepository
+	snapshotInterval int64
+	// userID is the ID of the user the appended events are attributed
+	// to or uuid.Nil if they are not made on behalf of any user
+	userID uuid.UUID
+}
+
+// NewEventSourcedPostgresRepository connects to the given Postgres
+// server and writes a snapshot of a notebook every snapshotInterval
+// events. The tables are created by the same migrations as the ones of
+// the PostgresRepository
+func NewEventSourcedPostgresRepository(host, port, user, password string, snapshotInterval int, options ...PostgresOption) (*EventSourcedPostgresRepository, error) {
+	base, err := NewPostgresRepository(host, port, user, password, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if snapshotInterval <= 0 {
+		snapshotInterval = DefaultSnapshotInterval
+	}
+
+	return &EventSourcedPostgresRepository{
+		PostgresRepository: base,
+		snapshotInterval:   int64(snapshotInterval),
+	}, nil
+}
+
+// As returns a repository that shares the connection of this one but
+// attributes all the events it appends to the user with the given ID
+func (r *EventSourcedPostgresRepository) As(userID uuid.UUID) service.Repository {
+	scoped := *r
+	scoped.userID = userID
+
+	return &scoped
+}
+
+func (r *EventSourcedPostgresRepository) Store(notebook *solvent.Notebook) error {
+	event, err := newCreatedEvent(notebook)
+	if err != nil {
+		return err
+	}
+	event.UserID = r.userID
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return errcode.NewNotebookError(notebook.ID, err, "could not begin transaction")
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO notebook_event_heads VALUES($1, $2)", notebook.ID.String(), event.Sequence)
+	if err != nil {
+		return errcode.NewNotebookError(notebook.ID, err, "could not execute insert")
+	}
+	if err := insertEvent(tx, event); err != nil {
+		return err
+	}
+	if err := insertRevision(tx, notebook); err != nil {
+		return err
+	}
+
+	return errcode.NewNotebookError(notebook.ID, tx.Commit(), "could not commit transaction")
+}
+
+func (r *EventSourcedPostgresRepository) Update(notebook *solvent.Notebook) error {
+	_, err := r.Modify(notebook.ID, func(stored *solvent.Notebook) (*solvent.Notebook, error) {
+		return notebook, nil
+	})
+
+	return err
+}
+
+// Modify applies the update function to the stored notebook within a
+// single transaction that locks the head of the notebook and appends
+// the changes to its log
+func (r *EventSourcedPostgresRepository) Modify(id uuid.UUID, update service.UpdateFunc) (*solvent.Notebook, error) {
+	return r.appendChanges(id, func(stored *solvent.Notebook, sequence int64) (*solvent.Notebook, *NotebookEvent, error) {
+		copied, err := stored.Copy()
+		if err != nil {
+			return nil, nil, errcode.NewNotebookError(id, err, "could not copy notebook")
+		}
+
+		updated, err := update(copied)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		event, changed, err := newChangeEvent(sequence, stored, updated)
+		if err != nil || !changed {
+			return updated, nil, err
+		}
+
+		return updated, event, nil
+	})
+}
+
+func (r *EventSourcedPostgresRepository) Fetch(id uuid.UUID) (*solvent.Notebook, error) {
+	// A repeatable read sees the snapshot and the events at the same
+	// point in time
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not begin transaction")
+	}
+	defer tx.Rollback()
+
+	notebook, _, err := selectEventSourcedNotebook(tx, id)
+	return notebook, err
+}
+
+// Compact drops the stable tombstones of the notebook with the given ID
+// and logs their IDs, so replaying the log drops them as well
+func (r *EventSourcedPostgresRepository) Compact(id uuid.UUID, stable func(id uuid.UUID) bool) ([]uuid.UUID, error) {
+	var pruned []uuid.UUID
+	_, err := r.appendChanges(id, func(stored *solvent.Notebook, sequence int64) (*solvent.Notebook, *NotebookEvent, error) {
+		pruned = stored.Compact(stable)
+		if len(pruned) <= 0 {
+			return stored, nil, nil
+		}
+
+		event, err := newCompactedEvent(id, sequence, pruned)
+		return stored, event, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pruned, nil
+}
+
+func (r *EventSourcedPostgresRepository) Remove(id uuid.UUID) error {
+	// The events and the snapshot are deleted in cascade
+	return r.remove(id, "DELETE FROM notebook_event_heads WHERE notebook_id = $1")
+}
+
+// Events returns the whole log of the notebook with the given ID
+func (r *EventSourcedPostgresRepository) Events(id uuid.UUID) ([]NotebookEvent, error) {
+	events, err := selectEvents(r.db, id, 0, -1)
+	if err != nil {
+		return nil, err
+	} else if len(events) <= 0 {
+		return nil, errcode.NewNotFoundError("notebook", id)
+	}
+
+	return events, nil
+}
+
+// FetchAt returns the state of the notebook with the given ID right
+// after the event with the given sequence. The state is rebuilt from
+// the whole log, so it does not depend on any snapshot
+func (r *EventSourcedPostgresRepository) FetchAt(id uuid.UUID, sequence int64) (*solvent.Notebook, error) {
+	events, err := selectEvents(r.db, id, 0, sequence)
+	if err != nil {
+		return nil, err
+	}
+
+	notebook, err := foldEvents(nil, events)
+	if err != nil {
+		return nil, err
+	} else if notebook == nil {
+		return nil, errcode.NewNotFoundError("notebook", id)
+	}
+
+	return notebook, nil
+}
+
+// appendFunc returns the new state of the stored notebook together with
+// the event with the given sequence that leads to it or no event if
+// nothing has changed
+type appendFunc func(stored *solvent.Notebook, sequence int64) (*solvent.Notebook, *NotebookEvent, error)
+
+// appendChanges appends the event returned by the given function to the
+// log of the notebook within a single transaction that locks its head.
+// The new state is recorded as revision as well if its lists or items
+// have changed and a snapshot of it is written once enough events have
+// been appended since the last one
+func (r *EventSourcedPostgresRepository) appendChanges(id uuid.UUID, change appendFunc) (*solvent.Notebook, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not begin transaction")
+	}
+	defer tx.Rollback()
+
+	var sequence int64
+	err = tx.QueryRow("SELECT sequence FROM notebook_event_heads WHERE notebook_id = $1 FOR UPDATE", id.String()).
+		Scan(&sequence)
+	if err == sql.ErrNoRows {
+		return nil, errcode.NewNotFoundError("notebook", id)
+	} else if err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not execute select")
+	}
+
+	stored, snapshotSequence, err := selectEventSourcedNotebook(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, event, err := change(stored, sequence+1)
+	if err != nil {
+		return nil, err
+	} else if event == nil {
+		return updated, nil
+	}
+	event.UserID = r.userID
+
+	if err := insertEvent(tx, event); err != nil {
+		return nil, err
+	}
+	if err := insertChangedRevision(tx, stored, updated); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec("UPDATE notebook_event_heads SET sequence = $2 WHERE notebook_id = $1", id.String(), event.Sequence)
+	if err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not execute update")
+	}
+
+	if event.Sequence-snapshotSequence >= r.snapshotInterval {
+		if err := upsertSnapshot(tx, updated, event.Sequence); err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not commit transaction")
+	}
+
+	return updated, nil
+}
+
+// selectEventSourcedNotebook folds the events since the latest snapshot
+// of the notebook with the given ID into it and returns the result
+// together with the sequence of the snapshot
+func selectEventSourcedNotebook(tx *sql.Tx, id uuid.UUID) (*solvent.Notebook, int64, error) {
+	var notebook *solvent.Notebook
+	var sequence int64
+	var data []byte
+	err := tx.QueryRow("SELECT sequence, data FROM notebook_snapshots WHERE notebook_id = $1", id.String()).
+		Scan(&sequence, &data)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, 0, errcode.NewNotebookError(id, err, "could not select snapshot")
+	} else if err == nil {
+		notebook, err = notebookFromJson(id, data)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	events, err := selectEvents(tx, id, sequence, -1)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	notebook, err = foldEvents(notebook, events)
+	if err != nil {
+		return nil, 0, err
+	} else if notebook == nil {
+		return nil, 0, errcode.NewNotFoundError("notebook", id)
+	}
+
+	return notebook, sequence, nil
+}
+
+// queryer is implemented by sql.DB as well as sql.Tx
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// selectEvents selects the events of the notebook with the given ID
+// after the given sequence up to and including the given last one or
+// all of them if last is negative
+func selectEvents(db queryer, id uuid.UUID, after, last int64) ([]NotebookEvent, error) {
+	result, err := db.Query(`SELECT sequence, kind, data, user_id, created_at FROM notebook_events
+		WHERE notebook_id = $1 AND sequence > $2 AND ($3 < 0 OR sequence <= $3)
+		ORDER BY sequence`, id.String(), after, last)
+	if err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not select events")
+	}
+	defer result.Close()
+
+	events := []NotebookEvent{}
+	for result.Next() {
+		event := NotebookEvent{NotebookID: id}
+		var userID sql.NullString
+		err := result.Scan(&event.Sequence, &event.Kind, &event.Data, &userID, &event.CreatedAt)
+		if err != nil {
+			return nil, errcode.NewNotebookError(id, err, "could not scan event")
+		}
+		if userID.Valid {
+			event.UserID, err = uuid.Parse(userID.String)
+			if err != nil {
+				return nil, errcode.NewNotebookError(id, err, "could not parse user ID")
+			}
+		}
+		events = append(events, event)
+	}
+	if err := result.Err(); err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not select events")
+	}
+
+	return events, nil
+}
+
+func insertEvent(tx *sql.Tx, event *NotebookEvent) error {
+	// Events that have not been made on behalf of any user have no user
+	var userID *string
+	if event.UserID != uuid.Nil {
+		id := event.UserID.String()
+		userID = &id
+	}
+
+	_, err := tx.Exec(`INSERT INTO notebook_events(notebook_id, sequence, kind, data, created_at, user_id)
+		VALUES($1, $2, $3, $4, $5, $6)`,
+		event.NotebookID.String(), event.Sequence, string(event.Kind), event.Data, event.CreatedAt, userID)
+
+	return errcode.NewNotebookError(event.NotebookID, err, "could not insert event")
+}
+
+func upsertSnapshot(tx *sql.Tx, notebook *solvent.Notebook, sequence int64) error {
+	data, err := notebookToJson(notebook)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO notebook_snapshots VALUES($1, $2, $3)
+		ON CONFLICT (notebook_id) DO UPDATE SET sequence = excluded.sequence, data = excluded.data`,
+		notebook.ID.String(), sequence, data)
+
+	return errcode.NewNotebookError(notebook.ID, err, "could not write snapshot")
+}
@@ -0,0 +1,349 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/eldelto/solvent"
+	"github.com/eldelto/solvent/service"
+	"github.com/eldelto/solvent/service/errcode"
+	"github.com/eldelto/solvent/web/dto"
+	"github.com/google/uuid"
+)
+
+// NormalizedPostgresRepository stores notebooks in separate tables for
+// their lists, items and tombstones instead of a single JSONB document,
+// so updates only write the rows that changed and items can be indexed.
+// Accounts are stored in the same tables as by the PostgresRepository
+type NormalizedPostgresRepository struct {
+	*PostgresRepository
+}
+
+// NewNormalizedPostgresRepository connects to the given Postgres server.
+// The tables are created by the same migrations as the ones of the
+// PostgresRepository
+func NewNormalizedPostgresRepository(host, port, user, password string, options ...PostgresOption) (*NormalizedPostgresRepository, error) {
+	base, err := NewPostgresRepository(host, port, user, password, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NormalizedPostgresRepository{PostgresRepository: base}, nil
+}
+
+func (r *NormalizedPostgresRepository) Store(notebook *solvent.Notebook) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return errcode.NewNotebookError(notebook.ID, err, "could not begin transaction")
+	}
+	defer tx.Rollback()
+
+	rows := notebookToRows(dto.NotebookToDto(notebook))
+	_, err = tx.Exec("INSERT INTO normalized_notebooks VALUES($1, $2, $3)",
+		notebook.ID.String(), rows.CreatedAt, rows.ListsKind)
+	if err != nil {
+		return errcode.NewNotebookError(notebook.ID, err, "could not execute insert")
+	}
+
+	empty := newNotebookRows(notebook.ID, rows.CreatedAt, rows.ListsKind)
+	if err := writeRowChanges(tx, notebook.ID, diffRows(empty, rows)); err != nil {
+		return err
+	}
+	if err := insertRevision(tx, notebook); err != nil {
+		return err
+	}
+
+	return errcode.NewNotebookError(notebook.ID, tx.Commit(), "could not commit transaction")
+}
+
+func (r *NormalizedPostgresRepository) Update(notebook *solvent.Notebook) error {
+	_, err := r.Modify(notebook.ID, func(stored *solvent.Notebook) (*solvent.Notebook, error) {
+		return notebook, nil
+	})
+
+	return err
+}
+
+// Modify applies the update function to the stored notebook within a
+// single transaction that locks the notebook row and only writes the
+// rows that have changed. The result is recorded as new revision within
+// the same transaction if its lists or items have changed
+func (r *NormalizedPostgresRepository) Modify(id uuid.UUID, update service.UpdateFunc) (*solvent.Notebook, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not begin transaction")
+	}
+	defer tx.Rollback()
+
+	stored, err := selectNotebookRows(tx, id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	notebookDto := stored.toDto()
+	notebook := dto.NotebookFromDto(&notebookDto)
+	updated, err := update(notebook)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := notebookToRows(dto.NotebookToDto(updated))
+	if err := writeRowChanges(tx, id, diffRows(stored, rows)); err != nil {
+		return nil, err
+	}
+	if err := insertChangedRevision(tx, notebook, updated); err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not commit transaction")
+	}
+
+	return updated, nil
+}
+
+func (r *NormalizedPostgresRepository) Fetch(id uuid.UUID) (*solvent.Notebook, error) {
+	// A repeatable read sees the rows of all tables at the same point
+	// in time
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not begin transaction")
+	}
+	defer tx.Rollback()
+
+	rows, err := selectNotebookRows(tx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	notebookDto := rows.toDto()
+
+	return dto.NotebookFromDto(&notebookDto), nil
+}
+
+// Compact drops the stable tombstones of the notebook with the given ID
+// within a single transaction that locks the notebook row so no
+// concurrent update gets lost
+func (r *NormalizedPostgresRepository) Compact(id uuid.UUID, stable func(id uuid.UUID) bool) ([]uuid.UUID, error) {
+	var pruned []uuid.UUID
+	_, err := r.Modify(id, func(stored *solvent.Notebook) (*solvent.Notebook, error) {
+		pruned = stored.Compact(stable)
+		return stored, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pruned, nil
+}
+
+func (r *NormalizedPostgresRepository) Remove(id uuid.UUID) error {
+	// The rows of the lists, items and tombstones are deleted in cascade
+	return r.remove(id, "DELETE FROM normalized_notebooks WHERE id = $1")
+}
+
+// selectNotebookRows selects all the rows of the notebook with the
+// given ID and optionally locks its notebook row until the transaction
+// ends
+func selectNotebookRows(tx *sql.Tx, id uuid.UUID, forUpdate bool) (*notebookRows, error) {
+	query := "SELECT created_at, lists_kind FROM normalized_notebooks WHERE id = $1"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	var createdAt int64
+	var listsKind string
+	err := tx.QueryRow(query, id.String()).Scan(&createdAt, &listsKind)
+	if err == sql.ErrNoRows {
+		return nil, errcode.NewNotFoundError("notebook", id)
+	} else if err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not execute select")
+	}
+	rows := newNotebookRows(id, createdAt, listsKind)
+
+	if err := selectListRows(tx, rows); err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not select lists")
+	}
+	if err := selectItemRows(tx, rows); err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not select items")
+	}
+	if err := selectTombstoneRows(tx, rows); err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not select tombstones")
+	}
+
+	return rows, nil
+}
+
+func selectListRows(tx *sql.Tx, rows *notebookRows) error {
+	result, err := tx.Query(`SELECT id, tag, removed,
+		title, title_updated_at, title_counter, title_replica,
+		order_value, order_updated_at, order_counter, order_replica,
+		created_at, items_kind
+		FROM normalized_lists WHERE notebook_id = $1`, rows.ID.String())
+	if err != nil {
+		return err
+	}
+	defer result.Close()
+
+	for result.Next() {
+		var key listKey
+		var row listRow
+		var title, order timestampColumns
+		err := result.Scan(&key.ID, &key.Tag, &key.Removed,
+			&row.Title.Value, &title.UpdatedAt, &title.Counter, &title.Replica,
+			&row.OrderValue.Value, &order.UpdatedAt, &order.Counter, &order.Replica,
+			&row.CreatedAt, &row.ItemsKind)
+		if err != nil {
+			return err
+		}
+		row.Title.TimestampDto = title.toDto()
+		row.OrderValue.TimestampDto = order.toDto()
+		rows.Lists[key] = row
+	}
+
+	return result.Err()
+}
+
+func selectItemRows(tx *sql.Tx, rows *notebookRows) error {
+	result, err := tx.Query(`SELECT list_id, list_tag, list_removed, id, tag, removed,
+		title, title_updated_at, title_counter, title_replica,
+		checked, checked_updated_at, checked_counter, checked_replica,
+		order_value, order_updated_at, order_counter, order_replica
+		FROM normalized_items WHERE notebook_id = $1`, rows.ID.String())
+	if err != nil {
+		return err
+	}
+	defer result.Close()
+
+	for result.Next() {
+		var key itemKey
+		var row itemRow
+		var title, checked, order timestampColumns
+		err := result.Scan(&key.List.ID, &key.List.Tag, &key.List.Removed, &key.ID, &key.Tag, &key.Removed,
+			&row.Title.Value, &title.UpdatedAt, &title.Counter, &title.Replica,
+			&row.Checked.Value, &checked.UpdatedAt, &checked.Counter, &checked.Replica,
+			&row.OrderValue.Value, &order.UpdatedAt, &order.Counter, &order.Replica)
+		if err != nil {
+			return err
+		}
+		row.Title.TimestampDto = title.toDto()
+		row.Checked.TimestampDto = checked.toDto()
+		row.OrderValue.TimestampDto = order.toDto()
+		rows.Items[key] = row
+	}
+
+	return result.Err()
+}
+
+func selectTombstoneRows(tx *sql.Tx, rows *notebookRows) error {
+	result, err := tx.Query(`SELECT list_id, list_tag, list_removed, tag
+		FROM normalized_tombstones WHERE notebook_id = $1`, rows.ID.String())
+	if err != nil {
+		return err
+	}
+	defer result.Close()
+
+	for result.Next() {
+		var key tombstoneKey
+		err := result.Scan(&key.List.ID, &key.List.Tag, &key.List.Removed, &key.Tag)
+		if err != nil {
+			return err
+		}
+		rows.Tombstones[key] = struct{}{}
+	}
+
+	return result.Err()
+}
+
+// writeRowChanges upserts the changed rows and deletes the removed ones
+func writeRowChanges(tx *sql.Tx, id uuid.UUID, changes rowChanges) error {
+	notebookID := id.String()
+
+	for key, row := range changes.Lists {
+		_, err := tx.Exec(`INSERT INTO normalized_lists VALUES($1, $2, $3, $4,
+			$5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+			ON CONFLICT (notebook_id, id, tag, removed) DO UPDATE SET
+			title = $5, title_updated_at = $6, title_counter = $7, title_replica = $8,
+			order_value = $9, order_updated_at = $10, order_counter = $11, order_replica = $12,
+			created_at = $13, items_kind = $14`,
+			notebookID, key.ID.String(), key.Tag.String(), key.Removed,
+			row.Title.Value, row.Title.UpdatedAt, row.Title.Counter, row.Title.Replica.String(),
+			row.OrderValue.Value, row.OrderValue.UpdatedAt, row.OrderValue.Counter, row.OrderValue.Replica.String(),
+			row.CreatedAt, row.ItemsKind)
+		if err != nil {
+			return errcode.NewNotebookError(id, err, "could not upsert list")
+		}
+	}
+	for _, key := range changes.RemovedLists {
+		_, err := tx.Exec(`DELETE FROM normalized_lists
+			WHERE notebook_id = $1 AND id = $2 AND tag = $3 AND removed = $4`,
+			notebookID, key.ID.String(), key.Tag.String(), key.Removed)
+		if err != nil {
+			return errcode.NewNotebookError(id, err, "could not delete list")
+		}
+	}
+
+	for key, row := range changes.Items {
+		_, err := tx.Exec(`INSERT INTO normalized_items VALUES($1, $2, $3, $4, $5, $6, $7,
+			$8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+			ON CONFLICT (notebook_id, list_id, list_tag, list_removed, id, tag, removed) DO UPDATE SET
+			title = $8, title_updated_at = $9, title_counter = $10, title_replica = $11,
+			checked = $12, checked_updated_at = $13, checked_counter = $14, checked_replica = $15,
+			order_value = $16, order_updated_at = $17, order_counter = $18, order_replica = $19`,
+			notebookID, key.List.ID.String(), key.List.Tag.String(), key.List.Removed,
+			key.ID.String(), key.Tag.String(), key.Removed,
+			row.Title.Value, row.Title.UpdatedAt, row.Title.Counter, row.Title.Replica.String(),
+			row.Checked.Value, row.Checked.UpdatedAt, row.Checked.Counter, row.Checked.Replica.String(),
+			row.OrderValue.Value, row.OrderValue.UpdatedAt, row.OrderValue.Counter, row.OrderValue.Replica.String())
+		if err != nil {
+			return errcode.NewNotebookError(id, err, "could not upsert item")
+		}
+	}
+	for _, key := range changes.RemovedItems {
+		_, err := tx.Exec(`DELETE FROM normalized_items
+			WHERE notebook_id = $1 AND list_id = $2 AND list_tag = $3 AND list_removed = $4
+			AND id = $5 AND tag = $6 AND removed = $7`,
+			notebookID, key.List.ID.String(), key.List.Tag.String(), key.List.Removed,
+			key.ID.String(), key.Tag.String(), key.Removed)
+		if err != nil {
+			return errcode.NewNotebookError(id, err, "could not delete item")
+		}
+	}
+
+	for _, key := range changes.Tombstones {
+		_, err := tx.Exec(`INSERT INTO normalized_tombstones VALUES($1, $2, $3, $4, $5)
+			ON CONFLICT DO NOTHING`,
+			notebookID, key.List.ID.String(), key.List.Tag.String(), key.List.Removed, key.Tag.String())
+		if err != nil {
+			return errcode.NewNotebookError(id, err, "could not insert tombstone")
+		}
+	}
+	for _, key := range changes.RemovedTombstones {
+		_, err := tx.Exec(`DELETE FROM normalized_tombstones
+			WHERE notebook_id = $1 AND list_id = $2 AND list_tag = $3 AND list_removed = $4 AND tag = $5`,
+			notebookID, key.List.ID.String(), key.List.Tag.String(), key.List.Removed, key.Tag.String())
+		if err != nil {
+			return errcode.NewNotebookError(id, err, "could not delete tombstone")
+		}
+	}
+
+	return nil
+}
+
+// timestampColumns holds the columns a dto.TimestampDto is stored in
+type timestampColumns struct {
+	UpdatedAt int64
+	Counter   int64
+	Replica   uuid.UUID
+}
+
+func (c timestampColumns) toDto() dto.TimestampDto {
+	return dto.TimestampDto{
+		UpdatedAt: c.UpdatedAt,
+		Counter:   uint32(c.Counter),
+		Replica:   c.Replica,
+	}
+}
@@ -0,0 +1,436 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eldelto/solvent"
+	"github.com/eldelto/solvent/internal/conf"
+	. "github.com/eldelto/solvent/internal/testutils"
+	"github.com/eldelto/solvent/service"
+	"github.com/eldelto/solvent/service/errcode"
+	"github.com/eldelto/solvent/web/dto"
+	"github.com/google/uuid"
+)
+
+const concurrentClients = 50
+const updatesPerClient = 10
+
+func TestInMemoryRepositoryConcurrentUpdates(t *testing.T) {
+	testConcurrentUpdates(t, NewInMemoryRepository())
+}
+
+func TestPostgresRepositoryConcurrentUpdates(t *testing.T) {
+	repository := newTestPostgresRepository(t)
+	defer repository.Close()
+
+	testConcurrentUpdates(t, repository)
+}
+
+func TestNormalizedPostgresRepositoryConcurrentUpdates(t *testing.T) {
+	repository := newTestNormalizedPostgresRepository(t)
+	defer repository.Close()
+
+	testConcurrentUpdates(t, repository)
+}
+
+func TestEventSourcedPostgresRepositoryConcurrentUpdates(t *testing.T) {
+	repository := newTestEventSourcedPostgresRepository(t)
+	defer repository.Close()
+
+	testConcurrentUpdates(t, repository)
+}
+
+func TestSQLiteRepositoryConcurrentUpdates(t *testing.T) {
+	repository := newTestSQLiteRepository(t)
+	defer repository.Close()
+
+	testConcurrentUpdates(t, repository)
+}
+
+func TestBoltRepositoryConcurrentUpdates(t *testing.T) {
+	repository := newTestBoltRepository(t)
+	defer repository.Close()
+
+	testConcurrentUpdates(t, repository)
+}
+
+func TestInMemoryRepositoryAccounts(t *testing.T) {
+	testAccounts(t, NewInMemoryRepository())
+}
+
+func TestPostgresRepositoryAccounts(t *testing.T) {
+	repository := newTestPostgresRepository(t)
+	defer repository.Close()
+
+	testAccounts(t, repository)
+}
+
+func TestNormalizedPostgresRepositoryAccounts(t *testing.T) {
+	repository := newTestNormalizedPostgresRepository(t)
+	defer repository.Close()
+
+	testAccounts(t, repository)
+}
+
+func TestNormalizedPostgresRepositoryRoundTrip(t *testing.T) {
+	repository := newTestNormalizedPostgresRepository(t)
+	defer repository.Close()
+
+	notebook := newTestNotebook(t, solvent.WithORSets())
+	AssertEquals(t, nil, repository.Store(notebook), "repository.Store error")
+	defer repository.Remove(notebook.ID)
+
+	stored, err := repository.Fetch(notebook.ID)
+	AssertEquals(t, nil, err, "repository.Fetch error")
+	AssertEquals(t, marshal(t, dto.NotebookToDto(notebook)), marshal(t, dto.NotebookToDto(stored)), "stored DTO")
+}
+
+func TestEventSourcedPostgresRepositoryReplaysLog(t *testing.T) {
+	repository := newTestEventSourcedPostgresRepository(t)
+	defer repository.Close()
+
+	notebook := newTestNotebook(t)
+	AssertEquals(t, nil, repository.Store(notebook), "repository.Store error")
+	defer repository.Remove(notebook.ID)
+
+	// More events than the snapshot interval, made on behalf of a user
+	userID := uuid.New()
+	scoped := repository.As(userID)
+	for i := 0; i < 3; i++ {
+		_, err := scoped.Modify(notebook.ID, func(stored *solvent.Notebook) (*solvent.Notebook, error) {
+			_, err := stored.AddList("list")
+			return stored, err
+		})
+		AssertEquals(t, nil, err, "repository.Modify error")
+	}
+
+	events, err := repository.Events(notebook.ID)
+	AssertEquals(t, nil, err, "repository.Events error")
+	AssertEquals(t, 4, len(events), "len(events)")
+	AssertEquals(t, uuid.Nil, events[0].UserID, "events[0].UserID")
+	AssertEquals(t, userID, events[3].UserID, "events[3].UserID")
+
+	first, err := repository.FetchAt(notebook.ID, 1)
+	AssertEquals(t, nil, err, "repository.FetchAt error")
+	AssertEquals(t, marshal(t, dto.NotebookToDto(notebook)), marshal(t, dto.NotebookToDto(first)), "first DTO")
+
+	latest, err := repository.FetchAt(notebook.ID, events[len(events)-1].Sequence)
+	AssertEquals(t, nil, err, "repository.FetchAt error")
+	stored, err := repository.Fetch(notebook.ID)
+	AssertEquals(t, nil, err, "repository.Fetch error")
+	AssertEquals(t, marshal(t, dto.NotebookToDto(latest)), marshal(t, dto.NotebookToDto(stored)), "stored DTO")
+}
+
+func TestSQLiteRepositoryAccounts(t *testing.T) {
+	repository := newTestSQLiteRepository(t)
+	defer repository.Close()
+
+	testAccounts(t, repository)
+}
+
+func TestBoltRepositoryAccounts(t *testing.T) {
+	repository := newTestBoltRepository(t)
+	defer repository.Close()
+
+	testAccounts(t, repository)
+}
+
+func TestInMemoryRepositoryRevisions(t *testing.T) {
+	testRevisions(t, NewInMemoryRepository())
+}
+
+func TestPostgresRepositoryRevisions(t *testing.T) {
+	repository := newTestPostgresRepository(t)
+	defer repository.Close()
+
+	testRevisions(t, repository)
+}
+
+func TestNormalizedPostgresRepositoryRevisions(t *testing.T) {
+	repository := newTestNormalizedPostgresRepository(t)
+	defer repository.Close()
+
+	testRevisions(t, repository)
+}
+
+func TestEventSourcedPostgresRepositoryRevisions(t *testing.T) {
+	repository := newTestEventSourcedPostgresRepository(t)
+	defer repository.Close()
+
+	testRevisions(t, repository)
+}
+
+func TestSQLiteRepositoryRevisions(t *testing.T) {
+	repository := newTestSQLiteRepository(t)
+	defer repository.Close()
+
+	testRevisions(t, repository)
+}
+
+func TestBoltRepositoryRevisions(t *testing.T) {
+	repository := newTestBoltRepository(t)
+	defer repository.Close()
+
+	testRevisions(t, repository)
+}
+
+func TestBoltRepositorySurvivesReopening(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "solvent.bolt")
+	repository, err := NewBoltRepository(path)
+	AssertEquals(t, nil, err, "NewBoltRepository error")
+
+	s := service.NewService(repository)
+	notebook, _ := s.Create()
+	notebook.AddList("list0")
+	s.Update(notebook)
+	repository.Close()
+
+	repository, err = NewBoltRepository(path)
+	AssertEquals(t, nil, err, "NewBoltRepository error")
+	defer repository.Close()
+
+	stored, err := repository.Fetch(notebook.ID)
+	AssertEquals(t, nil, err, "repository.Fetch error")
+	AssertEquals(t, 1, len(stored.GetLists()), "len(stored.GetLists)")
+}
+
+func newTestPostgresRepository(t *testing.T) *PostgresRepository {
+	config := conf.NewChainConfigProvider([]conf.ConfigProvider{
+		conf.NewFileConfigProvider("../conf/sim.properties"),
+	})
+	repository, err := NewPostgresRepository(
+		config.GetString("postgres.host"),
+		config.GetString("postgres.port"),
+		config.GetString("postgres.user"),
+		config.GetString("postgres.password"),
+	)
+	if err != nil {
+		t.Skipf("PostgreSQL is not available: %v", err)
+	}
+
+	return repository
+}
+
+func newTestNormalizedPostgresRepository(t *testing.T) *NormalizedPostgresRepository {
+	config := conf.NewChainConfigProvider([]conf.ConfigProvider{
+		conf.NewFileConfigProvider("../conf/sim.properties"),
+	})
+	repository, err := NewNormalizedPostgresRepository(
+		config.GetString("postgres.host"),
+		config.GetString("postgres.port"),
+		config.GetString("postgres.user"),
+		config.GetString("postgres.password"),
+	)
+	if err != nil {
+		t.Skipf("PostgreSQL is not available: %v", err)
+	}
+
+	return repository
+}
+
+func newTestSQLiteRepository(t *testing.T) *SQLiteRepository {
+	repository, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "solvent.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository error: %v", err)
+	}
+
+	return repository
+}
+
+// testConcurrentUpdates lets many clients update the same notebook at
+// once, each based on the state it fetched before, and checks that the
+// changes of every client end up in the stored notebook
+func testConcurrentUpdates(t *testing.T, repository service.Repository) {
+	s := service.NewService(repository)
+	notebook, err := s.Create()
+	AssertEquals(t, nil, err, "service.Create error")
+	defer s.Remove(notebook.ID)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrentClients*updatesPerClient)
+	for client := 0; client < concurrentClients; client++ {
+		wg.Add(1)
+		go func(client int) {
+			defer wg.Done()
+			for update := 0; update < updatesPerClient; update++ {
+				errs <- addList(&s, notebook.ID, fmt.Sprintf("list-%d-%d", client, update))
+			}
+		}(client)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		AssertEquals(t, nil, err, "update error")
+	}
+
+	stored, err := s.Fetch(notebook.ID)
+	AssertEquals(t, nil, err, "service.Fetch error")
+	AssertEquals(t, concurrentClients*updatesPerClient, len(stored.GetLists()), "len(stored.GetLists)")
+}
+
+func addList(s *service.Service, id uuid.UUID, title string) error {
+	notebook, err := s.Fetch(id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := notebook.AddList(title); err != nil {
+		return err
+	}
+
+	_, err = s.Update(notebook)
+	return err
+}
+
+// testAccounts checks that users, sessions and the access control lists
+// of notebooks survive a round trip through the repository
+func testAccounts(t *testing.T, repository repository) {
+	accounts := service.NewAccounts(repository)
+	s := service.NewService(repository, service.WithAccounts(accounts))
+	name := uuid.NewString()
+	owner, err := accounts.Register(name, "password")
+	AssertEquals(t, nil, err, "accounts.Register error")
+
+	_, err = accounts.Register(name, "password")
+	var conflictError *errcode.ConflictError
+	AssertEquals(t, true, errors.As(err, &conflictError), "taken name is a conflict")
+
+	token, _, err := accounts.Login(name, "password")
+	AssertEquals(t, nil, err, "accounts.Login error")
+	authenticated, err := accounts.Authenticate(token)
+	AssertEquals(t, nil, err, "accounts.Authenticate error")
+	AssertEquals(t, owner.ID, authenticated.ID, "authenticated.ID")
+
+	notebook, err := s.As(owner).Create()
+	AssertEquals(t, nil, err, "service.Create error")
+	defer s.Remove(notebook.ID)
+
+	viewerName := uuid.NewString()
+	accounts.Register(viewerName, "password")
+	_, err = accounts.Share(owner, notebook.ID, viewerName, service.RoleViewer)
+	AssertEquals(t, nil, err, "accounts.Share error")
+	members, _ := accounts.Members(owner, notebook.ID)
+	AssertEquals(t, 2, len(members), "len(members)")
+
+	expiresAt := time.Now().Add(time.Hour)
+	linkToken, link, err := accounts.CreateShareLink(owner, notebook.ID, uuid.Nil, expiresAt)
+	AssertEquals(t, nil, err, "accounts.CreateShareLink error")
+	resolved, err := accounts.ResolveShareLink(linkToken)
+	AssertEquals(t, nil, err, "accounts.ResolveShareLink error")
+	AssertEquals(t, link.ID, resolved.ID, "resolved.ID")
+	AssertEquals(t, uuid.Nil, resolved.ListID, "resolved.ListID")
+	AssertEquals(t, true, expiresAt.Equal(resolved.ExpiresAt), "resolved.ExpiresAt")
+
+	_, err = repository.FetchUser(uuid.New())
+	var notFoundError *errcode.NotFoundError
+	AssertEquals(t, true, errors.As(err, &notFoundError), "unknown user is not found")
+
+	_, err = repository.Fetch(uuid.New())
+	AssertEquals(t, true, errors.As(err, &notFoundError), "unknown notebook is not found")
+
+	err = repository.Remove(notebook.ID)
+	AssertEquals(t, nil, err, "repository.Remove error")
+	_, err = repository.FetchOwner(notebook.ID)
+	AssertEquals(t, true, errors.As(err, &notFoundError), "owner of removed notebook is not found")
+	_, err = repository.FetchShareLink(link.TokenHash)
+	AssertEquals(t, true, errors.As(err, &notFoundError), "link of removed notebook is not found")
+}
+
+func newTestBoltRepository(t *testing.T) *BoltRepository {
+	repository, err := NewBoltRepository(filepath.Join(t.TempDir(), "solvent.bolt"))
+	if err != nil {
+		t.Fatalf("NewBoltRepository error: %v", err)
+	}
+
+	return repository
+}
+
+// testRevisions checks that storing and changing a notebook records its
+// revisions, which are kept apart from the ones of other notebooks and
+// are removed with the notebook
+func testRevisions(t *testing.T, repository revisionRepository) {
+	notebook := newTestNotebook(t)
+	other := newTestNotebook(t)
+	for _, n := range []*solvent.Notebook{notebook, other} {
+		AssertEquals(t, nil, repository.Store(n), "repository.Store error")
+		defer repository.Remove(n.ID)
+	}
+
+	unchanged := func(stored *solvent.Notebook) (*solvent.Notebook, error) {
+		return stored.Copy()
+	}
+	_, err := repository.Modify(notebook.ID, unchanged)
+	AssertEquals(t, nil, err, "repository.Modify error")
+
+	failed := func(stored *solvent.Notebook) (*solvent.Notebook, error) {
+		return nil, errcode.NewValidationError("failed update")
+	}
+	_, err = repository.Modify(notebook.ID, failed)
+	AssertNotEquals(t, nil, err, "repository.Modify error")
+
+	updated, err := repository.Modify(notebook.ID, func(stored *solvent.Notebook) (*solvent.Notebook, error) {
+		updated, err := stored.Copy()
+		if err != nil {
+			return nil, err
+		}
+		_, err = updated.AddList("list2")
+		return updated, err
+	})
+	AssertEquals(t, nil, err, "repository.Modify error")
+
+	revisions, err := repository.FetchRevisions(notebook.ID)
+	AssertEquals(t, nil, err, "repository.FetchRevisions error")
+	AssertEquals(t, 2, len(revisions), "len(revisions)")
+	first, second := revisions[0].Number, revisions[1].Number
+	AssertEquals(t, true, first < second, "revision numbers increase")
+	AssertEquals(t, false, revisions[0].CreatedAt.IsZero(), "revision CreatedAt")
+
+	revision, err := repository.FetchRevision(notebook.ID, first)
+	AssertEquals(t, nil, err, "repository.FetchRevision error")
+	AssertEquals(t, marshal(t, dto.NotebookToDto(notebook)), marshal(t, dto.NotebookToDto(revision.Notebook)), "first revision DTO")
+	revision, err = repository.FetchRevision(notebook.ID, second)
+	AssertEquals(t, nil, err, "repository.FetchRevision error")
+	AssertEquals(t, marshal(t, dto.NotebookToDto(updated)), marshal(t, dto.NotebookToDto(revision.Notebook)), "second revision DTO")
+
+	var notFoundError *errcode.NotFoundError
+	_, err = repository.FetchRevision(other.ID, second)
+	AssertEquals(t, true, errors.As(err, &notFoundError), "revision of another notebook")
+
+	AssertEquals(t, nil, repository.Remove(notebook.ID), "repository.Remove error")
+	revisions, _ = repository.FetchRevisions(notebook.ID)
+	AssertEquals(t, 0, len(revisions), "len(revisions) after removal")
+}
+
+type revisionRepository interface {
+	service.Repository
+	service.RevisionRepository
+}
+
+type repository interface {
+	service.Repository
+	service.AccountRepository
+}
+
+func newTestEventSourcedPostgresRepository(t *testing.T) *EventSourcedPostgresRepository {
+	config := conf.NewChainConfigProvider([]conf.ConfigProvider{
+		conf.NewFileConfigProvider("../conf/sim.properties"),
+	})
+	repository, err := NewEventSourcedPostgresRepository(
+		config.GetString("postgres.host"),
+		config.GetString("postgres.port"),
+		config.GetString("postgres.user"),
+		config.GetString("postgres.password"),
+		2,
+	)
+	if err != nil {
+		t.Skipf("PostgreSQL is not available: %v", err)
+	}
+
+	return repository
+}
@@ -0,0 +1,185 @@
+package persistence
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/eldelto/solvent"
+	"github.com/eldelto/solvent/service"
+	"github.com/eldelto/solvent/service/errcode"
+	"github.com/google/uuid"
+)
+
+// The helpers in this file are shared by the SQL based repositories
+
+func scanUser(row *sql.Row, id uuid.UUID) (*service.User, error) {
+	var userID string
+	var user service.User
+	err := row.Scan(&userID, &user.Name, &user.PasswordHash)
+	if err == sql.ErrNoRows {
+		return nil, errcode.NewNotFoundError("user", id)
+	} else if err != nil {
+		return nil, errcode.NewUnknownError(err, "could not select user")
+	}
+
+	user.ID, err = uuid.Parse(userID)
+	if err != nil {
+		return nil, errcode.NewUnknownError(err, "could not parse user ID")
+	}
+
+	return &user, nil
+}
+
+// scanRoles returns the Roles of rows consisting of an ID and a role
+func scanRoles(rows *sql.Rows) (map[uuid.UUID]service.Role, error) {
+	roles := map[uuid.UUID]service.Role{}
+	for rows.Next() {
+		var id, role string
+		if err := rows.Scan(&id, &role); err != nil {
+			return nil, errcode.NewUnknownError(err, "could not scan role")
+		}
+
+		parsedID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, errcode.NewUnknownError(err, "could not parse ID")
+		}
+		parsedRole, err := service.ParseRole(role)
+		if err != nil {
+			return nil, errcode.NewUnknownError(err, "could not parse role")
+		}
+		roles[parsedID] = parsedRole
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errcode.NewUnknownError(err, "could not select roles")
+	}
+
+	return roles, nil
+}
+
+func scanShareLinks(rows *sql.Rows) ([]service.ShareLink, error) {
+	links := []service.ShareLink{}
+	for rows.Next() {
+		var id, notebookID string
+		var listID sql.NullString
+		var expiresAt sql.NullTime
+		var link service.ShareLink
+		err := rows.Scan(&id, &link.TokenHash, &notebookID, &listID, &expiresAt, &link.CreatedAt)
+		if err != nil {
+			return nil, errcode.NewUnknownError(err, "could not scan share link")
+		}
+
+		if link.ID, err = uuid.Parse(id); err != nil {
+			return nil, errcode.NewUnknownError(err, "could not parse ID")
+		}
+		if link.NotebookID, err = uuid.Parse(notebookID); err != nil {
+			return nil, errcode.NewUnknownError(err, "could not parse ID")
+		}
+		if listID.Valid {
+			if link.ListID, err = uuid.Parse(listID.String); err != nil {
+				return nil, errcode.NewUnknownError(err, "could not parse ID")
+			}
+		}
+		if expiresAt.Valid {
+			link.ExpiresAt = expiresAt.Time
+		}
+		links = append(links, link)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errcode.NewUnknownError(err, "could not select share links")
+	}
+
+	return links, nil
+}
+
+func scanIDs(rows *sql.Rows) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, errcode.NewUnknownError(err, "could not scan ID")
+		}
+
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			return nil, errcode.NewUnknownError(err, "could not parse ID")
+		}
+		ids = append(ids, parsed)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errcode.NewUnknownError(err, "could not select IDs")
+	}
+
+	return ids, nil
+}
+
+// insertRevision stores the notebook as new revision in the
+// notebook_revisions table within the transaction that stores the
+// notebook itself
+func insertRevision(tx *sql.Tx, notebook *solvent.Notebook) error {
+	data, err := notebookToJson(notebook)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO notebook_revisions(notebook_id, data, created_at)
+		VALUES($1, $2, $3)`, notebook.ID.String(), data, time.Now().UTC())
+
+	return errcode.NewNotebookError(notebook.ID, err, "could not insert revision")
+}
+
+// insertChangedRevision stores the updated notebook as new revision
+// within the given transaction if its lists or items have changed
+// since the stored one
+func insertChangedRevision(tx *sql.Tx, stored, updated *solvent.Notebook) error {
+	if _, changed := updated.DeltaSince(stored); !changed {
+		return nil
+	}
+
+	return insertRevision(tx, updated)
+}
+
+func selectRevisions(db *sql.DB, notebookID uuid.UUID) ([]service.Revision, error) {
+	rows, err := db.Query(`SELECT number, created_at FROM notebook_revisions
+		WHERE notebook_id = $1 ORDER BY number`, notebookID.String())
+	if err != nil {
+		return nil, errcode.NewNotebookError(notebookID, err, "could not select revisions")
+	}
+	defer rows.Close()
+
+	revisions := []service.Revision{}
+	for rows.Next() {
+		revision := service.Revision{NotebookID: notebookID}
+		if err := rows.Scan(&revision.Number, &revision.CreatedAt); err != nil {
+			return nil, errcode.NewNotebookError(notebookID, err, "could not scan revision")
+		}
+		revisions = append(revisions, revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errcode.NewNotebookError(notebookID, err, "could not select revisions")
+	}
+
+	return revisions, nil
+}
+
+func selectRevision(db *sql.DB, notebookID uuid.UUID, number int64) (*service.Revision, error) {
+	revision := service.Revision{NotebookID: notebookID, Number: number}
+	var data []byte
+	err := db.QueryRow(`SELECT data, created_at FROM notebook_revisions
+		WHERE notebook_id = $1 AND number = $2`, notebookID.String(), number).Scan(&data, &revision.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, errcode.NewNotFoundError("revision of notebook", notebookID)
+	} else if err != nil {
+		return nil, errcode.NewNotebookError(notebookID, err, "could not select revision")
+	}
+
+	revision.Notebook, err = notebookFromJson(notebookID, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &revision, nil
+}
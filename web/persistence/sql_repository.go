@@ -0,0 +1,418 @@
+package persistence
+
+import (
+	"database/sql"
+
+	"github.com/eldelto/solvent"
+	"github.com/eldelto/solvent/service"
+	"github.com/eldelto/solvent/service/errcode"
+	"github.com/google/uuid"
+)
+
+// sqlDialect holds the parts of the statements that differ between the
+// SQL databases. SQLite and PostgreSQL understand the same placeholders
+// and upserts, so only locking the row of a notebook differs
+type sqlDialect struct {
+	// lockRow is appended to the SELECT of a notebook that is changed
+	// within the same transaction to lock its row until the end of it
+	lockRow string
+}
+
+var postgresDialect = sqlDialect{lockRow: " FOR UPDATE"}
+
+// sqliteDialect does not lock rows as SQLite only allows a single writer
+// at a time, which serializes the transactions anyway
+var sqliteDialect = sqlDialect{}
+
+// sqlRepository stores notebooks as JSON documents together with the
+// accounts and revisions in a SQL database. It is the common base of
+// the SQLiteRepository and the PostgreSQL repositories, which create
+// the tables and choose the sqlDialect
+type sqlRepository struct {
+	db      *sql.DB
+	dialect sqlDialect
+}
+
+func (r *sqlRepository) Close() {
+	r.db.Close()
+}
+
+func (r *sqlRepository) Store(notebook *solvent.Notebook) error {
+	id := notebook.ID.String()
+	data, err := notebookToJson(notebook)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return errcode.NewNotebookError(notebook.ID, err, "could not begin transaction")
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO notebooks VALUES($1, $2)", id, data)
+	if err != nil {
+		return errcode.NewNotebookError(notebook.ID, err, "could not execute insert")
+	}
+	if err := insertRevision(tx, notebook); err != nil {
+		return err
+	}
+
+	return errcode.NewNotebookError(notebook.ID, tx.Commit(), "could not commit transaction")
+}
+
+func (r *sqlRepository) Update(notebook *solvent.Notebook) error {
+	id := notebook.ID.String()
+	data, err := notebookToJson(notebook)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.Exec("UPDATE notebooks SET data = $2 WHERE id = $1", id, data)
+	if err != nil {
+		return errcode.NewNotebookError(notebook.ID, err, "could not execute update")
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	} else if count <= 0 {
+		return errcode.NewNotFoundError("notebook", notebook.ID)
+	}
+
+	return nil
+}
+
+// Modify applies the update function to the stored notebook within a
+// single transaction that locks the notebook, so concurrent updates of
+// the same notebook are serialized. The result is recorded as new
+// revision within the same transaction if it has changed
+func (r *sqlRepository) Modify(id uuid.UUID, update service.UpdateFunc) (*solvent.Notebook, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not begin transaction")
+	}
+	defer tx.Rollback()
+
+	notebook, err := r.selectNotebook(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := update(notebook)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := notebookToJson(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("UPDATE notebooks SET data = $2 WHERE id = $1", id.String(), data)
+	if err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not execute update")
+	}
+	if err := insertChangedRevision(tx, notebook, updated); err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not commit transaction")
+	}
+
+	return updated, nil
+}
+
+func (r *sqlRepository) Fetch(id uuid.UUID) (*solvent.Notebook, error) {
+	var data []byte
+	err := r.db.QueryRow("SELECT data FROM notebooks WHERE id = $1", id.String()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, errcode.NewNotFoundError("notebook", id)
+	} else if err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not execute select")
+	}
+
+	return notebookFromJson(id, data)
+}
+
+// Compact drops the stable tombstones of the notebook with the given ID
+// within a single transaction that locks the notebook so no concurrent
+// update gets lost
+func (r *sqlRepository) Compact(id uuid.UUID, stable func(id uuid.UUID) bool) ([]uuid.UUID, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not begin transaction")
+	}
+	defer tx.Rollback()
+
+	notebook, err := r.selectNotebook(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	pruned := notebook.Compact(stable)
+	if len(pruned) <= 0 {
+		return pruned, nil
+	}
+
+	data, err := notebookToJson(notebook)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("UPDATE notebooks SET data = $2 WHERE id = $1", id.String(), data)
+	if err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not execute update")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not commit transaction")
+	}
+
+	return pruned, nil
+}
+
+// Remove deletes the notebook together with its owner, members, share
+// links and revisions within a single transaction
+func (r *sqlRepository) Remove(id uuid.UUID) error {
+	return r.remove(id)
+}
+
+// remove runs the given DELETE statements for the notebook with the
+// given ID and the ones of the tables shared by all the SQL
+// repositories within a single transaction
+func (r *sqlRepository) remove(id uuid.UUID, statements ...string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return errcode.NewNotebookError(id, err, "could not begin transaction")
+	}
+	defer tx.Rollback()
+
+	statements = append(statements,
+		"DELETE FROM notebooks WHERE id = $1",
+		"DELETE FROM notebook_owners WHERE notebook_id = $1",
+		"DELETE FROM notebook_members WHERE notebook_id = $1",
+		"DELETE FROM share_links WHERE notebook_id = $1",
+		"DELETE FROM notebook_revisions WHERE notebook_id = $1",
+	)
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, id.String()); err != nil {
+			return errcode.NewNotebookError(id, err, "could not execute delete")
+		}
+	}
+
+	return errcode.NewNotebookError(id, tx.Commit(), "could not commit transaction")
+}
+
+func (r *sqlRepository) StoreUser(user *service.User) error {
+	result, err := r.db.Exec(`INSERT INTO users VALUES($1, $2, $3)
+		ON CONFLICT (name) DO NOTHING`, user.ID.String(), user.Name, user.PasswordHash)
+	if err != nil {
+		return errcode.NewUnknownError(err, "could not insert user")
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return errcode.NewUnknownError(err, "could not insert user")
+	} else if count <= 0 {
+		return errcode.NewConflictError("user", user.Name)
+	}
+
+	return nil
+}
+
+func (r *sqlRepository) FetchUser(id uuid.UUID) (*service.User, error) {
+	row := r.db.QueryRow("SELECT id, name, password_hash FROM users WHERE id = $1", id.String())
+	return scanUser(row, id)
+}
+
+func (r *sqlRepository) FetchUserByName(name string) (*service.User, error) {
+	row := r.db.QueryRow("SELECT id, name, password_hash FROM users WHERE name = $1", name)
+	return scanUser(row, uuid.Nil)
+}
+
+func (r *sqlRepository) StoreSession(session *service.Session) error {
+	_, err := r.db.Exec("INSERT INTO sessions VALUES($1, $2, $3)",
+		session.TokenHash, session.UserID.String(), session.ExpiresAt)
+
+	return errcode.NewUnknownError(err, "could not insert session")
+}
+
+func (r *sqlRepository) FetchSession(tokenHash string) (*service.Session, error) {
+	var userID string
+	session := service.Session{TokenHash: tokenHash}
+	err := r.db.QueryRow("SELECT user_id, expires_at FROM sessions WHERE token_hash = $1", tokenHash).
+		Scan(&userID, &session.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, errcode.NewNotFoundError("session", uuid.Nil)
+	} else if err != nil {
+		return nil, errcode.NewUnknownError(err, "could not select session")
+	}
+
+	session.UserID, err = uuid.Parse(userID)
+	if err != nil {
+		return nil, errcode.NewUnknownError(err, "could not parse user ID")
+	}
+
+	return &session, nil
+}
+
+func (r *sqlRepository) RemoveSession(tokenHash string) error {
+	_, err := r.db.Exec("DELETE FROM sessions WHERE token_hash = $1", tokenHash)
+	return errcode.NewUnknownError(err, "could not delete session")
+}
+
+func (r *sqlRepository) StoreOwner(notebookID, userID uuid.UUID) error {
+	_, err := r.db.Exec(`INSERT INTO notebook_owners VALUES($1, $2)
+		ON CONFLICT (notebook_id) DO UPDATE SET user_id = $2`, notebookID.String(), userID.String())
+
+	return errcode.NewNotebookError(notebookID, err, "could not insert owner")
+}
+
+func (r *sqlRepository) FetchOwner(notebookID uuid.UUID) (uuid.UUID, error) {
+	var userID string
+	err := r.db.QueryRow("SELECT user_id FROM notebook_owners WHERE notebook_id = $1", notebookID.String()).
+		Scan(&userID)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, errcode.NewNotFoundError("notebook", notebookID)
+	} else if err != nil {
+		return uuid.Nil, errcode.NewNotebookError(notebookID, err, "could not select owner")
+	}
+
+	owner, err := uuid.Parse(userID)
+	return owner, errcode.NewNotebookError(notebookID, err, "could not parse owner")
+}
+
+func (r *sqlRepository) FetchOwnedNotebooks(userID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.db.Query("SELECT notebook_id FROM notebook_owners WHERE user_id = $1", userID.String())
+	if err != nil {
+		return nil, errcode.NewUnknownError(err, "could not select owned notebooks")
+	}
+	defer rows.Close()
+
+	return scanIDs(rows)
+}
+
+func (r *sqlRepository) StoreMember(notebookID, userID uuid.UUID, role service.Role) error {
+	_, err := r.db.Exec(`INSERT INTO notebook_members VALUES($1, $2, $3)
+		ON CONFLICT (notebook_id, user_id) DO UPDATE SET role = $3`,
+		notebookID.String(), userID.String(), string(role))
+
+	return errcode.NewNotebookError(notebookID, err, "could not insert member")
+}
+
+func (r *sqlRepository) RemoveMember(notebookID, userID uuid.UUID) error {
+	_, err := r.db.Exec("DELETE FROM notebook_members WHERE notebook_id = $1 AND user_id = $2",
+		notebookID.String(), userID.String())
+
+	return errcode.NewNotebookError(notebookID, err, "could not delete member")
+}
+
+func (r *sqlRepository) FetchMembers(notebookID uuid.UUID) (map[uuid.UUID]service.Role, error) {
+	rows, err := r.db.Query("SELECT user_id, role FROM notebook_members WHERE notebook_id = $1",
+		notebookID.String())
+	if err != nil {
+		return nil, errcode.NewNotebookError(notebookID, err, "could not select members")
+	}
+	defer rows.Close()
+
+	return scanRoles(rows)
+}
+
+func (r *sqlRepository) FetchSharedNotebooks(userID uuid.UUID) (map[uuid.UUID]service.Role, error) {
+	rows, err := r.db.Query("SELECT notebook_id, role FROM notebook_members WHERE user_id = $1",
+		userID.String())
+	if err != nil {
+		return nil, errcode.NewUnknownError(err, "could not select shared notebooks")
+	}
+	defer rows.Close()
+
+	return scanRoles(rows)
+}
+
+func (r *sqlRepository) StoreShareLink(link *service.ShareLink) error {
+	var listID sql.NullString
+	if link.ListID != uuid.Nil {
+		listID = sql.NullString{String: link.ListID.String(), Valid: true}
+	}
+	var expiresAt sql.NullTime
+	if !link.ExpiresAt.IsZero() {
+		expiresAt = sql.NullTime{Time: link.ExpiresAt, Valid: true}
+	}
+
+	_, err := r.db.Exec("INSERT INTO share_links VALUES($1, $2, $3, $4, $5, $6)",
+		link.ID.String(), link.TokenHash, link.NotebookID.String(), listID, expiresAt, link.CreatedAt)
+
+	return errcode.NewNotebookError(link.NotebookID, err, "could not insert share link")
+}
+
+func (r *sqlRepository) FetchShareLink(tokenHash string) (*service.ShareLink, error) {
+	rows, err := r.db.Query(`SELECT id, token_hash, notebook_id, list_id, expires_at, created_at
+		FROM share_links WHERE token_hash = $1`, tokenHash)
+	if err != nil {
+		return nil, errcode.NewUnknownError(err, "could not select share link")
+	}
+	defer rows.Close()
+
+	links, err := scanShareLinks(rows)
+	if err != nil {
+		return nil, err
+	} else if len(links) == 0 {
+		return nil, errcode.NewNotFoundError("share link", uuid.Nil)
+	}
+
+	return &links[0], nil
+}
+
+func (r *sqlRepository) FetchShareLinks(notebookID uuid.UUID) ([]service.ShareLink, error) {
+	rows, err := r.db.Query(`SELECT id, token_hash, notebook_id, list_id, expires_at, created_at
+		FROM share_links WHERE notebook_id = $1`, notebookID.String())
+	if err != nil {
+		return nil, errcode.NewNotebookError(notebookID, err, "could not select share links")
+	}
+	defer rows.Close()
+
+	return scanShareLinks(rows)
+}
+
+func (r *sqlRepository) RemoveShareLink(notebookID, id uuid.UUID) error {
+	result, err := r.db.Exec("DELETE FROM share_links WHERE notebook_id = $1 AND id = $2",
+		notebookID.String(), id.String())
+	if err != nil {
+		return errcode.NewNotebookError(notebookID, err, "could not delete share link")
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return errcode.NewNotebookError(notebookID, err, "could not delete share link")
+	} else if count <= 0 {
+		return errcode.NewNotFoundError("share link", id)
+	}
+
+	return nil
+}
+
+func (r *sqlRepository) FetchRevisions(notebookID uuid.UUID) ([]service.Revision, error) {
+	return selectRevisions(r.db, notebookID)
+}
+
+func (r *sqlRepository) FetchRevision(notebookID uuid.UUID, number int64) (*service.Revision, error) {
+	return selectRevision(r.db, notebookID, number)
+}
+
+// selectNotebook fetches the notebook with the given ID within the
+// given transaction and locks its row until the transaction ends
+func (r *sqlRepository) selectNotebook(tx *sql.Tx, id uuid.UUID) (*solvent.Notebook, error) {
+	var data []byte
+	err := tx.QueryRow("SELECT data FROM notebooks WHERE id = $1"+r.dialect.lockRow, id.String()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, errcode.NewNotFoundError("notebook", id)
+	} else if err != nil {
+		return nil, errcode.NewNotebookError(id, err, "could not execute select")
+	}
+
+	return notebookFromJson(id, data)
+}
@@ -0,0 +1,87 @@
+package persistence
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/eldelto/solvent/service/errcode"
+
+	// Import pure Go SQLite driver
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteRepository stores notebooks and accounts in a single SQLite
+// database file, so single node deployments do not need a separate
+// database server
+type SQLiteRepository struct {
+	*sqlRepository
+}
+
+// NewSQLiteRepository opens the SQLite database at the given path and
+// creates it if it does not exist yet
+func NewSQLiteRepository(path string) (*SQLiteRepository, error) {
+	connectURL := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)", path)
+	db, err := sql.Open("sqlite", connectURL)
+	if err != nil {
+		return nil, errcode.NewUnknownError(err, "could not open DB")
+	}
+	// SQLite only allows a single writer at a time, so a single
+	// connection serializes all transactions without running into
+	// busy errors
+	db.SetMaxOpenConns(1)
+
+	repo := SQLiteRepository{sqlRepository: &sqlRepository{db: db, dialect: sqliteDialect}}
+
+	for _, statement := range []string{
+		`CREATE TABLE IF NOT EXISTS notebooks(
+			id TEXT PRIMARY KEY NOT NULL,
+			data BLOB NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS users(
+			id TEXT PRIMARY KEY NOT NULL,
+			name TEXT UNIQUE NOT NULL,
+			password_hash BLOB NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS sessions(
+			token_hash TEXT PRIMARY KEY NOT NULL,
+			user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			expires_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS notebook_owners(
+			notebook_id TEXT PRIMARY KEY NOT NULL,
+			user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS notebook_owners_user_id ON notebook_owners(user_id)`,
+		`CREATE TABLE IF NOT EXISTS notebook_members(
+			notebook_id TEXT NOT NULL,
+			user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			role TEXT NOT NULL,
+			PRIMARY KEY (notebook_id, user_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS notebook_members_user_id ON notebook_members(user_id)`,
+		`CREATE TABLE IF NOT EXISTS share_links(
+			id TEXT PRIMARY KEY NOT NULL,
+			token_hash TEXT UNIQUE NOT NULL,
+			notebook_id TEXT NOT NULL,
+			list_id TEXT,
+			expires_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS share_links_notebook_id ON share_links(notebook_id)`,
+		`CREATE TABLE IF NOT EXISTS notebook_revisions(
+			number INTEGER PRIMARY KEY AUTOINCREMENT,
+			notebook_id TEXT NOT NULL,
+			data BLOB NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS notebook_revisions_notebook_id ON notebook_revisions(notebook_id)`,
+	} {
+		_, err = repo.db.Exec(statement)
+		if err != nil {
+			db.Close()
+			return nil, errcode.NewUnknownError(err, "could not initialize DB")
+		}
+	}
+
+	return &repo, nil
+}